@@ -2,4 +2,7 @@ module github.com/torpago/simple-media-proc
 
 go 1.23.8
 
-require gopkg.in/gographics/imagick.v3 v3.7.0
+require (
+	gopkg.in/gographics/imagick.v3 v3.7.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,144 @@
+package mwclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// readInput reads input i of a multi-image job into mw
+type readInput func(mw *imagick.MagickWand, i int, s *settings) error
+
+// writeDocument writes the assembled pages in mw
+type writeDocument func(mw *imagick.MagickWand, s *settings) error
+
+// readerInputs checks the inputs and writer of a reader-based job and
+// returns a function reading input i
+func readerInputs(inputs []io.Reader, w io.Writer) (readInput, error) {
+	if len(inputs) == 0 || w == nil {
+		return nil, fmt.Errorf("%w: no inputs or writer is nil", ErrInvalidInput)
+	}
+	for i, r := range inputs {
+		if r == nil {
+			return nil, fmt.Errorf("%w: input %d is nil", ErrInvalidInput, i)
+		}
+	}
+
+	return func(mw *imagick.MagickWand, i int, s *settings) error {
+		return readImage(mw, inputs[i], s)
+	}, nil
+}
+
+// pathInputs checks the paths of a file-based job and returns a function
+// reading input i
+func pathInputs(inputPaths []string, outputPath string) (readInput, error) {
+	if len(inputPaths) == 0 || outputPath == "" {
+		return nil, fmt.Errorf("%w: no input paths or output path is empty", ErrInvalidInput)
+	}
+	for i, path := range inputPaths {
+		if path == "" {
+			return nil, fmt.Errorf("%w: input path %d is empty", ErrInvalidInput, i)
+		}
+	}
+
+	return func(mw *imagick.MagickWand, i int, s *settings) error {
+		if err := checkFileSize(inputPaths[i], s); err != nil {
+			return err
+		}
+		s.logger.Info("ReadImage", "In", inputPaths[i])
+		if err := mw.ReadImage(inputPaths[i]); err != nil {
+			return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
+		}
+		return nil
+	}, nil
+}
+
+// documentWriter returns a function writing a document to outputPath in
+// format, whatever the extension of the path. A partial output is removed.
+func documentWriter(format, outputPath string) writeDocument {
+	return func(mw *imagick.MagickWand, s *settings) error {
+		s.logger.Info("WriteImage", "Out", outputPath)
+		if err := writeImageFile(mw, format+":"+outputPath); err != nil {
+			os.Remove(outputPath)
+			return err
+		}
+		return nil
+	}
+}
+
+// assemble reads n images with read and combines all their pages into one
+// document, written with write. Every page is auto-oriented, passed through
+// the output policies and then prepared for the document with page.
+func (c *Client) assemble(ctx context.Context, n int, read readInput,
+	page func(mw *imagick.MagickWand, s *settings) error,
+	write writeDocument, opts []Option) error {
+
+	s, err := c.settingsFor(opts)
+	if err != nil {
+		return err
+	}
+
+	release, err := c.pool.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	doc := imagick.NewMagickWand()
+	defer doc.Destroy()
+
+	for i := 0; i < n; i++ {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+
+		if err := addPages(doc, i, &s, read, page); err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	doc.SetFirstIterator()
+	return write(doc, &s)
+}
+
+// addPages reads input i, prepares each of its pages and appends them to
+// doc
+func addPages(doc *imagick.MagickWand, i int, s *settings, read readInput,
+	page func(mw *imagick.MagickWand, s *settings) error) error {
+
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+
+	if err := read(mw, i, s); err != nil {
+		return err
+	}
+
+	err := forEachFrame(mw, func() error {
+		if err := mw.AutoOrientImage(); err != nil {
+			s.logger.Error("Auto-orientation failed", "error", err)
+			// Continue despite error
+		}
+
+		if err := applyOutputPolicies(mw, s); err != nil {
+			return err
+		}
+		return page(mw, s)
+	})
+	if err != nil {
+		return err
+	}
+
+	// AddImage appends every image of mw after the current one
+	doc.SetLastIterator()
+	if err := doc.AddImage(mw); err != nil {
+		return fmt.Errorf("%w: failed to add page: %v", ErrProcessing, err)
+	}
+	return nil
+}
@@ -1,14 +1,12 @@
 package mwclient
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log/slog"
 	"os"
-	"path/filepath"
-	"strings"
-	"sync"
+	"time"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
@@ -17,8 +15,39 @@ import (
 var (
 	ErrInvalidInput = errors.New("invalid input")
 	ErrProcessing   = errors.New("processing error")
+
+	// ErrInputTooLarge is returned when an input exceeds the size set with
+	// WithMaxInputSize. It wraps ErrInvalidInput.
+	ErrInputTooLarge = fmt.Errorf("%w: input too large", ErrInvalidInput)
+
+	// ErrSizeUnreachable is returned when an image cannot be encoded within
+	// a SizeTarget. It wraps ErrProcessing.
+	ErrSizeUnreachable = fmt.Errorf("%w: size target unreachable", ErrProcessing)
+
+	// ErrPasswordRequired is returned when a PDF is encrypted and no
+	// password was given with WithPdfPassword. It wraps ErrInvalidInput.
+	ErrPasswordRequired = fmt.Errorf("%w: password required", ErrInvalidInput)
+
+	// ErrWrongPassword is returned when the password given with
+	// WithPdfPassword does not open an encrypted PDF. It wraps
+	// ErrInvalidInput.
+	ErrWrongPassword = fmt.Errorf("%w: wrong password", ErrInvalidInput)
+
+	// ErrStopPages can be returned by a PageFunc to stop rasterizing
+	// further pages. The call then returns nil.
+	ErrStopPages = errors.New("stop pages")
 )
 
+// checkContext reports whether ctx is done. The returned error wraps both
+// ErrProcessing and the context error, so callers can match either with
+// errors.Is.
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrProcessing, err)
+	}
+	return nil
+}
+
 // ImageMeta contains metadata about an image
 type ImageMeta struct {
 	FormatName      string
@@ -26,17 +55,102 @@ type ImageMeta struct {
 	ImageHeight     int32
 	ExifOrientation int16
 	ContentLength   int64
+
+	// Camera and capture details from EXIF. CaptureTime is zero if unknown
+	// and GPS is nil if the image carries no location.
+	Make        string
+	Model       string
+	CaptureTime time.Time
+	GPS         *GPSCoordinates
+
+	// Pixel format details
+	ColorSpace    string
+	BitDepth      uint
+	DPI           Resolution
+	ICCProfile    string // description of the embedded ICC profile, empty if none
+	ICCColorSpace string // color space of the embedded ICC profile, such as "RGB" or "CMYK"
+	FrameCount    int
+	HasAlpha      bool
+
+	// Encrypted is set for encrypted PDFs, including those protected by an
+	// owner password only, which open without one. It is read from the
+	// document trailer before rasterizing, so it is also set, along with
+	// FormatName, when OpenImage fails with ErrPasswordRequired or
+	// ErrWrongPassword.
+	Encrypted bool
+
+	// Pages describes every page of a multi-page image such as a scanned
+	// TIFF, or every frame of an animation. The fields above describe the
+	// first page.
+	Pages []PageInfo
+
+	// Raw metadata. EXIF maps tag names, without the "exif:" prefix, to
+	// ImageMagick's string form of their values.
+	EXIF map[string]string
+	IPTC *IPTC
+	XMP  []byte
 }
 
-// Client represents an ImageMagick client wrapper
+// Client represents an ImageMagick client wrapper.
+// Methods without a context parameter have a ...Ctx variant that takes one;
+// the others take the context as their first argument.
+// Operations run on independent MagickWands; the number processed at the same
+// time is bounded by the client's worker pool.
 type Client struct {
-	mu sync.Mutex
+	pool     *pool
+	settings settings
+	err      error // invalid options passed to New
 }
 
-// New creates a new ImageMagick client
-func New() *Client {
+// New creates a new ImageMagick client. Without options it processes one
+// image at a time with the default quality, filter and PDF density; every
+// processing option can also be overridden for a single call.
+// Invalid options are logged and reported by Err, and every operation of
+// the client then fails with the same error.
+func New(opts ...Option) *Client {
 	imagick.Initialize()
-	return &Client{}
+
+	s := defaultSettings()
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	c := &Client{pool: newPool(s.workers), settings: s}
+	if err := s.validate(); err != nil {
+		s.logger.Error("Invalid client options", "error", err)
+		c.err = err
+		return c
+	}
+
+	if err := s.limits.apply(); err != nil {
+		s.logger.Error("Failed to apply resource limits", "error", err)
+	}
+
+	return c
+}
+
+// Err returns the error found validating the options passed to New, or nil
+// if they are valid
+func (c *Client) Err() error {
+	return c.err
+}
+
+// NewWithConcurrency creates a new ImageMagick client that processes up to
+// workers images in parallel. threadsPerWorker caps the number of threads
+// ImageMagick uses for a single operation, so that workers*threadsPerWorker
+// roughly matches the available cores; 0 keeps ImageMagick's default.
+// The thread limit is process-wide, as ImageMagick has no per-wand setting.
+func NewWithConcurrency(workers, threadsPerWorker int, opts ...Option) *Client {
+	opts = append([]Option{
+		WithConcurrency(workers),
+		WithResourceLimits(ResourceLimits{Threads: int64(threadsPerWorker)}),
+	}, opts...)
+	return New(opts...)
+}
+
+// Stats returns a snapshot of the client's worker pool usage
+func (c *Client) Stats() PoolStats {
+	return c.pool.stats()
 }
 
 // Close releases resources used by the ImageMagick client
@@ -45,40 +159,73 @@ func (c *Client) Close() {
 }
 
 // OpenImage opens an image from a file path and extracts metadata
-func (c *Client) OpenImage(imagePath string) (ImageMeta, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Client) OpenImage(imagePath string, opts ...Option) (ImageMeta, error) {
+	return c.OpenImageCtx(context.Background(), imagePath, opts...)
+}
 
+// OpenImageCtx is like OpenImage but aborts when ctx is done
+func (c *Client) OpenImageCtx(ctx context.Context, imagePath string, opts ...Option) (ImageMeta, error) {
 	var meta ImageMeta
 
 	if imagePath == "" {
 		return meta, fmt.Errorf("%w: image path is empty", ErrInvalidInput)
 	}
 
+	s, err := c.settingsFor(opts)
+	if err != nil {
+		return meta, err
+	}
+
+	release, err := c.pool.acquire(ctx)
+	if err != nil {
+		return meta, err
+	}
+	defer release()
+
 	mw := imagick.NewMagickWand()
 	defer mw.Destroy()
 
-	err := mw.ReadImage(imagePath)
+	if err := setPdfPassword(mw, &s); err != nil {
+		return meta, err
+	}
+
+	if err := checkFileSize(imagePath, &s); err != nil {
+		return meta, err
+	}
+
+	// Fail fast on PDFs that cannot be opened without a password
+	encrypted, locked, _ := pdfEncryptedFile(imagePath)
+	if locked && s.pdfPassword == "" {
+		meta.FormatName = "PDF"
+		meta.Encrypted = true
+		return meta, fmt.Errorf("%w: PDF is encrypted", ErrPasswordRequired)
+	}
+
+	err = mw.ReadImage(imagePath)
 	if err != nil {
+		// Report encrypted PDFs that could not be opened as such
+		if perr := passwordError(imagePath, &s, err); perr != nil {
+			meta.FormatName = "PDF"
+			meta.Encrypted = true
+			return meta, perr
+		}
 		return meta, fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
 	}
 
-	// Extract metadata
-	meta = ImageMeta{
-		FormatName:      mw.GetImageFormat(),
-		ImageWidth:      int32(mw.GetImageWidth()),
-		ImageHeight:     int32(mw.GetImageHeight()),
-		ExifOrientation: int16(mw.GetOrientation()),
+	if err := checkContext(ctx); err != nil {
+		return meta, err
 	}
 
-	if cl, err := mw.GetImageLength(); err == nil {
-		meta.ContentLength = int64(cl)
+	// Extract metadata
+	meta = readMeta(mw, s.logger)
+	if meta.FormatName == "PDF" {
+		meta.Encrypted = encrypted
 	}
 
 	// Auto-orient the image based on EXIF data
 	err = mw.AutoOrientImage()
 	if err != nil {
-		slog.Error("Auto-orientation failed", "error", err)
+		s.logger.Error("Auto-orientation failed", "error", err)
 		// Continue despite error
 	}
 
@@ -86,17 +233,24 @@ func (c *Client) OpenImage(imagePath string) (ImageMeta, error) {
 }
 
 // GetImageMetadata extracts metadata from an image file
-func (c *Client) GetImageMetadata(imagePath string) (ImageMeta, error) {
+func (c *Client) GetImageMetadata(imagePath string, opts ...Option) (ImageMeta, error) {
 	// This is now just an alias for OpenImage for backward compatibility
-	return c.OpenImage(imagePath)
+	return c.OpenImage(imagePath, opts...)
+}
+
+// GetImageMetadataCtx is like GetImageMetadata but aborts when ctx is done
+func (c *Client) GetImageMetadataCtx(ctx context.Context, imagePath string, opts ...Option) (ImageMeta, error) {
+	return c.OpenImageCtx(ctx, imagePath, opts...)
 }
 
 // ResizeImage resizes an image from a reader to the specified dimensions
 // and writes the result to the provided writer
-func (c *Client) ResizeImage(r io.Reader, w io.Writer, width, height uint, format string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Client) ResizeImage(r io.Reader, w io.Writer, width, height uint, format string, opts ...Option) error {
+	return c.ResizeImageCtx(context.Background(), r, w, width, height, format, opts...)
+}
 
+// ResizeImageCtx is like ResizeImage but aborts when ctx is done
+func (c *Client) ResizeImageCtx(ctx context.Context, r io.Reader, w io.Writer, width, height uint, format string, opts ...Option) error {
 	if r == nil || w == nil {
 		return fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
 	}
@@ -105,116 +259,31 @@ func (c *Client) ResizeImage(r io.Reader, w io.Writer, width, height uint, forma
 		return fmt.Errorf("%w: invalid dimensions", ErrInvalidInput)
 	}
 
-	mw := imagick.NewMagickWand()
-	defer mw.Destroy()
-
-	// Read image data
-	data, err := io.ReadAll(r)
-	if err != nil {
-		return fmt.Errorf("failed to read image data: %w", err)
-	}
-
-	if err := mw.ReadImageBlob(data); err != nil {
-		return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
-	}
-
-	// Auto-orient the image based on EXIF data
-	if err := mw.AutoOrientImage(); err != nil {
-		slog.Error("Auto-orientation failed", "error", err)
-		// Continue despite error
-	}
-
-	// Resize the image using the Sinc filter (as in the original implementation)
-	if err := mw.ResizeImage(width, height, imagick.FILTER_SINC); err != nil {
-		return fmt.Errorf("%w: failed to resize image: %v", ErrProcessing, err)
-	}
-
-	// Set compression quality to 95 (high quality)
-	if err := mw.SetImageCompressionQuality(95); err != nil {
-		return fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
-	}
-
-	// Set the output format if specified
-	if format != "" {
-		if err := mw.SetImageFormat(format); err != nil {
-			return fmt.Errorf("%w: failed to set image format: %v", ErrProcessing, err)
-		}
-	}
-
-	// Get the image blob
-	blob, err := mw.GetImageBlob()
-	if err != nil {
-		return fmt.Errorf("%w: failed to get image blob: %v", ErrProcessing, err)
-	}
-	if len(blob) == 0 {
-		return fmt.Errorf("%w: empty result image", ErrProcessing)
-	}
-
-	// Write the result
-	if _, err := w.Write(blob); err != nil {
-		return fmt.Errorf("failed to write image data: %w", err)
-	}
-
-	return nil
+	return c.Pipeline(opts...).AutoOrient().Resize(width, height).Encode(format).Run(ctx, r, w)
 }
 
 // ResizeImageFile resizes an image from a file path to the specified dimensions
 // and writes the result to the output file path
-func (c *Client) ResizeImageFile(inputPath, outputPath string, width, height uint, format string) error {
+func (c *Client) ResizeImageFile(inputPath, outputPath string, width, height uint, format string, opts ...Option) error {
+	return c.ResizeImageFileCtx(context.Background(), inputPath, outputPath, width, height, format, opts...)
+}
+
+// ResizeImageFileCtx is like ResizeImageFile but aborts when ctx is done
+func (c *Client) ResizeImageFileCtx(ctx context.Context, inputPath, outputPath string, width, height uint, format string, opts ...Option) error {
 	if inputPath == "" || outputPath == "" {
 		return fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	mw := imagick.NewMagickWand()
-	defer mw.Destroy()
-
-	// Read the image
-	slog.Info("ReadImage", "In", inputPath)
-	if err := mw.ReadImage(inputPath); err != nil {
-		return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
-	}
-
-	// Auto-orient the image based on EXIF data
-	if err := mw.AutoOrientImage(); err != nil {
-		slog.Error("Auto-orientation failed", "error", err)
-		// Continue despite error
-	}
-
-	// Resize the image using the Sinc filter
-	if err := mw.ResizeImage(width, height, imagick.FILTER_SINC); err != nil {
-		return fmt.Errorf("%w: failed to resize image: %v", ErrProcessing, err)
-	}
-
-	// Set compression quality to 95 (high quality)
-	if err := mw.SetImageCompressionQuality(95); err != nil {
-		return fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
-	}
-
-	// Set the output format if specified
-	if format != "" {
-		slog.Info("SetImageFormat", "Format", format)
-		if err := mw.SetImageFormat(format); err != nil {
-			return fmt.Errorf("%w: failed to set image format: %v", ErrProcessing, err)
-		}
-	}
-
-	// Write the image directly to file
-	slog.Info("WriteImage", "Out", outputPath)
-	if err := mw.WriteImage(outputPath); err != nil {
-		return fmt.Errorf("%w: failed to write image: %v", ErrProcessing, err)
-	}
-
-	return nil
+	return c.Pipeline(opts...).AutoOrient().Resize(width, height).Encode(format).RunFile(ctx, inputPath, outputPath)
 }
 
 // ConvertFormat converts an image from one format to another
-func (c *Client) ConvertFormat(r io.Reader, w io.Writer, format string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Client) ConvertFormat(r io.Reader, w io.Writer, format string, opts ...Option) error {
+	return c.ConvertFormatCtx(context.Background(), r, w, format, opts...)
+}
 
+// ConvertFormatCtx is like ConvertFormat but aborts when ctx is done
+func (c *Client) ConvertFormatCtx(ctx context.Context, r io.Reader, w io.Writer, format string, opts ...Option) error {
 	if r == nil || w == nil {
 		return fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
 	}
@@ -223,57 +292,16 @@ func (c *Client) ConvertFormat(r io.Reader, w io.Writer, format string) error {
 		return fmt.Errorf("%w: format is empty", ErrInvalidInput)
 	}
 
-	mw := imagick.NewMagickWand()
-	defer mw.Destroy()
-
-	// Read image data
-	data, err := io.ReadAll(r)
-	if err != nil {
-		return fmt.Errorf("failed to read image data: %w", err)
-	}
-
-	if err := mw.ReadImageBlob(data); err != nil {
-		return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
-	}
-
-	// Auto-orient the image based on EXIF data
-	if err := mw.AutoOrientImage(); err != nil {
-		slog.Error("Auto-orientation failed", "error", err)
-		// Continue despite error
-	}
-
-	// Set compression quality to 95 (high quality)
-	if err := mw.SetImageCompressionQuality(95); err != nil {
-		return fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
-	}
-
-	// Set the output format
-	if err := mw.SetImageFormat(format); err != nil {
-		return fmt.Errorf("%w: failed to set image format: %v", ErrProcessing, err)
-	}
-
-	// Get the image blob
-	blob, err := mw.GetImageBlob()
-	if err != nil {
-		return fmt.Errorf("%w: failed to get image blob: %v", ErrProcessing, err)
-	}
-	if len(blob) == 0 {
-		return fmt.Errorf("%w: empty result image", ErrProcessing)
-	}
-
-	// Write the result
-	if _, err := w.Write(blob); err != nil {
-		return fmt.Errorf("failed to write image data: %w", err)
-	}
-
-	return nil
+	return c.Pipeline(opts...).AutoOrient().Encode(format).Run(ctx, r, w)
 }
 
 // ResizeByHeight resizes an image to a specific height while maintaining aspect ratio
-func (c *Client) ResizeByHeight(inputPath, outputPath string, targetHeight int) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Client) ResizeByHeight(inputPath, outputPath string, targetHeight int, opts ...Option) error {
+	return c.ResizeByHeightCtx(context.Background(), inputPath, outputPath, targetHeight, opts...)
+}
 
+// ResizeByHeightCtx is like ResizeByHeight but aborts when ctx is done
+func (c *Client) ResizeByHeightCtx(ctx context.Context, inputPath, outputPath string, targetHeight int, opts ...Option) error {
 	if inputPath == "" || outputPath == "" {
 		return fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
 	}
@@ -282,55 +310,16 @@ func (c *Client) ResizeByHeight(inputPath, outputPath string, targetHeight int)
 		return fmt.Errorf("%w: target height must be positive", ErrInvalidInput)
 	}
 
-	mw := imagick.NewMagickWand()
-	defer mw.Destroy()
-
-	// Read the image
-	slog.Info("ReadImage", "In", inputPath)
-	if err := mw.ReadImage(inputPath); err != nil {
-		return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
-	}
-
-	// Auto-orient the image based on EXIF data
-	slog.Info("AutoOrientImage")
-	if err := mw.AutoOrientImage(); err != nil {
-		slog.Error("Auto-orientation failed", "error", err)
-		// Continue despite error
-	}
-
-	// Get image dimensions
-	imageWidth := int32(mw.GetImageWidth())
-	imageHeight := int32(mw.GetImageHeight())
-
-	slog.Info("ResizeByHeight with Sinc", "Out", outputPath, "Height", targetHeight)
-
-	// Calculate the target width, keeping aspect ratio
-	targetWidth := uint(imageWidth * int32(targetHeight) / imageHeight)
-
-	// Resize the image using the Sinc filter
-	if err := mw.ResizeImage(targetWidth, uint(targetHeight), imagick.FILTER_SINC); err != nil {
-		return fmt.Errorf("%w: failed to resize image: %v", ErrProcessing, err)
-	}
-
-	// Set compression quality to 95 (high quality)
-	if err := mw.SetImageCompressionQuality(95); err != nil {
-		return fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
-	}
-
-	// Write the image directly to file
-	slog.Info("WriteImage", "Out", outputPath)
-	if err := mw.WriteImage(outputPath); err != nil {
-		return fmt.Errorf("%w: failed to write image: %v", ErrProcessing, err)
-	}
-
-	return nil
+	return c.Pipeline(opts...).AutoOrient().Resize(0, uint(targetHeight)).RunFile(ctx, inputPath, outputPath)
 }
 
 // ResizeByWidth resizes an image to a specific width while maintaining aspect ratio
-func (c *Client) ResizeByWidth(inputPath, outputPath string, targetWidth int) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Client) ResizeByWidth(inputPath, outputPath string, targetWidth int, opts ...Option) error {
+	return c.ResizeByWidthCtx(context.Background(), inputPath, outputPath, targetWidth, opts...)
+}
 
+// ResizeByWidthCtx is like ResizeByWidth but aborts when ctx is done
+func (c *Client) ResizeByWidthCtx(ctx context.Context, inputPath, outputPath string, targetWidth int, opts ...Option) error {
 	if inputPath == "" || outputPath == "" {
 		return fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
 	}
@@ -344,192 +333,32 @@ func (c *Client) ResizeByWidth(inputPath, outputPath string, targetWidth int) er
 		return fmt.Errorf("%w: input file does not exist: %v", ErrInvalidInput, err)
 	}
 
-	mw := imagick.NewMagickWand()
-	defer mw.Destroy()
-
-	// Read the image
-	slog.Info("ReadImage", "In", inputPath)
-	if err := mw.ReadImage(inputPath); err != nil {
-		return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
-	}
-
-	// Auto-orient the image based on EXIF data
-	slog.Info("AutoOrientImage")
-	if err := mw.AutoOrientImage(); err != nil {
-		slog.Error("Auto-orientation failed", "error", err)
-		// Continue despite error
-	}
-
-	// Get image dimensions
-	imageWidth := int32(mw.GetImageWidth())
-	imageHeight := int32(mw.GetImageHeight())
-
-	slog.Info("ResizeByWidth with Sinc", "Out", outputPath, "Width", targetWidth)
-
-	// Calculate the target height, keeping aspect ratio
-	targetHeight := uint(imageHeight * int32(targetWidth) / imageWidth)
-
-	// Resize the image using the Sinc filter
-	if err := mw.ResizeImage(uint(targetWidth), targetHeight, imagick.FILTER_SINC); err != nil {
-		return fmt.Errorf("%w: failed to resize image: %v", ErrProcessing, err)
-	}
-
-	// Set compression quality to 95 (high quality)
-	if err := mw.SetImageCompressionQuality(95); err != nil {
-		return fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
-	}
-
-	// Write the image directly to file
-	slog.Info("WriteImage", "Out", outputPath)
-	if err := mw.WriteImage(outputPath); err != nil {
-		return fmt.Errorf("%w: failed to write image: %v", ErrProcessing, err)
-	}
-
-	return nil
+	return c.Pipeline(opts...).AutoOrient().Resize(uint(targetWidth), 0).RunFile(ctx, inputPath, outputPath)
 }
 
 // ConvertPdfToImages converts a PDF file to one or more images
 // If createMontage is true, it will combine the images into a single montage image
 // maxPages limits the number of pages to process (0 means all pages)
 // targetHeight specifies the height for the output images
-func (c *Client) ConvertPdfToImages(inputPath, outputPath string, maxPages int, targetHeight int, createMontage bool) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if inputPath == "" || outputPath == "" {
-		return fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
-	}
+// Pages that fail are skipped; the returned PageErrors lists them
+// Encrypted PDFs need WithPdfPassword, or fail with ErrPasswordRequired
+func (c *Client) ConvertPdfToImages(inputPath, outputPath string, maxPages int, targetHeight int, createMontage bool, opts ...Option) error {
+	return c.ConvertPdfToImagesCtx(context.Background(), inputPath, outputPath, maxPages, targetHeight, createMontage, opts...)
+}
 
+// ConvertPdfToImagesCtx is like ConvertPdfToImages but aborts when ctx is done.
+// Cancellation is checked before rasterizing and between pages.
+func (c *Client) ConvertPdfToImagesCtx(ctx context.Context, inputPath, outputPath string, maxPages int, targetHeight int, createMontage bool, opts ...Option) error {
 	if targetHeight <= 0 {
 		return fmt.Errorf("%w: target height must be positive", ErrInvalidInput)
 	}
 
-	// Check if input file exists
-	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
-		return fmt.Errorf("%w: input file does not exist: %v", ErrInvalidInput, err)
-	}
-
-	// Read the PDF
-	pdfWand := imagick.NewMagickWand()
-	defer pdfWand.Destroy()
-
-	// bump PDF raster density to 300 DPI for sharper text/lines:
-	if err := pdfWand.SetResolution(300, 300); err != nil {
-		return fmt.Errorf("%w: could not set resolution: %v", ErrProcessing, err)
-	}
-
-	if err := pdfWand.ReadImage(inputPath); err != nil {
-		return fmt.Errorf("%w: failed to read PDF: %v", ErrProcessing, err)
+	pdf := PdfOptions{Height: targetHeight, Montage: createMontage}
+	if maxPages > 0 {
+		// Only the first maxPages pages are rasterized
+		pdf.Pages = PageSelection{ranges: []pageRange{{first: 1, last: maxPages}}}
 	}
 
-	// Get the number of pages
-	numPages := pdfWand.GetNumberImages()
-	slog.Info("ConvertPdf", "Out", outputPath, "Page Height", targetHeight, "Total Pages", numPages)
-
-	// Limit the number of pages if maxPages is specified
-	if maxPages > 0 && int(numPages) > maxPages {
-		numPages = uint(maxPages)
-	}
-
-	// Create a new wand for the output images
-	mw := imagick.NewMagickWand()
-	defer mw.Destroy()
-
-	// Add each page to the output wand
-	for i := 0; i < int(numPages); i++ {
-		slog.Info("Processing page", "Index", i)
-		pdfWand.SetIteratorIndex(i)
-		pageImg := pdfWand.GetImage()
-
-		// Add the page image to the output wand
-		err := mw.AddImage(pageImg)
-		if err != nil {
-			slog.Error("Failed to add page image", "error", err, "page", i)
-			continue
-		}
-
-		// If not creating a montage, save each page as a separate file
-		if !createMontage {
-			// Get the current image from the wand
-			mw.SetIteratorIndex(i)
-			currentImg := mw.GetImage()
-
-			// flatten transparency over white
-			white := imagick.NewPixelWand()
-			defer white.Destroy()
-			white.SetColor("white")
-			currentImg.SetImageBackgroundColor(white)
-			flat := currentImg.MergeImageLayers(imagick.IMAGE_LAYER_FLATTEN) // new flat wand
-			currentImg.Destroy()                                             // drop the raw one early
-			currentImg = flat                                                // now work with flat version
-			defer currentImg.Destroy()                                       // clean up
-
-			// Auto-orient the image based on EXIF data
-			err := currentImg.AutoOrientImage()
-			if err != nil {
-				slog.Error("Auto-orientation failed", "error", err)
-				// Continue despite error
-			}
-
-			// Resize to the target height
-			imageWidth := int32(currentImg.GetImageWidth())
-			imageHeight := int32(currentImg.GetImageHeight())
-			targetWidth := uint(imageWidth * int32(targetHeight) / imageHeight)
-
-			if err := currentImg.ResizeImage(targetWidth, uint(targetHeight), imagick.FILTER_SINC); err != nil {
-				slog.Error("Failed to resize page image", "error", err, "page", i)
-				continue
-			}
-
-			// Set compression quality
-			if err := currentImg.SetImageCompressionQuality(95); err != nil {
-				slog.Error("Failed to set compression quality", "error", err, "page", i)
-			}
-
-			// Generate the output filename for this page
-			pageOutputPath := outputPath
-			if numPages > 1 {
-				ext := filepath.Ext(outputPath)
-				base := strings.TrimSuffix(outputPath, ext)
-				pageOutputPath = fmt.Sprintf("%s_page%d%s", base, i+1, ext)
-				slog.Info("Processing page", "Index", i, "Path", pageOutputPath)
-			}
-
-			// Write the page image to file
-			if err := currentImg.WriteImage(pageOutputPath); err != nil {
-				slog.Error("Failed to write page image", "error", err, "page", i, "path", pageOutputPath)
-			}
-
-			slog.Info("Processed page", "Index", i)
-		}
-	}
-
-	// If creating a montage, combine all pages into one image
-	if createMontage {
-		// Create a drawing wand for the montage
-		dw := imagick.NewDrawingWand()
-		defer dw.Destroy()
-
-		// Set up montage parameters
-		tileGeo := "1x"                              // Stack vertically
-		thumbGeo := fmt.Sprintf("x%d", targetHeight) // Target height
-		mode := imagick.MONTAGE_MODE_CONCATENATE
-		frame := "+0+0" // No frame
-
-		// Create the montage
-		montageWand := mw.MontageImage(dw, tileGeo, thumbGeo, mode, frame)
-		defer montageWand.Destroy()
-
-		// Set compression quality
-		if err := montageWand.SetImageCompressionQuality(95); err != nil {
-			slog.Error("Failed to set montage compression quality", "error", err)
-		}
-
-		// Write the montage to file
-		if err := montageWand.WriteImage(outputPath); err != nil {
-			return fmt.Errorf("%w: failed to write montage image: %v", ErrProcessing, err)
-		}
-	}
-
-	return nil
+	_, err := c.ConvertPdf(ctx, inputPath, outputPath, pdf, opts...)
+	return err
 }
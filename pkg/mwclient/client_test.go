@@ -2,12 +2,15 @@ package mwclient
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/gographics/imagick.v3/imagick"
 )
@@ -460,3 +463,89 @@ func TestConvertPdfToImages(t *testing.T) {
 		}
 	}
 }
+
+// TestContextCancellation tests that the Ctx variants honor cancellation
+func TestContextCancellation(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+		call    func(ctx context.Context) error
+	}{
+		{
+			name:    "ResizeImageCtx canceled",
+			ctx:     canceled,
+			wantErr: context.Canceled,
+			call: func(ctx context.Context) error {
+				var buf bytes.Buffer
+				return client.ResizeImageCtx(ctx, bytes.NewReader([]byte("test")), &buf, 100, 100, "png")
+			},
+		},
+		{
+			name:    "ConvertFormatCtx deadline exceeded",
+			ctx:     expired,
+			wantErr: context.DeadlineExceeded,
+			call: func(ctx context.Context) error {
+				var buf bytes.Buffer
+				return client.ConvertFormatCtx(ctx, bytes.NewReader([]byte("test")), &buf, "png")
+			},
+		},
+		{
+			name:    "ResizeImageFileCtx canceled",
+			ctx:     canceled,
+			wantErr: context.Canceled,
+			call: func(ctx context.Context) error {
+				return client.ResizeImageFileCtx(ctx, "input.png", "output.png", 100, 100, "png")
+			},
+		},
+		{
+			name:    "ResizeByHeightCtx canceled",
+			ctx:     canceled,
+			wantErr: context.Canceled,
+			call: func(ctx context.Context) error {
+				return client.ResizeByHeightCtx(ctx, "input.png", "output.png", 100)
+			},
+		},
+		{
+			name:    "OpenImageCtx deadline exceeded",
+			ctx:     expired,
+			wantErr: context.DeadlineExceeded,
+			call: func(ctx context.Context) error {
+				_, err := client.OpenImageCtx(ctx, "input.png")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call(tt.ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			if !errors.Is(err, ErrProcessing) {
+				t.Errorf("expected error to wrap ErrProcessing, got %v", err)
+			}
+		})
+	}
+
+	// Invalid input is still reported as such, even with a canceled context
+	err := client.ResizeImageCtx(canceled, nil, nil, 100, 100, "png")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package mwclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Gravity selects which part of an image survives a crop
+type Gravity int
+
+const (
+	GravityCenter Gravity = iota
+	GravityNorth
+	GravityNorthEast
+	GravityEast
+	GravitySouthEast
+	GravitySouth
+	GravitySouthWest
+	GravityWest
+	GravityNorthWest
+)
+
+var gravityNames = [...]string{
+	GravityCenter:    "center",
+	GravityNorth:     "north",
+	GravityNorthEast: "northeast",
+	GravityEast:      "east",
+	GravitySouthEast: "southeast",
+	GravitySouth:     "south",
+	GravitySouthWest: "southwest",
+	GravityWest:      "west",
+	GravityNorthWest: "northwest",
+}
+
+// String returns the name of the gravity as accepted by ParseGravity
+func (g Gravity) String() string {
+	if g >= 0 && int(g) < len(gravityNames) {
+		return gravityNames[g]
+	}
+	return fmt.Sprintf("Gravity(%d)", int(g))
+}
+
+// ParseGravity parses a gravity name such as "center", "north" or
+// "south_east". The empty string is GravityCenter.
+func ParseGravity(name string) (Gravity, error) {
+	name = strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(name))
+	if name == "" {
+		return GravityCenter, nil
+	}
+	for g, n := range gravityNames {
+		if n == name {
+			return Gravity(g), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown gravity %q", ErrInvalidInput, name)
+}
+
+// anchor returns the horizontal and vertical position of the gravity as
+// fractions: 0 is left/top, 0.5 is center, 1 is right/bottom
+func (g Gravity) anchor() (float64, float64) {
+	switch g {
+	case GravityNorth:
+		return 0.5, 0
+	case GravityNorthEast:
+		return 1, 0
+	case GravityEast:
+		return 1, 0.5
+	case GravitySouthEast:
+		return 1, 1
+	case GravitySouth:
+		return 0.5, 1
+	case GravitySouthWest:
+		return 0, 1
+	case GravityWest:
+		return 0, 0.5
+	case GravityNorthWest:
+		return 0, 0
+	default:
+		return 0.5, 0.5
+	}
+}
+
+// FocalPoint is a point of interest expressed as fractions of the image
+// size: 0, 0 is the top-left corner and 1, 1 the bottom-right one.
+// Coordinates refer to the auto-oriented image, as it is displayed.
+type FocalPoint struct {
+	X float64 `json:"x" yaml:"x"`
+	Y float64 `json:"y" yaml:"y"`
+}
+
+// CropSpec describes a crop of Width x Height pixels. The region is placed
+// by Focal, when set, or else by Gravity.
+type CropSpec struct {
+	Width   uint
+	Height  uint
+	Gravity Gravity
+	Focal   *FocalPoint
+}
+
+// validate checks the crop size and placement
+func (spec CropSpec) validate() error {
+	if spec.Width == 0 || spec.Height == 0 {
+		return fmt.Errorf("%w: invalid crop dimensions", ErrInvalidInput)
+	}
+	if err := checkPlacement(spec.Gravity, spec.Focal); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return nil
+}
+
+// checkPlacement checks a gravity and optional focal point. The error does
+// not wrap a sentinel, so callers can add their own context.
+func checkPlacement(gravity Gravity, focal *FocalPoint) error {
+	if gravity < 0 || int(gravity) >= len(gravityNames) {
+		return fmt.Errorf("unknown gravity %v", gravity)
+	}
+	if focal != nil {
+		f := *focal
+		if math.IsNaN(f.X) || math.IsNaN(f.Y) || f.X < 0 || f.X > 1 || f.Y < 0 || f.Y > 1 {
+			return errors.New("focal point must be between 0 and 1")
+		}
+	}
+	return nil
+}
+
+// cropOrigin returns the top-left corner of a width x height region of a
+// srcWidth x srcHeight image. A focal point centers the region on the point
+// as far as the image bounds allow; otherwise the gravity anchors it.
+func cropOrigin(srcWidth, srcHeight, width, height uint, gravity Gravity, focal *FocalPoint) (int, int) {
+	spareX := float64(srcWidth) - float64(min(width, srcWidth))
+	spareY := float64(srcHeight) - float64(min(height, srcHeight))
+
+	var x, y float64
+	if focal != nil {
+		x = focal.X*float64(srcWidth) - float64(width)/2
+		y = focal.Y*float64(srcHeight) - float64(height)/2
+	} else {
+		ax, ay := gravity.anchor()
+		x, y = ax*spareX, ay*spareY
+	}
+
+	x = math.Max(0, math.Min(spareX, math.Round(x)))
+	y = math.Max(0, math.Min(spareY, math.Round(y)))
+	return int(x), int(y)
+}
+
+// CropTo crops the image according to spec. A region larger than the image
+// is clamped to the image size.
+func (p *Pipeline) CropTo(spec CropSpec) *Pipeline {
+	return p.add("Crop", func(mw *imagick.MagickWand, s *settings) error {
+		if err := spec.validate(); err != nil {
+			return err
+		}
+
+		srcWidth, srcHeight := mw.GetImageWidth(), mw.GetImageHeight()
+		width, height := min(spec.Width, srcWidth), min(spec.Height, srcHeight)
+		x, y := cropOrigin(srcWidth, srcHeight, width, height, spec.Gravity, spec.Focal)
+
+		if err := mw.CropImage(width, height, x, y); err != nil {
+			return fmt.Errorf("%w: failed to crop image: %v", ErrProcessing, err)
+		}
+		if err := mw.ResetImagePage(""); err != nil {
+			return fmt.Errorf("%w: failed to reset image page: %v", ErrProcessing, err)
+		}
+		return nil
+	})
+}
+
+// Crop crops an image from a reader according to spec and writes the result
+// to the provided writer. The image is auto-oriented first, so gravity and
+// focal points refer to the image as it is displayed.
+func (c *Client) Crop(ctx context.Context, r io.Reader, w io.Writer, spec CropSpec, format string, opts ...Option) error {
+	if r == nil || w == nil {
+		return fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
+	}
+
+	if err := spec.validate(); err != nil {
+		return err
+	}
+
+	return c.Pipeline(opts...).AutoOrient().CropTo(spec).Encode(format).Run(ctx, r, w)
+}
+
+// CropFile is like Crop but reads from inputPath and writes to outputPath
+func (c *Client) CropFile(ctx context.Context, inputPath, outputPath string, spec CropSpec, format string, opts ...Option) error {
+	if inputPath == "" || outputPath == "" {
+		return fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
+	}
+
+	if err := spec.validate(); err != nil {
+		return err
+	}
+
+	return c.Pipeline(opts...).AutoOrient().CropTo(spec).Encode(format).RunFile(ctx, inputPath, outputPath)
+}
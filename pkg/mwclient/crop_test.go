@@ -0,0 +1,147 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// TestParseGravity tests parsing gravity names
+func TestParseGravity(t *testing.T) {
+	tests := map[string]Gravity{
+		"":           GravityCenter,
+		"center":     GravityCenter,
+		"North":      GravityNorth,
+		"south_east": GravitySouthEast,
+		"north-west": GravityNorthWest,
+		"southwest":  GravitySouthWest,
+	}
+	for name, want := range tests {
+		got, err := ParseGravity(name)
+		if err != nil || got != want {
+			t.Errorf("ParseGravity(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseGravity("up"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for unknown gravity, got %v", err)
+	}
+}
+
+// TestCropOrigin tests crop placement by gravity and focal point
+func TestCropOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		gravity Gravity
+		focal   *FocalPoint
+		x, y    int
+	}{
+		{"center", GravityCenter, nil, 150, 50},
+		{"north", GravityNorth, nil, 150, 0},
+		{"southeast", GravitySouthEast, nil, 300, 100},
+		{"west", GravityWest, nil, 0, 50},
+		{"focal", GravityCenter, &FocalPoint{X: 0.25, Y: 0.5}, 50, 50},
+		{"focal clamped to left edge", GravityCenter, &FocalPoint{X: 0, Y: 0}, 0, 0},
+		{"focal clamped to bottom right", GravityCenter, &FocalPoint{X: 1, Y: 1}, 300, 100},
+		{"focal overrides gravity", GravityNorthWest, &FocalPoint{X: 0.5, Y: 0.5}, 150, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 100x100 region of a 400x200 image
+			x, y := cropOrigin(400, 200, 100, 100, tt.gravity, tt.focal)
+			if x != tt.x || y != tt.y {
+				t.Errorf("cropOrigin = %d,%d; want %d,%d", x, y, tt.x, tt.y)
+			}
+		})
+	}
+
+	// A region larger than the image is anchored at the origin
+	if x, y := cropOrigin(50, 50, 100, 100, GravitySouthEast, nil); x != 0 || y != 0 {
+		t.Errorf("expected 0,0 for oversized region, got %d,%d", x, y)
+	}
+}
+
+// TestCropSpecValidation tests that invalid crop specs are rejected
+func TestCropSpecValidation(t *testing.T) {
+	tests := []CropSpec{
+		{Height: 10},
+		{Width: 10},
+		{Width: 10, Height: 10, Gravity: Gravity(-1)},
+		{Width: 10, Height: 10, Focal: &FocalPoint{X: 1.5}},
+		{Width: 10, Height: 10, Focal: &FocalPoint{Y: math.NaN()}},
+	}
+	for _, spec := range tests {
+		if err := spec.validate(); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput for %+v, got %v", spec, err)
+		}
+	}
+
+	spec := ResizeSpec{Width: 10, Height: 10, Mode: ResizeFill, Focal: &FocalPoint{X: -0.1}}
+	if err := spec.validate(); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for resize focal point, got %v", err)
+	}
+}
+
+// TestFillGravity tests that ResizeFill crops according to gravity
+func TestFillGravity(t *testing.T) {
+	plan := planResize(400, 200, ResizeSpec{Width: 100, Height: 100, Mode: ResizeFill, Gravity: GravityEast})
+	if plan.cropX != 100 || plan.cropY != 0 {
+		t.Errorf("expected crop at 100,0; got %d,%d", plan.cropX, plan.cropY)
+	}
+
+	plan = planResize(400, 200, ResizeSpec{Width: 100, Height: 100, Mode: ResizeFill, Focal: &FocalPoint{X: 0.1, Y: 0.5}})
+	if plan.cropX != 0 || plan.cropY != 0 {
+		t.Errorf("expected crop at 0,0; got %d,%d", plan.cropX, plan.cropY)
+	}
+}
+
+// TestCrop tests cropping an image with gravity
+func TestCrop(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	// Left half black, right half white
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 100; x < 200; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	var src bytes.Buffer
+	if err := png.Encode(&src, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err := client.Crop(context.Background(), bytes.NewReader(src.Bytes()), &buf, CropSpec{Width: 50, Height: 50, Gravity: GravityEast}, "png")
+	if err != nil {
+		t.Fatalf("Crop failed: %v", err)
+	}
+
+	out, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if b := out.Bounds(); b.Dx() != 50 || b.Dy() != 50 {
+		t.Errorf("expected 50x50, got %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := out.At(25, 25).RGBA(); r < 0xf000 {
+		t.Errorf("expected the white east side to survive the crop")
+	}
+
+	err = client.CropFile(context.Background(), "input.png", "", CropSpec{Width: 10, Height: 10}, "")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with empty output path, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package mwclient
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// ChromaSubsampling is a JPEG chroma subsampling ratio
+type ChromaSubsampling string
+
+// Chroma subsampling ratios. The zero value keeps ImageMagick's default,
+// which depends on the quality.
+const (
+	SubsamplingDefault ChromaSubsampling = ""
+	Subsampling444     ChromaSubsampling = "4:4:4"
+	Subsampling422     ChromaSubsampling = "4:2:2"
+	Subsampling420     ChromaSubsampling = "4:2:0"
+)
+
+// JPEGOptions configures the JPEG encoder
+type JPEGOptions struct {
+	Quality         uint // 1-100; 0 uses the client quality
+	Progressive     bool
+	Subsampling     ChromaSubsampling
+	OptimizeHuffman bool // compute optimal Huffman tables
+}
+
+// PNGOptions configures the PNG encoder
+type PNGOptions struct {
+	CompressionLevel uint // zlib level 1-9; 0 derives it from the client quality
+	MaxColors        uint // quantize to at most this many colors; 0 keeps all
+	Interlace        bool // Adam7 interlacing
+}
+
+// WebPOptions configures the WebP encoder
+type WebPOptions struct {
+	Quality      uint // 1-100; 0 uses the client quality
+	Lossless     bool
+	Method       uint // speed/size trade-off 1-6, higher is smaller; 0 uses the default (4)
+	AlphaQuality uint // 1-100; 0 uses the default (100)
+}
+
+// AVIFOptions configures the AVIF encoder
+type AVIFOptions struct {
+	Quality uint // 1-100; 0 uses the client quality
+	Speed   uint // encoder speed 1-10, higher is faster; 0 uses the default
+}
+
+// validate checks the options for out-of-range values
+func (o JPEGOptions) validate() error {
+	if o.Quality > 100 {
+		return fmt.Errorf("%w: JPEG quality must be between 1 and 100", ErrInvalidInput)
+	}
+	switch o.Subsampling {
+	case SubsamplingDefault, Subsampling444, Subsampling422, Subsampling420:
+	default:
+		return fmt.Errorf("%w: unknown chroma subsampling %q", ErrInvalidInput, o.Subsampling)
+	}
+	return nil
+}
+
+// validate checks the options for out-of-range values
+func (o PNGOptions) validate() error {
+	if o.CompressionLevel > 9 {
+		return fmt.Errorf("%w: PNG compression level must be between 1 and 9", ErrInvalidInput)
+	}
+	return nil
+}
+
+// validate checks the options for out-of-range values
+func (o WebPOptions) validate() error {
+	if o.Quality > 100 || o.AlphaQuality > 100 {
+		return fmt.Errorf("%w: WebP quality must be between 1 and 100", ErrInvalidInput)
+	}
+	if o.Method > 6 {
+		return fmt.Errorf("%w: WebP method must be between 1 and 6", ErrInvalidInput)
+	}
+	return nil
+}
+
+// validate checks the options for out-of-range values
+func (o AVIFOptions) validate() error {
+	if o.Quality > 100 {
+		return fmt.Errorf("%w: AVIF quality must be between 1 and 100", ErrInvalidInput)
+	}
+	if o.Speed > 10 {
+		return fmt.Errorf("%w: AVIF speed must be between 1 and 10", ErrInvalidInput)
+	}
+	return nil
+}
+
+// formatFromPath returns the output format implied by the extension of path
+func formatFromPath(path string) string {
+	return strings.TrimPrefix(filepath.Ext(path), ".")
+}
+
+// applyEncoderOptions configures mw for encoding to format with the
+// per-format options in s. Other formats only get the client quality.
+func applyEncoderOptions(mw *imagick.MagickWand, format string, s *settings) error {
+	quality := s.quality
+	var options [][2]string
+
+	switch strings.ToUpper(format) {
+	case "JPEG", "JPG":
+		quality = cmp.Or(s.jpeg.Quality, quality)
+		if s.jpeg.Progressive {
+			if err := mw.SetImageInterlaceScheme(imagick.INTERLACE_PLANE); err != nil {
+				return fmt.Errorf("%w: failed to enable progressive JPEG: %v", ErrProcessing, err)
+			}
+		}
+		if s.jpeg.Subsampling != SubsamplingDefault {
+			options = append(options, [2]string{"jpeg:sampling-factor", string(s.jpeg.Subsampling)})
+		}
+		if s.jpeg.OptimizeHuffman {
+			options = append(options, [2]string{"jpeg:optimize-coding", "true"})
+		}
+
+	case "PNG":
+		if s.png.CompressionLevel != 0 {
+			options = append(options, [2]string{"png:compression-level", strconv.Itoa(int(s.png.CompressionLevel))})
+		}
+		if s.png.MaxColors != 0 {
+			if err := mw.QuantizeImage(s.png.MaxColors, imagick.COLORSPACE_SRGB, 0, imagick.DITHER_METHOD_NO, false); err != nil {
+				return fmt.Errorf("%w: failed to quantize image: %v", ErrProcessing, err)
+			}
+		}
+		if s.png.Interlace {
+			if err := mw.SetImageInterlaceScheme(imagick.INTERLACE_PNG); err != nil {
+				return fmt.Errorf("%w: failed to enable PNG interlacing: %v", ErrProcessing, err)
+			}
+		}
+
+	case "WEBP":
+		quality = cmp.Or(s.webp.Quality, quality)
+		if s.webp.Lossless {
+			options = append(options, [2]string{"webp:lossless", "true"})
+		}
+		if s.webp.Method != 0 {
+			options = append(options, [2]string{"webp:method", strconv.Itoa(int(s.webp.Method))})
+		}
+		if s.webp.AlphaQuality != 0 {
+			options = append(options, [2]string{"webp:alpha-quality", strconv.Itoa(int(s.webp.AlphaQuality))})
+		}
+
+	case "AVIF":
+		quality = cmp.Or(s.avif.Quality, quality)
+		if s.avif.Speed != 0 {
+			// ImageMagick encodes AVIF through its HEIC coder
+			options = append(options, [2]string{"heic:speed", strconv.Itoa(int(s.avif.Speed))})
+		}
+	}
+
+	// Set compression quality
+	if err := mw.SetImageCompressionQuality(quality); err != nil {
+		return fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
+	}
+
+	for _, opt := range options {
+		if err := mw.SetOption(opt[0], opt[1]); err != nil {
+			return fmt.Errorf("%w: failed to set %s: %v", ErrProcessing, opt[0], err)
+		}
+	}
+
+	return nil
+}
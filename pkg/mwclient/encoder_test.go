@@ -0,0 +1,99 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFormatFromPath tests deriving the output format from a file name
+func TestFormatFromPath(t *testing.T) {
+	tests := map[string]string{
+		"out.png":          "png",
+		"dir.v2/photo.JPG": "JPG",
+		"noext":            "",
+	}
+	for path, want := range tests {
+		if got := formatFromPath(path); got != want {
+			t.Errorf("formatFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// TestEncoderOptions tests that per-format encoder options reach the output
+func TestEncoderOptions(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	src := testPNG(t, 64, 48)
+
+	// Progressive JPEG uses a SOF2 frame header
+	var buf bytes.Buffer
+	err := client.ConvertFormatCtx(ctx, bytes.NewReader(src), &buf, "jpeg",
+		WithJPEGOptions(JPEGOptions{Quality: 80, Progressive: true, Subsampling: Subsampling444, OptimizeHuffman: true}))
+	if err != nil {
+		t.Fatalf("ConvertFormat to JPEG failed: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte{0xff, 0xc2}) {
+		t.Error("expected a progressive JPEG")
+	}
+
+	// Quantized, interlaced PNG
+	buf.Reset()
+	err = client.ConvertFormatCtx(ctx, bytes.NewReader(src), &buf, "png",
+		WithPNGOptions(PNGOptions{CompressionLevel: 9, MaxColors: 16, Interlace: true}))
+	if err != nil {
+		t.Fatalf("ConvertFormat to PNG failed: %v", err)
+	}
+	// The IHDR interlace method follows the signature, chunk header and 12 bytes of fields
+	if data := buf.Bytes(); len(data) < 29 || data[28] != 1 {
+		t.Error("expected an Adam7-interlaced PNG")
+	}
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if p, ok := img.(*image.Paletted); !ok || len(p.Palette) > 16 {
+		t.Errorf("expected a palette of at most 16 colors, got %T", img)
+	}
+
+	// Lossless WebP
+	buf.Reset()
+	err = client.ConvertFormatCtx(ctx, bytes.NewReader(src), &buf, "webp",
+		WithWebPOptions(WebPOptions{Lossless: true, Method: 6}))
+	if err != nil {
+		t.Fatalf("ConvertFormat to WebP failed: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("VP8L")) {
+		t.Error("expected a lossless WebP")
+	}
+
+	// Files only get the options of the output format, not of the input
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "in.png")
+	if err := os.WriteFile(inputPath, src, 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	outputPath := filepath.Join(dir, "out.jpg")
+	err = client.Pipeline(WithPNGOptions(PNGOptions{Interlace: true})).RunFile(ctx, inputPath, outputPath)
+	if err != nil {
+		t.Fatalf("RunFile to JPEG failed: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if bytes.Contains(data, []byte{0xff, 0xc2}) {
+		t.Error("expected PNG interlacing not to make the JPEG progressive")
+	}
+}
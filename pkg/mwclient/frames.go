@@ -0,0 +1,129 @@
+package mwclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Frame keeps only frame index (0 for the first) of an animated or
+// multi-page image, rendered as it appears in the animation, so the result
+// is a still image. Steps then run on that frame only.
+func (p *Pipeline) Frame(index int) *Pipeline {
+	p.frame = index
+	p.singleFrame = true
+	return p
+}
+
+// ExtractFrame writes frame index (0 for the first) of an animated image from
+// a reader as a still image. An empty format keeps the input format.
+func (c *Client) ExtractFrame(ctx context.Context, r io.Reader, w io.Writer, index int, format string, opts ...Option) error {
+	if r == nil || w == nil {
+		return fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
+	}
+
+	if index < 0 {
+		return fmt.Errorf("%w: frame index must not be negative", ErrInvalidInput)
+	}
+
+	return c.Pipeline(opts...).Frame(index).AutoOrient().Encode(format).Run(ctx, r, w)
+}
+
+// ExtractFrameFile is like ExtractFrame but reads from inputPath and writes
+// to outputPath
+func (c *Client) ExtractFrameFile(ctx context.Context, inputPath, outputPath string, index int, format string, opts ...Option) error {
+	if inputPath == "" || outputPath == "" {
+		return fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
+	}
+
+	if index < 0 {
+		return fmt.Errorf("%w: frame index must not be negative", ErrInvalidInput)
+	}
+
+	return c.Pipeline(opts...).Frame(index).AutoOrient().Encode(format).RunFile(ctx, inputPath, outputPath)
+}
+
+// animatedFormats lists the formats whose frames are layers of one
+// animation rather than independent pages
+var animatedFormats = map[string]bool{
+	"APNG": true,
+	"GIF":  true,
+	"MNG":  true,
+	"PNG":  true,
+	"WEBP": true,
+}
+
+// coalesceFrames returns mw with every frame of an animation rendered to a
+// full canvas, so frames can be transformed independently. Delays and the
+// loop count are kept. Single images and the independent pages of other
+// multi-page formats, such as TIFF, are returned unchanged; otherwise mw is
+// destroyed and replaced.
+func coalesceFrames(mw *imagick.MagickWand) (*imagick.MagickWand, error) {
+	if mw.GetNumberImages() <= 1 || !animatedFormats[strings.ToUpper(mw.GetImageFormat())] {
+		return mw, nil
+	}
+
+	coalesced := mw.CoalesceImages()
+	if coalesced == nil || coalesced.GetNumberImages() == 0 {
+		return mw, fmt.Errorf("%w: failed to coalesce frames", ErrProcessing)
+	}
+	mw.Destroy()
+	return coalesced, nil
+}
+
+// selectFrame replaces mw with a wand holding only frame index
+func selectFrame(mw *imagick.MagickWand, index int) (*imagick.MagickWand, error) {
+	n := int(mw.GetNumberImages())
+	if index < 0 || index >= n {
+		return mw, fmt.Errorf("%w: frame %d out of range, image has %d frames", ErrInvalidInput, index, n)
+	}
+	if n == 1 {
+		return mw, nil
+	}
+
+	mw.SetIteratorIndex(index)
+	frame := mw.GetImage()
+	if frame == nil {
+		return mw, fmt.Errorf("%w: failed to get frame %d", ErrProcessing, index)
+	}
+	mw.Destroy()
+	return frame, nil
+}
+
+// forEachFrame calls fn with mw positioned on each of its frames in turn.
+// Single images are not repositioned.
+func forEachFrame(mw *imagick.MagickWand, fn func() error) error {
+	n := int(mw.GetNumberImages())
+	if n <= 1 {
+		return fn()
+	}
+
+	defer mw.SetFirstIterator()
+	for i := 0; i < n; i++ {
+		mw.SetIteratorIndex(i)
+		if err := fn(); err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// optimizeFrames replaces the coalesced frames of a GIF animation with
+// optimized layers holding only what changes between frames. Other formats
+// are returned unchanged: animated WebP and AVIF encoders optimize full
+// frames themselves.
+func optimizeFrames(mw *imagick.MagickWand) (*imagick.MagickWand, error) {
+	if mw.GetNumberImages() <= 1 || !strings.EqualFold(mw.GetImageFormat(), "GIF") {
+		return mw, nil
+	}
+
+	optimized := mw.OptimizeImageLayers()
+	if optimized == nil || optimized.GetNumberImages() == 0 {
+		return mw, fmt.Errorf("%w: failed to optimize frames", ErrProcessing)
+	}
+	mw.Destroy()
+	return optimized, nil
+}
@@ -0,0 +1,138 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+// testGIF returns a width x height animated GIF with one solid frame per
+// color, each shown for the matching delay in 1/100 s, looping forever
+func testGIF(t *testing.T, width, height int, colors []color.Color, delays []int) []byte {
+	t.Helper()
+
+	anim := &gif.GIF{LoopCount: 0}
+	for i, c := range colors {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette.Plan9)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				frame.Set(x, y, c)
+			}
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delays[i])
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("Failed to encode test animation: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestAnimations tests frame-aware resizing, conversion and frame extraction
+func TestAnimations(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	colors := []color.Color{
+		color.RGBA{R: 255, A: 255},
+		color.RGBA{G: 255, A: 255},
+		color.RGBA{B: 255, A: 255},
+	}
+	delays := []int{10, 20, 30}
+	src := testGIF(t, 200, 100, colors, delays)
+
+	t.Run("Resize", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := client.Resize(ctx, bytes.NewReader(src), &buf, ResizeSpec{Width: 50, Mode: ResizeFit}, "")
+		if err != nil {
+			t.Fatalf("Resize failed: %v", err)
+		}
+
+		anim, err := gif.DecodeAll(&buf)
+		if err != nil {
+			t.Fatalf("Failed to decode result animation: %v", err)
+		}
+		if len(anim.Image) != len(colors) {
+			t.Fatalf("expected %d frames, got %d", len(colors), len(anim.Image))
+		}
+		if anim.Config.Width != 50 || anim.Config.Height != 25 {
+			t.Errorf("expected 50x25, got %dx%d", anim.Config.Width, anim.Config.Height)
+		}
+		for i, delay := range anim.Delay {
+			if delay != delays[i] {
+				t.Errorf("frame %d: expected delay %d, got %d", i, delays[i], delay)
+			}
+		}
+		if anim.LoopCount != 0 {
+			t.Errorf("expected infinite loop, got loop count %d", anim.LoopCount)
+		}
+	})
+
+	t.Run("WebP", func(t *testing.T) {
+		var webp bytes.Buffer
+		if err := client.ConvertFormatCtx(ctx, bytes.NewReader(src), &webp, "webp"); err != nil {
+			t.Fatalf("ConvertFormat to WebP failed: %v", err)
+		}
+		if !bytes.HasPrefix(webp.Bytes(), []byte("RIFF")) || !bytes.Contains(webp.Bytes(), []byte("ANIM")) {
+			t.Fatal("expected an animated WebP")
+		}
+
+		var back bytes.Buffer
+		if err := client.ConvertFormatCtx(ctx, &webp, &back, "gif"); err != nil {
+			t.Fatalf("ConvertFormat to GIF failed: %v", err)
+		}
+		anim, err := gif.DecodeAll(&back)
+		if err != nil {
+			t.Fatalf("Failed to decode result animation: %v", err)
+		}
+		if len(anim.Image) != len(colors) {
+			t.Errorf("expected %d frames, got %d", len(colors), len(anim.Image))
+		}
+	})
+
+	t.Run("ExtractFrame", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := client.ExtractFrame(ctx, bytes.NewReader(src), &buf, 1, "png"); err != nil {
+			t.Fatalf("ExtractFrame failed: %v", err)
+		}
+
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatalf("Failed to decode result image: %v", err)
+		}
+		if r, g, b, _ := img.At(100, 50).RGBA(); r>>8 > 16 || g>>8 < 240 || b>>8 > 16 {
+			t.Errorf("expected the green frame, got %d,%d,%d", r>>8, g>>8, b>>8)
+		}
+	})
+
+	t.Run("InvalidFrame", func(t *testing.T) {
+		for _, index := range []int{-1, len(colors)} {
+			err := client.ExtractFrame(ctx, bytes.NewReader(src), &bytes.Buffer{}, index, "png")
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("frame %d: expected ErrInvalidInput, got %v", index, err)
+			}
+		}
+	})
+
+	t.Run("SizeTarget", func(t *testing.T) {
+		_, err := client.EncodeToSize(ctx, bytes.NewReader(src), &bytes.Buffer{}, "webp", SizeTarget{MaxBytes: 1000})
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput for an animation, got %v", err)
+		}
+	})
+}
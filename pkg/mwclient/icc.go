@@ -0,0 +1,124 @@
+package mwclient
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// iccHeaderSize is the size of an ICC profile header
+const iccHeaderSize = 128
+
+// ColorManagement controls the color profile conversion of output images
+type ColorManagement struct {
+	// Convert transforms images from their embedded profile to Target.
+	// Untagged images are assumed to be sRGB; untagged CMYK and other
+	// color spaces are converted to sRGB numerically first.
+	Convert bool
+
+	// Target is the ICC profile to convert to. Nil means the bundled
+	// sRGB profile returned by SRGBProfile.
+	Target []byte
+
+	// Embed keeps the target profile in the output. Otherwise the output
+	// is untagged, which viewers treat as sRGB.
+	Embed bool
+}
+
+// validate checks that the target, if set, is an ICC profile
+func (cm ColorManagement) validate() error {
+	if cm.Target == nil {
+		return nil
+	}
+	if err := checkICC(cm.Target); err != nil {
+		return fmt.Errorf("%w: target profile: %v", ErrInvalidInput, err)
+	}
+	return nil
+}
+
+// target returns the profile images are converted to
+func (cm ColorManagement) target() []byte {
+	if cm.Target != nil {
+		return cm.Target
+	}
+	return srgbProfile()
+}
+
+// SRGBProfile returns a copy of the bundled sRGB ICC profile
+func SRGBProfile() []byte {
+	return bytes.Clone(srgbProfile())
+}
+
+// srgbICC is the sRGB IEC61966-2.1 display profile by Graeme W. Gill,
+// released into the public domain
+//
+//go:embed srgb.icc
+var srgbICC []byte
+
+// srgbProfile returns the bundled sRGB profile. Callers must not modify it.
+func srgbProfile() []byte {
+	return srgbICC
+}
+
+// checkICC reports whether profile has a valid ICC header
+func checkICC(profile []byte) error {
+	if len(profile) < iccHeaderSize {
+		return errors.New("profile too short")
+	}
+	if string(profile[36:40]) != "acsp" {
+		return errors.New("missing ICC signature")
+	}
+	if size := binary.BigEndian.Uint32(profile[0:4]); int(size) != len(profile) {
+		return fmt.Errorf("header size %d does not match profile size %d", size, len(profile))
+	}
+	return nil
+}
+
+// iccColorSpace returns the data color space recorded in an ICC profile
+// header, such as "RGB", "CMYK" or "GRAY", or "" if profile is not valid
+func iccColorSpace(profile []byte) string {
+	if len(profile) < iccHeaderSize || string(profile[36:40]) != "acsp" {
+		return ""
+	}
+	return strings.TrimSpace(string(profile[16:20]))
+}
+
+// convertColor transforms the current image in mw to the target profile
+func convertColor(mw *imagick.MagickWand, cm ColorManagement) error {
+	if !cm.Convert {
+		return nil
+	}
+
+	target := cm.target()
+	source := []byte(mw.GetImageProfile("icc"))
+
+	if len(source) == 0 {
+		if mw.GetImageColorspace() != imagick.COLORSPACE_SRGB {
+			if err := mw.TransformImageColorspace(imagick.COLORSPACE_SRGB); err != nil {
+				return fmt.Errorf("%w: failed to convert to sRGB: %v", ErrProcessing, err)
+			}
+		}
+
+		// Tag the image so the next call converts instead of assigning
+		source = srgbProfile()
+		if err := mw.ProfileImage("icc", source); err != nil {
+			return fmt.Errorf("%w: failed to assign sRGB profile: %v", ErrProcessing, err)
+		}
+	}
+
+	if !bytes.Equal(source, target) {
+		if err := mw.ProfileImage("icc", target); err != nil {
+			return fmt.Errorf("%w: failed to convert color profile: %v", ErrProcessing, err)
+		}
+	}
+
+	if !cm.Embed {
+		mw.RemoveImageProfile("icc")
+	}
+	return nil
+}
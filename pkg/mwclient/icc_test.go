@@ -0,0 +1,145 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// TestSRGBProfile tests the structure of the bundled sRGB profile
+func TestSRGBProfile(t *testing.T) {
+	profile := SRGBProfile()
+	if err := checkICC(profile); err != nil {
+		t.Fatalf("invalid profile: %v", err)
+	}
+	if cs := iccColorSpace(profile); cs != "RGB" {
+		t.Errorf("expected RGB color space, got %q", cs)
+	}
+
+	be := binary.BigEndian
+	tags := make(map[string][]byte)
+	count := int(be.Uint32(profile[iccHeaderSize:]))
+	for i := 0; i < count; i++ {
+		entry := profile[iccHeaderSize+4+12*i:]
+		offset, size := be.Uint32(entry[4:8]), be.Uint32(entry[8:12])
+		if offset%4 != 0 || int(offset+size) > len(profile) {
+			t.Fatalf("tag %s at %d+%d is misplaced", entry[:4], offset, size)
+		}
+		tags[string(entry[:4])] = profile[offset : offset+size]
+	}
+
+	for _, sig := range []string{"desc", "cprt", "wtpt", "rXYZ", "gXYZ", "bXYZ", "rTRC", "gTRC", "bTRC"} {
+		if tags[sig] == nil {
+			t.Errorf("missing %s tag", sig)
+		}
+	}
+
+	if desc := tags["desc"]; !bytes.Contains(desc, []byte("sRGB IEC61966-2.1")) {
+		t.Errorf("unexpected description %q", desc)
+	}
+
+	// The D50-adapted sRGB primaries
+	for sig, want := range map[string][3]float64{
+		"rXYZ": {0.4361, 0.2225, 0.0139},
+		"gXYZ": {0.3851, 0.7169, 0.0971},
+		"bXYZ": {0.1431, 0.0606, 0.7139},
+	} {
+		for i, v := range want {
+			got := float64(int32(be.Uint32(tags[sig][8+4*i:]))) / 65536
+			if math.Abs(got-v) > 0.0005 {
+				t.Errorf("%s[%d]: expected %.4f, got %.4f", sig, i, v, got)
+			}
+		}
+	}
+
+	curve := tags["rTRC"]
+	if !bytes.HasPrefix(curve, []byte("curv")) || !bytes.Equal(curve, tags["bTRC"]) {
+		t.Fatal("expected a shared curv tone curve")
+	}
+	points := be.Uint32(curve[8:12])
+	if first, last := be.Uint16(curve[12:]), be.Uint16(curve[12+2*(points-1):]); first != 0 || last != 65535 {
+		t.Errorf("expected the curve to span 0-65535, got %d-%d", first, last)
+	}
+
+	profile[0] = 0xff
+	if bytes.Equal(profile, SRGBProfile()) {
+		t.Error("SRGBProfile returned a shared slice")
+	}
+}
+
+// TestCheckICC tests ICC header validation
+func TestCheckICC(t *testing.T) {
+	valid := SRGBProfile()
+
+	truncated := valid[:len(valid)-4]
+	unsigned := bytes.Clone(valid)
+	copy(unsigned[36:], "xxxx")
+
+	for name, profile := range map[string][]byte{
+		"short":     []byte("acsp"),
+		"truncated": truncated,
+		"unsigned":  unsigned,
+	} {
+		if err := checkICC(profile); err == nil {
+			t.Errorf("expected an error for a %s profile", name)
+		}
+	}
+	if cs := iccColorSpace(unsigned); cs != "" {
+		t.Errorf("expected no color space for an invalid profile, got %q", cs)
+	}
+}
+
+// TestColorManagement tests converting an untagged CMYK image to sRGB
+func TestColorManagement(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	// Build an untagged CMYK JPEG
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+	if err := mw.ReadImageBlob(testPNG(t, 32, 32)); err != nil {
+		t.Fatalf("Failed to read test image: %v", err)
+	}
+	if err := mw.TransformImageColorspace(imagick.COLORSPACE_CMYK); err != nil {
+		t.Fatalf("Failed to convert test image to CMYK: %v", err)
+	}
+	if err := mw.SetImageFormat("jpeg"); err != nil {
+		t.Fatalf("Failed to set format: %v", err)
+	}
+	src, err := mw.GetImageBlob()
+	if err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	client := New()
+	defer client.Close()
+	ctx := context.Background()
+
+	for _, embed := range []bool{false, true} {
+		cm := ColorManagement{Convert: true, Embed: embed}
+
+		var buf bytes.Buffer
+		err := client.Pipeline(WithColorManagement(cm)).Encode("jpeg").Run(ctx, bytes.NewReader(src), &buf)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		out := imagick.NewMagickWand()
+		if err := out.ReadImageBlob(buf.Bytes()); err != nil {
+			t.Fatalf("Failed to read result image: %v", err)
+		}
+		if cs := out.GetImageColorspace(); cs != imagick.COLORSPACE_SRGB {
+			t.Errorf("embed %v: expected sRGB output, got %v", embed, cs)
+		}
+		if icc := []byte(out.GetImageProfile("icc")); embed != bytes.Equal(icc, SRGBProfile()) {
+			t.Errorf("embed %v: unexpected output profile of %d bytes", embed, len(icc))
+		}
+		out.Destroy()
+	}
+}
@@ -0,0 +1,231 @@
+package mwclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// IPTC-IIM framing
+const (
+	iptcTagMarker      = 0x1c
+	iptcResourceID     = 0x0404 // Photoshop image resource holding IPTC-IIM
+	iptcApplicationRec = 2
+)
+
+// IPTC holds the commonly used fields of an IPTC-IIM application record
+type IPTC struct {
+	ObjectName    string    // 2:05
+	Keywords      []string  // 2:25
+	Created       time.Time // 2:55 and 2:60
+	Byline        []string  // 2:80
+	BylineTitle   string    // 2:85
+	City          string    // 2:90
+	Sublocation   string    // 2:92
+	ProvinceState string    // 2:95
+	CountryCode   string    // 2:100
+	Country       string    // 2:101
+	Headline      string    // 2:105
+	Credit        string    // 2:110
+	Source        string    // 2:115
+	Copyright     string    // 2:116
+	Caption       string    // 2:120
+	CaptionWriter string    // 2:122
+
+	// Datasets holds every dataset in the order read, including the ones
+	// not mapped to a field above
+	Datasets []IPTCDataset
+}
+
+// IPTCDataset is a single raw IPTC-IIM dataset
+type IPTCDataset struct {
+	Record uint8
+	Number uint8
+	Value  []byte
+}
+
+// parseIPTC parses IPTC-IIM data, either bare or wrapped in Photoshop
+// image resources
+func parseIPTC(data []byte) (IPTC, error) {
+	var iptc IPTC
+
+	if bytes.HasPrefix(data, []byte("8BIM")) {
+		iim, err := photoshopResource(data, iptcResourceID)
+		if err != nil {
+			return iptc, err
+		}
+		data = iim
+	}
+
+	datasets, err := parseIIM(data)
+	if err != nil {
+		return iptc, err
+	}
+	iptc.Datasets = datasets
+
+	var date, clock string
+	for _, ds := range datasets {
+		if ds.Record != iptcApplicationRec {
+			continue
+		}
+
+		value := iptcString(ds.Value)
+		switch ds.Number {
+		case 5:
+			iptc.ObjectName = value
+		case 25:
+			iptc.Keywords = append(iptc.Keywords, value)
+		case 55:
+			date = value
+		case 60:
+			clock = value
+		case 80:
+			iptc.Byline = append(iptc.Byline, value)
+		case 85:
+			iptc.BylineTitle = value
+		case 90:
+			iptc.City = value
+		case 92:
+			iptc.Sublocation = value
+		case 95:
+			iptc.ProvinceState = value
+		case 100:
+			iptc.CountryCode = value
+		case 101:
+			iptc.Country = value
+		case 105:
+			iptc.Headline = value
+		case 110:
+			iptc.Credit = value
+		case 115:
+			iptc.Source = value
+		case 116:
+			iptc.Copyright = value
+		case 120:
+			iptc.Caption = value
+		case 122:
+			iptc.CaptionWriter = value
+		}
+	}
+	iptc.Created = iptcTime(date, clock)
+
+	return iptc, nil
+}
+
+// parseIIM splits IPTC-IIM data into datasets. Trailing zero padding, as
+// written by some TIFF encoders, is ignored.
+func parseIIM(data []byte) ([]IPTCDataset, error) {
+	var datasets []IPTCDataset
+
+	for len(data) > 0 {
+		if data[0] != iptcTagMarker {
+			if len(bytes.Trim(data, "\x00")) == 0 {
+				break
+			}
+			return nil, fmt.Errorf("invalid IPTC tag marker 0x%02x", data[0])
+		}
+		if len(data) < 5 {
+			return nil, errors.New("truncated IPTC dataset header")
+		}
+
+		record, number := data[1], data[2]
+		size := int(binary.BigEndian.Uint16(data[3:5]))
+		data = data[5:]
+
+		// Extended datasets store the size of the length field instead
+		if size&0x8000 != 0 {
+			n := size & 0x7fff
+			if n == 0 || n > 4 || len(data) < n {
+				return nil, errors.New("invalid IPTC extended length")
+			}
+			size = 0
+			for _, b := range data[:n] {
+				size = size<<8 | int(b)
+			}
+			data = data[n:]
+		}
+
+		if size > len(data) {
+			return nil, fmt.Errorf("IPTC dataset %d:%d overruns the data", record, number)
+		}
+		datasets = append(datasets, IPTCDataset{Record: record, Number: number, Value: data[:size]})
+		data = data[size:]
+	}
+
+	return datasets, nil
+}
+
+// photoshopResource returns the data of the Photoshop image resource with
+// the given ID
+func photoshopResource(data []byte, id uint16) ([]byte, error) {
+	for len(data) >= 12 && bytes.HasPrefix(data, []byte("8BIM")) {
+		rid := binary.BigEndian.Uint16(data[4:6])
+
+		// The Pascal-string name is padded to an even length
+		nameLen := int(data[6]) + 1
+		nameLen += nameLen & 1
+		header := 6 + nameLen
+		if len(data) < header+4 {
+			break
+		}
+
+		size := int(binary.BigEndian.Uint32(data[header : header+4]))
+		start := header + 4
+		if size > len(data)-start {
+			return nil, errors.New("Photoshop resource overruns the data")
+		}
+		if rid == id {
+			return data[start : start+size], nil
+		}
+
+		// Resource data is padded to an even length too
+		next := start + size + size&1
+		if next > len(data) {
+			break
+		}
+		data = data[next:]
+	}
+
+	return nil, fmt.Errorf("no Photoshop resource 0x%04x", id)
+}
+
+// iptcString decodes an IPTC text value. IIM predates UTF-8, so values that
+// are not valid UTF-8 are decoded as Latin-1.
+func iptcString(b []byte) string {
+	if utf8.Valid(b) {
+		return strings.TrimRight(string(b), "\x00")
+	}
+
+	runes := make([]rune, 0, len(b))
+	for _, c := range b {
+		runes = append(runes, rune(c))
+	}
+	return strings.TrimRight(string(runes), "\x00")
+}
+
+// iptcTime combines the CCYYMMDD date and HHMMSS±HHMM time datasets. The
+// time defaults to midnight UTC when missing.
+func iptcTime(date, clock string) time.Time {
+	if date == "" {
+		return time.Time{}
+	}
+
+	if clock != "" {
+		if t, err := time.Parse("20060102 150405-0700", date+" "+clock); err == nil {
+			return t
+		}
+		if t, err := time.Parse("20060102 150405", date+" "+clock); err == nil {
+			return t
+		}
+	}
+
+	t, err := time.Parse("20060102", date)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
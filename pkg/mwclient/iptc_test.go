@@ -0,0 +1,121 @@
+package mwclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// iimDataset encodes a single IPTC-IIM dataset
+func iimDataset(record, number uint8, value string) []byte {
+	b := []byte{iptcTagMarker, record, number, 0, 0}
+	binary.BigEndian.PutUint16(b[3:], uint16(len(value)))
+	return append(b, value...)
+}
+
+// TestParseIPTC tests parsing bare IPTC-IIM data into typed fields
+func TestParseIPTC(t *testing.T) {
+	var data []byte
+	data = append(data, iimDataset(1, 90, "\x1b%G")...)
+	data = append(data, iimDataset(2, 5, "Harbour")...)
+	data = append(data, iimDataset(2, 25, "boats")...)
+	data = append(data, iimDataset(2, 25, "sunset")...)
+	data = append(data, iimDataset(2, 55, "20240315")...)
+	data = append(data, iimDataset(2, 60, "183000+0100")...)
+	data = append(data, iimDataset(2, 80, "Jane Doe")...)
+	data = append(data, iimDataset(2, 101, "Norway")...)
+	data = append(data, iimDataset(2, 116, "© 2024 Jane Doe")...)
+	data = append(data, iimDataset(2, 120, "Boats at dusk")...)
+	data = append(data, 0, 0, 0)
+
+	iptc, err := parseIPTC(data)
+	if err != nil {
+		t.Fatalf("parseIPTC failed: %v", err)
+	}
+
+	if iptc.ObjectName != "Harbour" || iptc.Country != "Norway" || iptc.Caption != "Boats at dusk" {
+		t.Errorf("unexpected text fields: %+v", iptc)
+	}
+	if iptc.Copyright != "© 2024 Jane Doe" {
+		t.Errorf("expected UTF-8 copyright, got %q", iptc.Copyright)
+	}
+	if len(iptc.Keywords) != 2 || iptc.Keywords[0] != "boats" || iptc.Keywords[1] != "sunset" {
+		t.Errorf("expected keywords [boats sunset], got %v", iptc.Keywords)
+	}
+	if len(iptc.Byline) != 1 || iptc.Byline[0] != "Jane Doe" {
+		t.Errorf("expected byline [Jane Doe], got %v", iptc.Byline)
+	}
+	want := time.Date(2024, 3, 15, 18, 30, 0, 0, time.FixedZone("", 3600))
+	if !iptc.Created.Equal(want) {
+		t.Errorf("expected created %v, got %v", want, iptc.Created)
+	}
+	if len(iptc.Datasets) != 10 {
+		t.Errorf("expected 10 raw datasets, got %d", len(iptc.Datasets))
+	}
+}
+
+// TestParseIPTCPhotoshop tests extracting IPTC-IIM from Photoshop image
+// resources
+func TestParseIPTCPhotoshop(t *testing.T) {
+	resource := func(id uint16, data []byte) []byte {
+		b := []byte("8BIM")
+		b = binary.BigEndian.AppendUint16(b, id)
+		b = append(b, 0, 0) // empty name, padded
+		b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+		b = append(b, data...)
+		if len(data)%2 == 1 {
+			b = append(b, 0)
+		}
+		return b
+	}
+
+	iim := iimDataset(2, 105, "Headline")
+	var data []byte
+	data = append(data, resource(0x03ed, []byte{1, 2, 3})...)
+	data = append(data, resource(iptcResourceID, iim)...)
+
+	iptc, err := parseIPTC(data)
+	if err != nil {
+		t.Fatalf("parseIPTC failed: %v", err)
+	}
+	if iptc.Headline != "Headline" {
+		t.Errorf("expected headline, got %q", iptc.Headline)
+	}
+
+	if _, err := parseIPTC(resource(0x03ed, []byte{1, 2, 3})); err == nil {
+		t.Error("expected an error without an IPTC resource")
+	}
+}
+
+// TestParseIIM tests extended lengths, Latin-1 values and malformed data
+func TestParseIIM(t *testing.T) {
+	value := bytes.Repeat([]byte("x"), 40000)
+	extended := []byte{iptcTagMarker, 2, 120, 0x80, 0x04}
+	extended = binary.BigEndian.AppendUint32(extended, uint32(len(value)))
+	extended = append(extended, value...)
+
+	datasets, err := parseIIM(extended)
+	if err != nil {
+		t.Fatalf("parseIIM failed: %v", err)
+	}
+	if len(datasets) != 1 || len(datasets[0].Value) != len(value) {
+		t.Errorf("expected one %d byte dataset, got %d datasets", len(value), len(datasets))
+	}
+
+	if got := iptcString([]byte("Z\xfcrich")); got != "Zürich" {
+		t.Errorf("expected Latin-1 decoding, got %q", got)
+	}
+
+	invalid := [][]byte{
+		{0x1d, 2, 5, 0, 1, 'x'},
+		{iptcTagMarker, 2, 5},
+		{iptcTagMarker, 2, 5, 0, 10, 'x'},
+		{iptcTagMarker, 2, 5, 0x80, 0x00},
+	}
+	for _, data := range invalid {
+		if _, err := parseIIM(data); err == nil {
+			t.Errorf("expected an error for % x", data)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package mwclient
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// exifTimeLayout is the layout of EXIF date/time tags
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// GPSCoordinates is a location in decimal degrees. Southern latitudes and
+// western longitudes are negative. Altitude is in meters above sea level.
+type GPSCoordinates struct {
+	Latitude    float64
+	Longitude   float64
+	Altitude    float64
+	HasAltitude bool
+}
+
+// Resolution is an image resolution in dots per inch. Zero values mean the
+// image does not record a resolution.
+type Resolution struct {
+	X float64
+	Y float64
+}
+
+// colorSpaceNames maps ImageMagick colorspaces to the names used in ImageMeta
+var colorSpaceNames = map[imagick.ColorspaceType]string{
+	imagick.COLORSPACE_CMY:   "CMY",
+	imagick.COLORSPACE_CMYK:  "CMYK",
+	imagick.COLORSPACE_GRAY:  "Gray",
+	imagick.COLORSPACE_HSB:   "HSB",
+	imagick.COLORSPACE_HSL:   "HSL",
+	imagick.COLORSPACE_LAB:   "Lab",
+	imagick.COLORSPACE_RGB:   "RGB",
+	imagick.COLORSPACE_SRGB:  "sRGB",
+	imagick.COLORSPACE_XYZ:   "XYZ",
+	imagick.COLORSPACE_YCBCR: "YCbCr",
+	imagick.COLORSPACE_YUV:   "YUV",
+}
+
+// colorSpaceName returns the name of cs, or "Undefined" if it is unknown
+func colorSpaceName(cs imagick.ColorspaceType) string {
+	if name, ok := colorSpaceNames[cs]; ok {
+		return name
+	}
+	return "Undefined"
+}
+
+// readMeta extracts the metadata of the image in mw. Malformed optional
+// metadata is logged and left empty rather than failing the read.
+func readMeta(mw *imagick.MagickWand, logger *slog.Logger) ImageMeta {
+	// Enumerate the pages first; this leaves mw on the first page
+	pages := readPages(mw)
+
+	meta := ImageMeta{
+		FormatName:      mw.GetImageFormat(),
+		ImageWidth:      int32(mw.GetImageWidth()),
+		ImageHeight:     int32(mw.GetImageHeight()),
+		ExifOrientation: int16(mw.GetOrientation()),
+
+		ColorSpace: colorSpaceName(mw.GetImageColorspace()),
+		BitDepth:   mw.GetImageDepth(),
+		FrameCount: int(mw.GetNumberImages()),
+		HasAlpha:   mw.GetImageAlphaChannel(),
+		Pages:      pages,
+	}
+
+	if cl, err := mw.GetImageLength(); err == nil {
+		meta.ContentLength = int64(cl)
+	}
+
+	if x, y, err := mw.GetImageResolution(); err == nil {
+		meta.DPI = dotsPerInch(x, y, mw.GetImageUnits())
+	}
+
+	if icc := mw.GetImageProfile("icc"); icc != "" {
+		meta.ICCProfile = mw.GetImageProperty("icc:description")
+		meta.ICCColorSpace = iccColorSpace([]byte(icc))
+	}
+
+	// EXIF
+	meta.EXIF = make(map[string]string)
+	for _, name := range mw.GetImageProperties("exif:*") {
+		meta.EXIF[strings.TrimPrefix(name, "exif:")] = mw.GetImageProperty(name)
+	}
+	meta.Make = strings.TrimSpace(meta.EXIF["Make"])
+	meta.Model = strings.TrimSpace(meta.EXIF["Model"])
+	meta.CaptureTime = captureTime(meta.EXIF)
+
+	gps, err := parseGPS(meta.EXIF)
+	if err != nil {
+		logger.Warn("Ignoring malformed GPS metadata", "error", err)
+	}
+	meta.GPS = gps
+
+	// IPTC and XMP
+	if profile := mw.GetImageProfile("iptc"); profile != "" {
+		iptc, err := parseIPTC([]byte(profile))
+		if err != nil {
+			logger.Warn("Ignoring malformed IPTC metadata", "error", err)
+		} else {
+			meta.IPTC = &iptc
+		}
+	}
+	if profile := mw.GetImageProfile("xmp"); profile != "" {
+		meta.XMP = []byte(profile)
+	}
+
+	return meta
+}
+
+// dotsPerInch converts a resolution in the given units to DPI
+func dotsPerInch(x, y float64, units imagick.ResolutionType) Resolution {
+	switch units {
+	case imagick.RESOLUTION_PIXELS_PER_INCH:
+		return Resolution{X: x, Y: y}
+	case imagick.RESOLUTION_PIXELS_PER_CENTIMETER:
+		return Resolution{X: x * 2.54, Y: y * 2.54}
+	default:
+		// Without units the values are only an aspect ratio
+		return Resolution{}
+	}
+}
+
+// captureTime returns the time the image was taken, trying the original,
+// digitized and modification times in turn. EXIF only records the UTC
+// offset in its OffsetTime tags; without one the time is returned in UTC.
+func captureTime(exif map[string]string) time.Time {
+	tags := []struct{ value, offset string }{
+		{"DateTimeOriginal", "OffsetTimeOriginal"},
+		{"DateTimeDigitized", "OffsetTimeDigitized"},
+		{"DateTime", "OffsetTime"},
+	}
+
+	for _, tag := range tags {
+		value := strings.TrimSpace(exif[tag.value])
+		if value == "" {
+			continue
+		}
+
+		loc := time.UTC
+		if offset, err := time.Parse("-07:00", strings.TrimSpace(exif[tag.offset])); err == nil {
+			_, seconds := offset.Zone()
+			loc = time.FixedZone("", seconds)
+		}
+
+		t, err := time.ParseInLocation(exifTimeLayout, value, loc)
+		if err != nil {
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// parseGPS returns the coordinates in the EXIF GPS tags, or nil if the image
+// has no latitude and longitude
+func parseGPS(exif map[string]string) (*GPSCoordinates, error) {
+	if exif["GPSLatitude"] == "" || exif["GPSLongitude"] == "" {
+		return nil, nil
+	}
+
+	lat, err := parseDegrees(exif["GPSLatitude"], exif["GPSLatitudeRef"], "S")
+	if err != nil {
+		return nil, fmt.Errorf("latitude: %v", err)
+	}
+	lon, err := parseDegrees(exif["GPSLongitude"], exif["GPSLongitudeRef"], "W")
+	if err != nil {
+		return nil, fmt.Errorf("longitude: %v", err)
+	}
+	if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return nil, fmt.Errorf("coordinates %g, %g out of range", lat, lon)
+	}
+
+	gps := &GPSCoordinates{Latitude: lat, Longitude: lon}
+	if alt, err := parseRational(exif["GPSAltitude"]); err == nil {
+		// A reference of 1 means below sea level
+		if strings.TrimSpace(exif["GPSAltitudeRef"]) == "1" {
+			alt = -alt
+		}
+		gps.Altitude = alt
+		gps.HasAltitude = true
+	}
+
+	return gps, nil
+}
+
+// parseDegrees converts a "degrees, minutes, seconds" list of rationals to
+// decimal degrees, negated if ref equals negativeRef
+func parseDegrees(value, ref, negativeRef string) (float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) == 0 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+
+	var degrees float64
+	for i, part := range parts {
+		v, err := parseRational(part)
+		if err != nil {
+			return 0, err
+		}
+		degrees += v / math.Pow(60, float64(i))
+	}
+
+	if strings.EqualFold(strings.TrimSpace(ref), negativeRef) {
+		degrees = -degrees
+	}
+	return degrees, nil
+}
+
+// parseRational parses an EXIF rational such as "1234/100", or a plain
+// decimal number
+func parseRational(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+
+	num, den, ok := strings.Cut(value, "/")
+	if !ok {
+		return strconv.ParseFloat(value, 64)
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil {
+		return 0, err
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(den), 64)
+	if err != nil {
+		return 0, err
+	}
+	if d == 0 {
+		return 0, fmt.Errorf("zero denominator in %q", value)
+	}
+	return n / d, nil
+}
@@ -0,0 +1,130 @@
+package mwclient
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// TestCaptureTime tests reading the capture time from EXIF tags
+func TestCaptureTime(t *testing.T) {
+	tests := []struct {
+		name string
+		exif map[string]string
+		want time.Time
+	}{
+		{"none", map[string]string{}, time.Time{}},
+		{
+			"original with offset",
+			map[string]string{"DateTimeOriginal": "2023:07:01 12:34:56", "OffsetTimeOriginal": "+02:00"},
+			time.Date(2023, 7, 1, 12, 34, 56, 0, time.FixedZone("", 2*3600)),
+		},
+		{
+			"original without offset",
+			map[string]string{"DateTimeOriginal": "2023:07:01 12:34:56"},
+			time.Date(2023, 7, 1, 12, 34, 56, 0, time.UTC),
+		},
+		{
+			"falls back to modification time",
+			map[string]string{"DateTimeOriginal": "0000:00:00 00:00:00", "DateTime": "2020:01:02 03:04:05"},
+			time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := captureTime(tt.exif); !got.Equal(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestParseGPS tests converting EXIF GPS tags to decimal degrees
+func TestParseGPS(t *testing.T) {
+	gps, err := parseGPS(map[string]string{
+		"GPSLatitude":     "33/1, 51/1, 5400/100",
+		"GPSLatitudeRef":  "S",
+		"GPSLongitude":    "151/1, 12/1, 3000/100",
+		"GPSLongitudeRef": "E",
+		"GPSAltitude":     "125/10",
+		"GPSAltitudeRef":  "1",
+	})
+	if err != nil {
+		t.Fatalf("parseGPS failed: %v", err)
+	}
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+	if !near(gps.Latitude, -33.865) || !near(gps.Longitude, 151.208333) {
+		t.Errorf("expected -33.865, 151.208333, got %f, %f", gps.Latitude, gps.Longitude)
+	}
+	if !gps.HasAltitude || !near(gps.Altitude, -12.5) {
+		t.Errorf("expected altitude -12.5, got %f", gps.Altitude)
+	}
+
+	gps, err = parseGPS(map[string]string{"Make": "Canon"})
+	if gps != nil || err != nil {
+		t.Errorf("expected no coordinates, got %v, %v", gps, err)
+	}
+
+	_, err = parseGPS(map[string]string{"GPSLatitude": "1/0, 0/1, 0/1", "GPSLongitude": "1/1"})
+	if err == nil {
+		t.Error("expected an error for a zero denominator")
+	}
+	_, err = parseGPS(map[string]string{"GPSLatitude": "95/1", "GPSLongitude": "1/1"})
+	if err == nil {
+		t.Error("expected an error for an out-of-range latitude")
+	}
+}
+
+// TestDotsPerInch tests resolution unit conversion
+func TestDotsPerInch(t *testing.T) {
+	if got := dotsPerInch(300, 300, imagick.RESOLUTION_PIXELS_PER_INCH); got != (Resolution{300, 300}) {
+		t.Errorf("expected 300 DPI, got %v", got)
+	}
+	if got := dotsPerInch(100, 50, imagick.RESOLUTION_PIXELS_PER_CENTIMETER); got != (Resolution{254, 127}) {
+		t.Errorf("expected 254x127 DPI, got %v", got)
+	}
+	if got := dotsPerInch(72, 72, imagick.RESOLUTION_UNDEFINED); got != (Resolution{}) {
+		t.Errorf("expected no resolution without units, got %v", got)
+	}
+}
+
+// TestOpenImageMetadata tests the extended metadata returned by OpenImage
+func TestOpenImageMetadata(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	path := filepath.Join(t.TempDir(), "input.png")
+	if err := os.WriteFile(path, testPNG(t, 40, 20), 0o644); err != nil {
+		t.Fatalf("Failed to write test image: %v", err)
+	}
+
+	meta, err := client.OpenImage(path)
+	if err != nil {
+		t.Fatalf("OpenImage failed: %v", err)
+	}
+
+	if meta.ImageWidth != 40 || meta.ImageHeight != 20 {
+		t.Errorf("expected 40x20, got %dx%d", meta.ImageWidth, meta.ImageHeight)
+	}
+	if meta.FrameCount != 1 || meta.BitDepth != 8 || meta.HasAlpha {
+		t.Errorf("expected one opaque 8-bit frame, got %d frames, depth %d, alpha %v",
+			meta.FrameCount, meta.BitDepth, meta.HasAlpha)
+	}
+	if meta.ColorSpace != "sRGB" {
+		t.Errorf("expected sRGB, got %q", meta.ColorSpace)
+	}
+	if meta.GPS != nil || meta.IPTC != nil || meta.XMP != nil {
+		t.Errorf("expected no GPS, IPTC or XMP metadata, got %+v", meta)
+	}
+}
@@ -0,0 +1,231 @@
+package mwclient
+
+import (
+	"cmp"
+	"fmt"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Page shadow geometry, in pixels
+const (
+	shadowOpacity = 50 // percent
+	shadowSigma   = 4
+	shadowOffset  = 6
+)
+
+// DefaultBorderColor is the color of page borders in a montage
+const DefaultBorderColor = "gray"
+
+// MontageLayout arranges the pages of a PDF montage. The zero value stacks
+// the pages in a single column without spacing.
+type MontageLayout struct {
+	// Columns and Rows set the grid. With only Columns, rows are added as
+	// needed, and with only Rows, columns are: Rows 1 is a horizontal strip.
+	// With both, as for a 3x2 contact sheet, pages that do not fit continue
+	// on further sheets.
+	Columns int
+	Rows    int
+
+	// Spacing is the gutter between pages in pixels. Half of it also
+	// surrounds the sheet, so odd values are rounded down.
+	Spacing int
+
+	// Background fills the gutters and the space around smaller pages.
+	// Empty uses the client's background color.
+	Background string
+
+	// Border is the width in pixels of a border drawn around every page in
+	// BorderColor; an empty BorderColor uses DefaultBorderColor
+	Border      int
+	BorderColor string
+
+	// Shadow drops a soft shadow below and to the right of every page
+	Shadow bool
+
+	// Captions labels every page with its page number in the document
+	Captions bool
+
+	// MaxWidth and MaxHeight bound the size of a sheet. Larger sheets are
+	// scaled down to fit, keeping their aspect ratio; 0 means no limit.
+	MaxWidth  int
+	MaxHeight int
+}
+
+// validate checks the layout for negative values
+func (l MontageLayout) validate() error {
+	if err := l.check(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return nil
+}
+
+// check is like validate but returns an error without the sentinel
+func (l MontageLayout) check() error {
+	for _, v := range []struct {
+		name  string
+		value int
+	}{
+		{"columns", l.Columns},
+		{"rows", l.Rows},
+		{"spacing", l.Spacing},
+		{"border", l.Border},
+		{"max width", l.MaxWidth},
+		{"max height", l.MaxHeight},
+	} {
+		if v.value < 0 {
+			return fmt.Errorf("montage %s must not be negative", v.name)
+		}
+	}
+	return nil
+}
+
+// tile returns the ImageMagick tile geometry of the grid
+func (l MontageLayout) tile() string {
+	switch {
+	case l.Columns == 0 && l.Rows == 0:
+		return "1x" // Stack vertically
+	case l.Rows == 0:
+		return fmt.Sprintf("%dx", l.Columns)
+	case l.Columns == 0:
+		return fmt.Sprintf("x%d", l.Rows)
+	default:
+		return fmt.Sprintf("%dx%d", l.Columns, l.Rows)
+	}
+}
+
+// decoratePage prepares a flattened page for the montage: it draws the
+// border and shadow and sets the caption of page number
+func decoratePage(mw *imagick.MagickWand, layout MontageLayout, number int) error {
+	if layout.Border > 0 {
+		color := imagick.NewPixelWand()
+		defer color.Destroy()
+		color.SetColor(cmp.Or(layout.BorderColor, DefaultBorderColor))
+
+		border := uint(layout.Border)
+		if err := mw.BorderImage(color, border, border, imagick.COMPOSITE_OP_OVER); err != nil {
+			return fmt.Errorf("%w: failed to draw page border: %v", ErrProcessing, err)
+		}
+	}
+
+	if layout.Shadow {
+		if err := addShadow(mw); err != nil {
+			return err
+		}
+	}
+
+	// The montage prints each image's label below it
+	if layout.Captions {
+		if err := mw.SetImageProperty("label", fmt.Sprintf("Page %d", number)); err != nil {
+			return fmt.Errorf("%w: failed to set page caption: %v", ErrProcessing, err)
+		}
+	}
+	return nil
+}
+
+// addShadow drops a soft shadow below and to the right of the image in mw,
+// growing the image to make room for it
+func addShadow(mw *imagick.MagickWand) error {
+	shadow := mw.Clone()
+	defer shadow.Destroy()
+
+	black := imagick.NewPixelWand()
+	defer black.Destroy()
+	black.SetColor("black")
+
+	if err := shadow.SetImageBackgroundColor(black); err != nil {
+		return fmt.Errorf("%w: failed to set shadow color: %v", ErrProcessing, err)
+	}
+	if err := shadow.ShadowImage(shadowOpacity, shadowSigma, shadowOffset, shadowOffset); err != nil {
+		return fmt.Errorf("%w: failed to cast shadow: %v", ErrProcessing, err)
+	}
+
+	none := imagick.NewPixelWand()
+	defer none.Destroy()
+	none.SetColor("none")
+
+	if err := mw.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_SET); err != nil {
+		return fmt.Errorf("%w: failed to add alpha channel: %v", ErrProcessing, err)
+	}
+	if err := mw.SetImageBackgroundColor(none); err != nil {
+		return fmt.Errorf("%w: failed to set background color: %v", ErrProcessing, err)
+	}
+
+	// The shadow extends 2 sigma past the page on every side before it is
+	// offset; only the part below and to the right is kept
+	margin := uint(shadowOffset + 2*shadowSigma)
+	if err := mw.ExtentImage(mw.GetImageWidth()+margin, mw.GetImageHeight()+margin, 0, 0); err != nil {
+		return fmt.Errorf("%w: failed to make room for shadow: %v", ErrProcessing, err)
+	}
+	offset := shadowOffset - 2*shadowSigma
+	if err := mw.CompositeImage(shadow, imagick.COMPOSITE_OP_DST_OVER, false, offset, offset); err != nil {
+		return fmt.Errorf("%w: failed to draw shadow: %v", ErrProcessing, err)
+	}
+	return nil
+}
+
+// montagePdfPages arranges the decorated pages in mw into a new wand with
+// one image per sheet, flattened onto the background and scaled down to
+// the layout's maximum size
+func montagePdfPages(mw *imagick.MagickWand, layout MontageLayout, s *settings) (*imagick.MagickWand, error) {
+	if mw.GetNumberImages() == 0 {
+		return nil, fmt.Errorf("%w: no pages to combine", ErrProcessing)
+	}
+
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	bg.SetColor(cmp.Or(layout.Background, s.background))
+
+	// The montage fills the sheet with the wand's background color
+	if err := mw.SetBackgroundColor(bg); err != nil {
+		return nil, fmt.Errorf("%w: failed to set background color: %v", ErrProcessing, err)
+	}
+
+	// Create a drawing wand for the captions, sized to the tallest page
+	dw := imagick.NewDrawingWand()
+	defer dw.Destroy()
+
+	var height uint
+	forEachFrame(mw, func() error {
+		height = max(height, mw.GetImageHeight())
+		return nil
+	})
+	dw.SetFontSize(max(12, float64(height)/25))
+
+	// Set up montage parameters. Pages are already at their target size,
+	// so tiles keep the size of their page.
+	mode := imagick.MONTAGE_MODE_CONCATENATE
+	thumbGeo := "+0+0"
+	if layout.Spacing > 0 || layout.Captions {
+		mode = imagick.MONTAGE_MODE_UNFRAME
+		gap := layout.Spacing / 2
+		thumbGeo = fmt.Sprintf("+%d+%d", gap, gap)
+	}
+	frame := "+0+0" // No frame
+
+	// Create the montage
+	montageWand := mw.MontageImage(dw, layout.tile(), thumbGeo, mode, frame)
+	if montageWand == nil || montageWand.GetNumberImages() == 0 {
+		return nil, fmt.Errorf("%w: failed to create montage", ErrProcessing)
+	}
+
+	err := forEachFrame(montageWand, func() error {
+		if err := montageWand.SetImageBackgroundColor(bg); err != nil {
+			return fmt.Errorf("%w: failed to set background color: %v", ErrProcessing, err)
+		}
+		if err := montageWand.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_REMOVE); err != nil {
+			return fmt.Errorf("%w: failed to flatten transparency: %v", ErrProcessing, err)
+		}
+
+		if layout.MaxWidth == 0 && layout.MaxHeight == 0 {
+			return nil
+		}
+		spec := ResizeSpec{Width: uint(layout.MaxWidth), Height: uint(layout.MaxHeight), Mode: ResizeFit, OnlyShrink: true}
+		return resizeImage(montageWand, spec, s)
+	})
+	if err != nil {
+		montageWand.Destroy()
+		return nil, err
+	}
+	return montageWand, nil
+}
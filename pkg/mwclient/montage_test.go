@@ -0,0 +1,153 @@
+package mwclient
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMontageLayout tests the validation and grid geometry of montage
+// layouts
+func TestMontageLayout(t *testing.T) {
+	tests := []struct {
+		layout MontageLayout
+		tile   string
+	}{
+		{MontageLayout{}, "1x"},
+		{MontageLayout{Columns: 3}, "3x"},
+		{MontageLayout{Rows: 1}, "x1"},
+		{MontageLayout{Columns: 3, Rows: 2}, "3x2"},
+	}
+
+	for _, tt := range tests {
+		if err := tt.layout.validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", tt.layout, err)
+		}
+		if got := tt.layout.tile(); got != tt.tile {
+			t.Errorf("%+v: tile() = %q, want %q", tt.layout, got, tt.tile)
+		}
+	}
+
+	for _, layout := range []MontageLayout{
+		{Columns: -1},
+		{Rows: -2},
+		{Spacing: -4},
+		{Border: -1},
+		{MaxWidth: -100},
+		{MaxHeight: -100},
+	} {
+		if err := layout.validate(); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%+v: expected ErrInvalidInput, got %v", layout, err)
+		}
+		if err := (PdfOptions{Montage: true, Layout: layout}).validate(); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%+v: expected PdfOptions to reject the layout, got %v", layout, err)
+		}
+	}
+}
+
+// TestPdfMontage tests grid, strip and decorated montage layouts
+func TestPdfMontage(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Build a 5-page PDF of 100x200 point pages
+	var inputs []string
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, filepath.Base(pagePath("in.png", i, 5)))
+		if err := os.WriteFile(path, testPNG(t, 100, 200), 0o644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		inputs = append(inputs, path)
+	}
+	pdfPath := filepath.Join(dir, "doc.pdf")
+	if err := client.AssemblePDFFile(ctx, inputs, pdfPath, PDFLayout{DPI: 72}); err != nil {
+		t.Fatalf("AssemblePDFFile failed: %v", err)
+	}
+
+	readSize := func(t *testing.T, path string) (int, int) {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Montage was not written: %v", err)
+		}
+		_, w, h := decodeConfig(t, data)
+		return w, h
+	}
+
+	t.Run("Column", func(t *testing.T) {
+		out := filepath.Join(dir, "column.png")
+		if _, err := client.ConvertPdf(ctx, pdfPath, out, PdfOptions{DPI: 36, Montage: true}); err != nil {
+			t.Fatalf("ConvertPdf failed: %v", err)
+		}
+		if w, h := readSize(t, out); w != 50 || h != 500 {
+			t.Errorf("expected a 50x500 column, got %dx%d", w, h)
+		}
+	})
+
+	t.Run("Strip", func(t *testing.T) {
+		out := filepath.Join(dir, "strip.png")
+		pdf := PdfOptions{DPI: 36, Montage: true, Layout: MontageLayout{Rows: 1, Spacing: 10}}
+		if _, err := client.ConvertPdf(ctx, pdfPath, out, pdf); err != nil {
+			t.Fatalf("ConvertPdf failed: %v", err)
+		}
+		// Each page has a 5 pixel margin on every side
+		if w, h := readSize(t, out); w != 5*60 || h != 110 {
+			t.Errorf("expected a 300x110 strip, got %dx%d", w, h)
+		}
+	})
+
+	t.Run("Sheets", func(t *testing.T) {
+		out := filepath.Join(dir, "sheet.png")
+		pdf := PdfOptions{DPI: 36, Montage: true, Layout: MontageLayout{Columns: 2, Rows: 2}}
+		result, err := client.ConvertPdf(ctx, pdfPath, out, pdf)
+		if err != nil {
+			t.Fatalf("ConvertPdf failed: %v", err)
+		}
+		if !result.Montage || len(result.Pages) != 2 {
+			t.Fatalf("expected 2 sheets, got %+v", result)
+		}
+		for i, sheet := range result.Pages {
+			if sheet.Index != i || sheet.Path != pagePath(out, i, 2) || sheet.Err != nil {
+				t.Errorf("unexpected sheet result %+v", sheet)
+			}
+		}
+		if w, h := readSize(t, pagePath(out, 0, 2)); w != 100 || h != 200 {
+			t.Errorf("expected a full 100x200 first sheet, got %dx%d", w, h)
+		}
+	})
+
+	t.Run("Decorated", func(t *testing.T) {
+		out := filepath.Join(dir, "contact.jpg")
+		layout := MontageLayout{
+			Columns:    3,
+			Rows:       2,
+			Spacing:    8,
+			Background: "#eeeeee",
+			Border:     2,
+			Shadow:     true,
+			Captions:   true,
+			MaxWidth:   120,
+		}
+		result, err := client.ConvertPdf(ctx, pdfPath, out, PdfOptions{DPI: 72, Montage: true, Layout: layout})
+		if err != nil {
+			t.Fatalf("ConvertPdf failed: %v", err)
+		}
+		if len(result.Pages) != 1 || result.Pages[0].Path != out {
+			t.Fatalf("expected a single sheet, got %+v", result.Pages)
+		}
+		// The sheet is scaled down to the maximum width
+		if w, h := readSize(t, out); w != 120 || h <= 0 {
+			t.Errorf("expected a sheet 120 wide, got %dx%d", w, h)
+		}
+	})
+}
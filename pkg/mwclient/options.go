@@ -0,0 +1,285 @@
+package mwclient
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Default processing settings
+const (
+	DefaultQuality    = 95
+	DefaultFilter     = imagick.FILTER_SINC
+	DefaultPdfDensity = 300
+	DefaultBackground = "white"
+
+	// DefaultSpoolThreshold is the input size above which readers are
+	// spooled to a temporary file instead of being held in memory
+	DefaultSpoolThreshold = 8 << 20
+)
+
+// ResourceLimits caps the resources ImageMagick may use.
+// Zero values keep ImageMagick's own defaults.
+type ResourceLimits struct {
+	Memory  int64         // pixel cache memory, in bytes
+	Map     int64         // memory-mapped pixel cache, in bytes
+	Disk    int64         // disk-backed pixel cache, in bytes
+	Threads int64         // threads per operation
+	Time    time.Duration // maximum elapsed time per operation
+}
+
+// apply sets the non-zero limits. ImageMagick limits are process-wide.
+func (l ResourceLimits) apply() error {
+	limits := []struct {
+		name  string
+		rtype imagick.ResourceType
+		value int64
+	}{
+		{"memory", imagick.RESOURCE_MEMORY, l.Memory},
+		{"map", imagick.RESOURCE_MAP, l.Map},
+		{"disk", imagick.RESOURCE_DISK, l.Disk},
+		{"thread", imagick.RESOURCE_THREAD, l.Threads},
+		{"time", imagick.RESOURCE_TIME, int64(l.Time / time.Second)},
+	}
+
+	for _, limit := range limits {
+		if limit.value <= 0 {
+			continue
+		}
+		if !imagick.SetResourceLimit(limit.rtype, uint64(limit.value)) {
+			return fmt.Errorf("%w: failed to set %s resource limit to %d", ErrProcessing, limit.name, limit.value)
+		}
+	}
+
+	return nil
+}
+
+// settings holds the configuration used by an operation
+type settings struct {
+	quality     uint
+	filter      imagick.FilterType
+	pdfDensity  float64
+	pdfPassword string
+	background  string
+	logger      *slog.Logger
+
+	maxInputSize   int64
+	spoolThreshold int64
+	tempDir        string
+
+	strip StripPolicy
+	color ColorManagement
+
+	jpeg JPEGOptions
+	png  PNGOptions
+	webp WebPOptions
+	avif AVIFOptions
+
+	// Client-level only; ignored when passed to a single call
+	workers int
+	limits  ResourceLimits
+}
+
+// defaultSettings returns the settings used when no options are given
+func defaultSettings() settings {
+	return settings{
+		quality:    DefaultQuality,
+		filter:     DefaultFilter,
+		pdfDensity: DefaultPdfDensity,
+		background: DefaultBackground,
+		logger:     slog.Default(),
+
+		spoolThreshold: DefaultSpoolThreshold,
+
+		workers: 1,
+	}
+}
+
+// validate checks the settings for out-of-range values
+func (s settings) validate() error {
+	if s.quality < 1 || s.quality > 100 {
+		return fmt.Errorf("%w: quality must be between 1 and 100", ErrInvalidInput)
+	}
+	if s.pdfDensity <= 0 {
+		return fmt.Errorf("%w: PDF density must be positive", ErrInvalidInput)
+	}
+	if s.background == "" {
+		return fmt.Errorf("%w: background color is empty", ErrInvalidInput)
+	}
+	if s.maxInputSize < 0 {
+		return fmt.Errorf("%w: maximum input size must not be negative", ErrInvalidInput)
+	}
+	if s.spoolThreshold < 0 {
+		return fmt.Errorf("%w: spool threshold must not be negative", ErrInvalidInput)
+	}
+	if err := s.strip.validate(); err != nil {
+		return err
+	}
+	if err := s.color.validate(); err != nil {
+		return err
+	}
+	for _, o := range []interface{ validate() error }{s.jpeg, s.png, s.webp, s.avif} {
+		if err := o.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Option configures a Client, or overrides the client's settings for a single call
+type Option func(*settings)
+
+// WithQuality sets the compression quality (1-100) of encoded images
+func WithQuality(quality uint) Option {
+	return func(s *settings) {
+		s.quality = quality
+	}
+}
+
+// WithFilter sets the resample filter used when resizing
+func WithFilter(filter imagick.FilterType) Option {
+	return func(s *settings) {
+		s.filter = filter
+	}
+}
+
+// WithPdfDensity sets the density, in DPI, at which PDF pages are rasterized
+func WithPdfDensity(dpi float64) Option {
+	return func(s *settings) {
+		s.pdfDensity = dpi
+	}
+}
+
+// WithPdfPassword sets the password used to open encrypted PDFs. Without
+// it, reading an encrypted PDF fails with ErrPasswordRequired.
+func WithPdfPassword(password string) Option {
+	return func(s *settings) {
+		s.pdfPassword = password
+	}
+}
+
+// WithBackgroundColor sets the color used to flatten transparency
+func WithBackgroundColor(color string) Option {
+	return func(s *settings) {
+		s.background = color
+	}
+}
+
+// WithLogger sets the logger used for progress and error messages.
+// A nil logger discards all messages.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *settings) {
+		if logger == nil {
+			logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		}
+		s.logger = logger
+	}
+}
+
+// WithMaxInputSize rejects inputs larger than size bytes with
+// ErrInputTooLarge, whether they are read from a reader or a path. Zero,
+// the default, means no limit.
+func WithMaxInputSize(size int64) Option {
+	return func(s *settings) {
+		s.maxInputSize = size
+	}
+}
+
+// WithSpoolThreshold sets the size, in bytes, above which readers are spooled
+// to a temporary file and results are streamed from one instead of being
+// buffered in memory. Zero disables spooling.
+func WithSpoolThreshold(size int64) Option {
+	return func(s *settings) {
+		s.spoolThreshold = size
+	}
+}
+
+// WithTempDir sets the directory used for spooled inputs and outputs.
+// An empty dir, the default, uses os.TempDir.
+func WithTempDir(dir string) Option {
+	return func(s *settings) {
+		s.tempDir = dir
+	}
+}
+
+// WithStripPolicy sets which metadata is removed from output images. The
+// default, KeepMetadata, writes metadata unchanged.
+func WithStripPolicy(policy StripPolicy) Option {
+	return func(s *settings) {
+		s.strip = policy
+	}
+}
+
+// WithColorManagement sets the color profile conversion applied to output
+// images. By default no conversion happens.
+func WithColorManagement(cm ColorManagement) Option {
+	return func(s *settings) {
+		s.color = cm
+	}
+}
+
+// WithJPEGOptions sets the encoder options used for JPEG output
+func WithJPEGOptions(o JPEGOptions) Option {
+	return func(s *settings) {
+		s.jpeg = o
+	}
+}
+
+// WithPNGOptions sets the encoder options used for PNG output
+func WithPNGOptions(o PNGOptions) Option {
+	return func(s *settings) {
+		s.png = o
+	}
+}
+
+// WithWebPOptions sets the encoder options used for WebP output
+func WithWebPOptions(o WebPOptions) Option {
+	return func(s *settings) {
+		s.webp = o
+	}
+}
+
+// WithAVIFOptions sets the encoder options used for AVIF output
+func WithAVIFOptions(o AVIFOptions) Option {
+	return func(s *settings) {
+		s.avif = o
+	}
+}
+
+// WithConcurrency sets the number of images processed in parallel.
+// Client-level only.
+func WithConcurrency(workers int) Option {
+	return func(s *settings) {
+		s.workers = workers
+	}
+}
+
+// WithResourceLimits sets ImageMagick resource limits. The limits are
+// process-wide, so they affect every client. Client-level only.
+func WithResourceLimits(limits ResourceLimits) Option {
+	return func(s *settings) {
+		s.limits = limits
+	}
+}
+
+// settingsFor returns the client's settings with per-call overrides applied
+func (c *Client) settingsFor(opts []Option) (settings, error) {
+	s := c.settings
+	if c.err != nil {
+		return s, c.err
+	}
+
+	for _, opt := range opts {
+		opt(&s)
+	}
+	s.workers = c.settings.workers
+	s.limits = c.settings.limits
+
+	if err := s.validate(); err != nil {
+		return s, err
+	}
+	return s, nil
+}
@@ -0,0 +1,166 @@
+package mwclient
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// TestDefaultSettings tests that the defaults match the historical behavior
+func TestDefaultSettings(t *testing.T) {
+	s := defaultSettings()
+
+	if s.quality != 95 {
+		t.Errorf("expected quality 95, got %d", s.quality)
+	}
+	if s.filter != imagick.FILTER_SINC {
+		t.Errorf("expected Sinc filter, got %v", s.filter)
+	}
+	if s.pdfDensity != 300 {
+		t.Errorf("expected PDF density 300, got %v", s.pdfDensity)
+	}
+	if s.background != "white" {
+		t.Errorf("expected white background, got %q", s.background)
+	}
+	if s.workers != 1 {
+		t.Errorf("expected 1 worker, got %d", s.workers)
+	}
+	if err := s.validate(); err != nil {
+		t.Errorf("default settings are invalid: %v", err)
+	}
+}
+
+// TestSettingsFor tests per-call overrides on top of client settings
+func TestSettingsFor(t *testing.T) {
+	base := defaultSettings()
+	WithQuality(80)(&base)
+	WithConcurrency(4)(&base)
+	c := &Client{settings: base}
+
+	s, err := c.settingsFor(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.quality != 80 {
+		t.Errorf("expected client quality 80, got %d", s.quality)
+	}
+
+	s, err = c.settingsFor([]Option{
+		WithQuality(60),
+		WithFilter(imagick.FILTER_LANCZOS),
+		WithPdfDensity(72),
+		WithPdfPassword("secret"),
+		WithBackgroundColor("black"),
+		WithConcurrency(16),
+		WithResourceLimits(ResourceLimits{Memory: 1 << 20}),
+		WithLogger(nil),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.quality != 60 || s.filter != imagick.FILTER_LANCZOS || s.pdfDensity != 72 || s.pdfPassword != "secret" || s.background != "black" {
+		t.Errorf("per-call overrides not applied: %+v", s)
+	}
+	if s.workers != 4 || s.limits != (ResourceLimits{}) {
+		t.Errorf("client-level settings must not be overridden per call: %+v", s)
+	}
+	if s.logger == nil {
+		t.Error("expected a discarding logger, got nil")
+	}
+
+	// The client's own settings are unchanged
+	if c.settings.quality != 80 {
+		t.Errorf("client settings were modified: %+v", c.settings)
+	}
+}
+
+// TestSettingsValidation tests that out-of-range options are rejected
+func TestSettingsValidation(t *testing.T) {
+	c := &Client{settings: defaultSettings()}
+
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"zero quality", WithQuality(0)},
+		{"quality above 100", WithQuality(101)},
+		{"zero density", WithPdfDensity(0)},
+		{"negative density", WithPdfDensity(-72)},
+		{"empty background", WithBackgroundColor("")},
+		{"negative max input size", WithMaxInputSize(-1)},
+		{"negative spool threshold", WithSpoolThreshold(-1)},
+		{"unknown strip mode", WithStripPolicy(StripPolicy{Mode: StripMode(7)})},
+		{"JPEG quality above 100", WithJPEGOptions(JPEGOptions{Quality: 101})},
+		{"unknown chroma subsampling", WithJPEGOptions(JPEGOptions{Subsampling: "4:1:1"})},
+		{"PNG compression level above 9", WithPNGOptions(PNGOptions{CompressionLevel: 10})},
+		{"WebP method above 6", WithWebPOptions(WebPOptions{Method: 7})},
+		{"WebP alpha quality above 100", WithWebPOptions(WebPOptions{AlphaQuality: 101})},
+		{"AVIF speed above 10", WithAVIFOptions(AVIFOptions{Speed: 11})},
+		{"invalid target profile", WithColorManagement(ColorManagement{Convert: true, Target: []byte("not a profile")})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.settingsFor([]Option{tt.opt})
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("expected ErrInvalidInput, got %v", err)
+			}
+		})
+	}
+}
+
+// TestPerCallOptions tests that operations reject invalid per-call options
+func TestPerCallOptions(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New(WithQuality(85), WithConcurrency(2))
+	defer client.Close()
+
+	if got := client.Stats().Workers; got != 2 {
+		t.Errorf("expected 2 workers, got %d", got)
+	}
+
+	var buf bytes.Buffer
+	err := client.ResizeImage(bytes.NewReader([]byte("test")), &buf, 100, 100, "png", WithQuality(0))
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+
+	err = client.ConvertPdfToImages("nonexistent.pdf", "output.png", 1, 300, false, WithPdfDensity(-1))
+	if err == nil {
+		t.Error("Expected error with invalid density, got nil")
+	}
+}
+
+// TestNewValidation tests that New reports invalid options
+func TestNewValidation(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New(WithQuality(0), WithLogger(nil))
+	defer client.Close()
+
+	if err := client.Err(); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput from Err, got %v", err)
+	}
+
+	// Per-call options do not repair an invalid client
+	var buf bytes.Buffer
+	err := client.ResizeImage(bytes.NewReader([]byte("test")), &buf, 100, 100, "png", WithQuality(80))
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+
+	valid := New(WithQuality(80))
+	defer valid.Close()
+	if err := valid.Err(); err != nil {
+		t.Errorf("unexpected error for valid options: %v", err)
+	}
+}
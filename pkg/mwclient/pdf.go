@@ -0,0 +1,648 @@
+package mwclient
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// lastPage stands for the last page of a document in a page range
+const lastPage = -1
+
+// pageRange is a range of 1-based page numbers; either end may be lastPage
+type pageRange struct {
+	first, last int
+}
+
+// PageSelection selects pages of a document, in order. The zero value
+// selects every page.
+type PageSelection struct {
+	ranges []pageRange
+}
+
+// ParsePageSelection parses a comma-separated list of 1-based pages and
+// ranges, such as "1,3-5,last". "last" is the last page, and a range
+// without an end ("7-") runs to the last page. Pages are returned in the
+// order given; an empty string or "all" selects every page.
+func ParsePageSelection(spec string) (PageSelection, error) {
+	var sel PageSelection
+
+	spec = strings.TrimSpace(spec)
+	if spec == "" || strings.EqualFold(spec, "all") {
+		return sel, nil
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+
+		first, last, isRange := strings.Cut(item, "-")
+		r, err := parsePageRange(first, last, isRange)
+		if err != nil {
+			return PageSelection{}, fmt.Errorf("%w: invalid page selection %q: %v", ErrInvalidInput, item, err)
+		}
+		sel.ranges = append(sel.ranges, r)
+	}
+
+	return sel, nil
+}
+
+// parsePageRange parses the ends of a single page or a range
+func parsePageRange(first, last string, isRange bool) (pageRange, error) {
+	start, err := parsePageNumber(first)
+	if err != nil {
+		return pageRange{}, err
+	}
+	if !isRange {
+		return pageRange{first: start, last: start}, nil
+	}
+	if start == lastPage {
+		return pageRange{}, fmt.Errorf("range starts at the last page")
+	}
+
+	if strings.TrimSpace(last) == "" {
+		return pageRange{first: start, last: lastPage}, nil
+	}
+	end, err := parsePageNumber(last)
+	if err != nil {
+		return pageRange{}, err
+	}
+	if end != lastPage && end < start {
+		return pageRange{}, fmt.Errorf("range ends before it starts")
+	}
+	return pageRange{first: start, last: end}, nil
+}
+
+// parsePageNumber parses a 1-based page number or "last"
+func parsePageNumber(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "last") {
+		return lastPage, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a page number", s)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("pages are numbered from 1")
+	}
+	return n, nil
+}
+
+// String returns the selection in the form accepted by ParsePageSelection
+func (sel PageSelection) String() string {
+	if len(sel.ranges) == 0 {
+		return "all"
+	}
+
+	page := func(n int) string {
+		if n == lastPage {
+			return "last"
+		}
+		return strconv.Itoa(n)
+	}
+
+	items := make([]string, len(sel.ranges))
+	for i, r := range sel.ranges {
+		items[i] = page(r.first)
+		if r.last != r.first {
+			items[i] += "-" + page(r.last)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// needsCount reports whether resolving the selection needs the page count
+func (sel PageSelection) needsCount() bool {
+	for _, r := range sel.ranges {
+		if r.first == lastPage || r.last == lastPage {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the 1-based first and last page of r in a document with
+// count pages, or 0 pages if the count is unknown. Ranges running past the
+// end are cut short; ranges starting past the end are an error.
+func (r pageRange) resolve(count int) (int, int, error) {
+	first, last := r.first, r.last
+	if first == lastPage {
+		first = count
+	}
+	if last == lastPage {
+		last = count
+	}
+
+	if count > 0 {
+		if first > count {
+			return 0, 0, fmt.Errorf("%w: page %d out of range, document has %d pages", ErrInvalidInput, first, count)
+		}
+		last = min(last, count)
+	}
+	return first, last, nil
+}
+
+// PdfOptions configures the rasterization of a PDF by ConvertPdf
+type PdfOptions struct {
+	// Pages selects the pages to convert; the zero value converts all.
+	// Only the selected pages are rasterized.
+	Pages PageSelection
+
+	// DPI is the density at which pages are rasterized. 0 uses the
+	// client's PDF density.
+	DPI float64
+
+	// Height is the height of the output images; 0 keeps the rasterized
+	// size
+	Height int
+
+	// Montage combines the pages into a single image, arranged by Layout
+	Montage bool
+
+	// Layout arranges the pages of a montage; the zero value stacks them
+	// vertically
+	Layout MontageLayout
+
+	// Strict aborts the conversion on the first page that fails. Otherwise
+	// failing pages are skipped and reported once all pages are done.
+	Strict bool
+}
+
+// validate checks the options for invalid values
+func (o PdfOptions) validate() error {
+	if err := o.check(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return nil
+}
+
+// check is like validate but returns an error without the sentinel
+func (o PdfOptions) check() error {
+	if o.DPI < 0 {
+		return errors.New("DPI must not be negative")
+	}
+	if o.Height < 0 {
+		return errors.New("height must not be negative")
+	}
+	return o.Layout.check()
+}
+
+// PageResult describes the outcome of one page of a PDF conversion
+type PageResult struct {
+	Index  int    // 0-based page index in the document, or sheet index of a montage
+	Path   string // output path, empty for a failed page of a montage
+	Width  uint
+	Height uint
+	Size   int64 // encoded size in bytes
+	Err    error
+}
+
+// PdfResult lists the pages of a PDF conversion in the order they were
+// processed, or the sheets of a montage
+type PdfResult struct {
+	Pages   []PageResult
+	Montage bool
+}
+
+// PageError is the failure of a single page
+type PageError struct {
+	Index int // 0-based page index in the document
+	Err   error
+}
+
+// Error implements error
+func (e PageError) Error() string {
+	return fmt.Sprintf("page %d: %v", e.Index+1, e.Err)
+}
+
+// Unwrap returns the page's error
+func (e PageError) Unwrap() error {
+	return e.Err
+}
+
+// PageErrors is returned when pages of a PDF conversion fail. It wraps the
+// error of every failed page, so errors.Is matches any of them.
+type PageErrors []PageError
+
+// Error implements error
+func (e PageErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = pe.Error()
+	}
+
+	noun := "pages"
+	if len(e) == 1 {
+		noun = "page"
+	}
+	return fmt.Sprintf("%d %s failed: %s", len(e), noun, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the failed pages
+func (e PageErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, pe := range e {
+		errs[i] = pe
+	}
+	return errs
+}
+
+// ConvertPdf converts the selected pages of a PDF file to images. Pages are
+// written next to outputPath as "<base>_page<N><ext>", N being the page
+// number in the document, or to outputPath itself if a single page is
+// selected. With Montage the pages are combined into outputPath, or into
+// "<base>_page<N><ext>" for sheet N if the layout needs several sheets. The
+// output extension picks the format.
+//
+// The result lists every page with its output path, dimensions, size and
+// error. If pages fail, the returned error is a PageErrors; with Strict it
+// holds the first failure, after which no further pages are converted.
+func (c *Client) ConvertPdf(ctx context.Context, inputPath, outputPath string, pdf PdfOptions, opts ...Option) (PdfResult, error) {
+	return c.convertPdf(ctx, inputPath, outputPath, pdf, nil, opts)
+}
+
+// convertPdf is ConvertPdf, applying steps to every page, or every sheet of
+// a montage, before it is encoded
+func (c *Client) convertPdf(ctx context.Context, inputPath, outputPath string, pdf PdfOptions, steps []step, opts []Option) (PdfResult, error) {
+	result := PdfResult{Montage: pdf.Montage}
+
+	if inputPath == "" || outputPath == "" {
+		return result, fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
+	}
+
+	if err := pdf.validate(); err != nil {
+		return result, err
+	}
+
+	// Check if input file exists
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		return result, fmt.Errorf("%w: input file does not exist: %v", ErrInvalidInput, err)
+	}
+
+	s, err := c.settingsFor(opts)
+	if err != nil {
+		return result, err
+	}
+
+	release, err := c.pool.acquire(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer release()
+
+	// Generate the output filename for a page
+	pathOf := func(page pdfPage) string {
+		return pagePath(outputPath, page.index, page.count)
+	}
+
+	var failed PageErrors
+	err = renderPdf(ctx, inputPath, pdf, &s, func(page pdfPage) error {
+		pageOutputPath := pathOf(page)
+		s.logger.Info("Writing page", "Index", page.index, "Path", pageOutputPath)
+
+		for _, st := range steps {
+			s.logger.Debug("Page step", "Step", st.name)
+			if err := st.apply(page.mw, &s); err != nil {
+				return err
+			}
+		}
+
+		// Convert colors and remove metadata before anything is written
+		if err := applyOutputPolicies(page.mw, &s); err != nil {
+			return err
+		}
+
+		// Configure the encoder for the output format
+		if err := applyEncoderOptions(page.mw, formatFromPath(pageOutputPath), &s); err != nil {
+			return err
+		}
+
+		// Write the page image to file
+		if err := page.mw.WriteImage(pageOutputPath); err != nil {
+			return fmt.Errorf("%w: failed to write page image: %v", ErrProcessing, err)
+		}
+
+		info, err := os.Stat(pageOutputPath)
+		if err != nil {
+			return fmt.Errorf("failed to stat output: %w", err)
+		}
+
+		result.Pages = append(result.Pages, PageResult{
+			Index:  page.index,
+			Path:   pageOutputPath,
+			Width:  page.mw.GetImageWidth(),
+			Height: page.mw.GetImageHeight(),
+			Size:   info.Size(),
+		})
+		return nil
+	}, func(page pdfPage, err error) error {
+		s.logger.Error("Failed to convert page", "error", err, "page", page.index+1)
+
+		// Pages of a montage are only written as part of a sheet
+		path := pathOf(page)
+		if pdf.Montage && !page.montage {
+			path = ""
+		}
+		result.Pages = append(result.Pages, PageResult{Index: page.index, Path: path, Err: err})
+		failed = append(failed, PageError{Index: page.index, Err: err})
+		if pdf.Strict || page.montage {
+			return failed
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if len(failed) > 0 {
+		return result, failed
+	}
+
+	return result, nil
+}
+
+// pdfPage is a rasterized page, or a sheet of the montage, ready to be
+// encoded
+type pdfPage struct {
+	index   int // 0-based page index in the document, or sheet index
+	count   int // number of selected pages, or of sheets
+	montage bool
+	mw      *imagick.MagickWand // nil for a page that failed before emit
+}
+
+// renderPdf rasterizes the selected pages of the PDF at path, flattens,
+// orients and resizes each of them and passes it to emit, or combines them
+// into a montage whose sheets are passed to emit. A page that fails, in emit or before,
+// is passed to failed, which returns the error ending the job or nil to
+// skip the page. ErrStopPages returned by emit ends the job without an
+// error.
+func renderPdf(ctx context.Context, path string, pdf PdfOptions, s *settings,
+	emit func(page pdfPage) error, failed func(page pdfPage, err error) error) error {
+
+	// Read the selected pages of the PDF
+	pdfWand := imagick.NewMagickWand()
+	defer pdfWand.Destroy()
+
+	dpi := cmp.Or(pdf.DPI, s.pdfDensity)
+	pages, err := readPdfPages(ctx, pdfWand, path, pdf.Pages, dpi, s)
+	if err != nil {
+		return err
+	}
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	numPages := len(pages)
+	s.logger.Info("ConvertPdf", "Page Height", pdf.Height, "Pages", pdf.Pages.String(), "Total Pages", numPages)
+
+	// pageFailed passes a failure on unless emit asked to stop
+	pageFailed := func(page pdfPage, err error) error {
+		if errors.Is(err, ErrStopPages) {
+			return err
+		}
+		return failed(page, err)
+	}
+
+	// Create a new wand for the montage
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+
+	for i := 0; i < numPages; i++ {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+
+		s.logger.Info("Processing page", "Index", i, "Page", pages[i])
+		pdfWand.SetIteratorIndex(i)
+		page := pdfPage{index: pages[i] - 1, count: numPages}
+
+		err := func() error {
+			flat, err := flattenPdfPage(pdfWand.GetImage(), pdf, s)
+			if err != nil {
+				return err
+			}
+			defer flat.Destroy()
+
+			// Collect the decorated pages for the montage
+			if pdf.Montage {
+				if err := decoratePage(flat, pdf.Layout, pages[i]); err != nil {
+					return err
+				}
+				if err := mw.AddImage(flat); err != nil {
+					return fmt.Errorf("%w: failed to add page image: %v", ErrProcessing, err)
+				}
+				return nil
+			}
+
+			page.mw = flat
+			return emit(page)
+		}()
+		if err != nil {
+			page.mw = nil
+			if err := pageFailed(page, err); err != nil {
+				return stopPages(err)
+			}
+			continue
+		}
+
+		s.logger.Info("Processed page", "Index", i)
+	}
+
+	// If creating a montage, combine all pages into sheets
+	if pdf.Montage {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+
+		sheets, err := montagePdfPages(mw, pdf.Layout, s)
+		if err != nil {
+			return stopPages(pageFailed(pdfPage{count: 1, montage: true}, err))
+		}
+		defer sheets.Destroy()
+
+		numSheets := int(sheets.GetNumberImages())
+		for i := 0; i < numSheets; i++ {
+			if err := checkContext(ctx); err != nil {
+				return err
+			}
+
+			sheets.SetIteratorIndex(i)
+			page := pdfPage{index: i, count: numSheets, montage: true}
+
+			err := func() error {
+				sheet := sheets.GetImage()
+				defer sheet.Destroy()
+
+				page.mw = sheet
+				return emit(page)
+			}()
+			if err != nil {
+				page.mw = nil
+				if err := pageFailed(page, err); err != nil {
+					return stopPages(err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// stopPages turns ErrStopPages into a successful end of the job
+func stopPages(err error) error {
+	if errors.Is(err, ErrStopPages) {
+		return nil
+	}
+	return err
+}
+
+// flattenPdfPage flattens page over the background color, auto-orients it
+// and resizes it to the target height. page is destroyed.
+func flattenPdfPage(page *imagick.MagickWand, pdf PdfOptions, s *settings) (*imagick.MagickWand, error) {
+	// flatten transparency over the background color
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	bg.SetColor(s.background)
+	page.SetImageBackgroundColor(bg)
+	flat := page.MergeImageLayers(imagick.IMAGE_LAYER_FLATTEN) // new flat wand
+	page.Destroy()                                             // drop the raw one early
+
+	// Auto-orient the image based on EXIF data
+	err := flat.AutoOrientImage()
+	if err != nil {
+		s.logger.Error("Auto-orientation failed", "error", err)
+		// Continue despite error
+	}
+
+	// Resize to the target height
+	if pdf.Height > 0 {
+		imageWidth := int32(flat.GetImageWidth())
+		imageHeight := int32(flat.GetImageHeight())
+		targetWidth := uint(imageWidth * int32(pdf.Height) / imageHeight)
+
+		if err := flat.ResizeImage(targetWidth, uint(pdf.Height), s.filter); err != nil {
+			flat.Destroy()
+			return nil, fmt.Errorf("%w: failed to resize page image: %v", ErrProcessing, err)
+		}
+	}
+
+	return flat, nil
+}
+
+// readPdfPages rasterizes the selected pages of the PDF at path into mw at
+// dpi and returns their 1-based page numbers. Each page or range is read
+// with ImageMagick's "file.pdf[first-last]" syntax, so pages outside the
+// selection are never rasterized.
+func readPdfPages(ctx context.Context, mw *imagick.MagickWand, path string, sel PageSelection, dpi float64, s *settings) ([]int, error) {
+	if err := checkFileSize(pdfFile(path), s); err != nil {
+		return nil, err
+	}
+	if err := checkPdfLocked(path, s); err != nil {
+		return nil, err
+	}
+
+	// bump PDF raster density for sharper text/lines
+	if err := mw.SetResolution(dpi, dpi); err != nil {
+		return nil, fmt.Errorf("%w: could not set resolution: %v", ErrProcessing, err)
+	}
+	if err := setPdfPassword(mw, s); err != nil {
+		return nil, err
+	}
+
+	if len(sel.ranges) == 0 {
+		if err := mw.ReadImage(path); err != nil {
+			if perr := passwordError(path, s, err); perr != nil {
+				return nil, perr
+			}
+			return nil, fmt.Errorf("%w: failed to read PDF: %v", ErrProcessing, err)
+		}
+		pages := make([]int, mw.GetNumberImages())
+		for i := range pages {
+			pages[i] = i + 1
+		}
+		return pages, nil
+	}
+
+	var count int
+	if sel.needsCount() {
+		var err error
+		if count, err = pdfPageCount(path, s); err != nil {
+			return nil, err
+		}
+	}
+
+	var pages []int
+	for _, r := range sel.ranges {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+
+		first, last, err := r.resolve(count)
+		if err != nil {
+			return nil, err
+		}
+
+		// ImageMagick numbers pages from 0
+		spec := fmt.Sprintf("%s[%d-%d]", path, first-1, last-1)
+		if first == last {
+			spec = fmt.Sprintf("%s[%d]", path, first-1)
+		}
+
+		s.logger.Debug("Reading PDF pages", "Pages", spec)
+		before := mw.GetNumberImages()
+		mw.SetLastIterator()
+		if err := mw.ReadImage(spec); err != nil {
+			if perr := passwordError(path, s, err); perr != nil {
+				return nil, perr
+			}
+			return nil, fmt.Errorf("%w: failed to read PDF pages %d-%d: %v", ErrProcessing, first, last, err)
+		}
+
+		read := int(mw.GetNumberImages() - before)
+		if read == 0 {
+			return nil, fmt.Errorf("%w: page %d out of range", ErrInvalidInput, first)
+		}
+		for page := first; page < first+read; page++ {
+			pages = append(pages, page)
+		}
+	}
+
+	return pages, nil
+}
+
+// pdfFile returns the file name of path without ImageMagick's "PDF:"
+// format prefix
+func pdfFile(path string) string {
+	return strings.TrimPrefix(path, "PDF:")
+}
+
+// pdfPageCount returns the number of pages of the PDF at path, from its
+// page tree if it can be parsed, which is much cheaper than a ping
+func pdfPageCount(path string, s *settings) (int, error) {
+	count, err := pdfPageCountFromStructure(pdfFile(path))
+	if err == nil {
+		return count, nil
+	}
+	s.logger.Debug("Failed to parse PDF structure, pinging instead", "error", err)
+
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+
+	if err := setPdfPassword(mw, s); err != nil {
+		return 0, err
+	}
+	if err := mw.PingImage(path); err != nil {
+		if perr := passwordError(path, s, err); perr != nil {
+			return 0, perr
+		}
+		return 0, fmt.Errorf("%w: failed to read PDF: %v", ErrProcessing, err)
+	}
+	return int(mw.GetNumberImages()), nil
+}
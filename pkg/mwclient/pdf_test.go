@@ -0,0 +1,252 @@
+package mwclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParsePageSelection tests parsing and formatting page selections
+func TestParsePageSelection(t *testing.T) {
+	tests := []struct {
+		spec   string
+		want   string
+		ranges []pageRange
+	}{
+		{"", "all", nil},
+		{"all", "all", nil},
+		{"3", "3", []pageRange{{3, 3}}},
+		{"1,3-5,last", "1,3-5,last", []pageRange{{1, 1}, {3, 5}, {lastPage, lastPage}}},
+		{" 2 - 4 , 7- ", "2-4,7-last", []pageRange{{2, 4}, {7, lastPage}}},
+		{"5-LAST,1", "5-last,1", []pageRange{{5, lastPage}, {1, 1}}},
+		{"4-4", "4", []pageRange{{4, 4}}},
+	}
+
+	for _, tt := range tests {
+		sel, err := ParsePageSelection(tt.spec)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.spec, err)
+			continue
+		}
+		if got := sel.String(); got != tt.want {
+			t.Errorf("%q: String() = %q, want %q", tt.spec, got, tt.want)
+		}
+		if len(sel.ranges) != len(tt.ranges) {
+			t.Errorf("%q: got %v, want %v", tt.spec, sel.ranges, tt.ranges)
+			continue
+		}
+		for i := range tt.ranges {
+			if sel.ranges[i] != tt.ranges[i] {
+				t.Errorf("%q: got %v, want %v", tt.spec, sel.ranges, tt.ranges)
+				break
+			}
+		}
+	}
+
+	for _, spec := range []string{"0", "-3", "1,,2", "5-3", "last-2", "a", "1-b", "2.5"} {
+		if _, err := ParsePageSelection(spec); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%q: expected ErrInvalidInput, got %v", spec, err)
+		}
+	}
+}
+
+// TestPageRangeResolve tests resolving page ranges against a page count
+func TestPageRangeResolve(t *testing.T) {
+	tests := []struct {
+		r           pageRange
+		count       int
+		first, last int
+	}{
+		{pageRange{2, 4}, 0, 2, 4},
+		{pageRange{2, 4}, 10, 2, 4},
+		{pageRange{2, 40}, 10, 2, 10},
+		{pageRange{lastPage, lastPage}, 10, 10, 10},
+		{pageRange{7, lastPage}, 10, 7, 10},
+	}
+
+	for _, tt := range tests {
+		first, last, err := tt.r.resolve(tt.count)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tt.r, err)
+			continue
+		}
+		if first != tt.first || last != tt.last {
+			t.Errorf("%v with %d pages: got %d-%d, want %d-%d", tt.r, tt.count, first, last, tt.first, tt.last)
+		}
+	}
+
+	if _, _, err := (pageRange{12, 14}).resolve(10); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput past the last page, got %v", err)
+	}
+}
+
+// TestConvertPdf tests converting selected PDF pages at a chosen density
+func TestConvertPdf(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Build a 4-page PDF whose pages are 100, 120, 140 and 160 points wide
+	var inputs []string
+	for i := 0; i < 4; i++ {
+		path := filepath.Join(dir, filepath.Base(pagePath("in.png", i, 4)))
+		if err := os.WriteFile(path, testPNG(t, 100+20*i, 200), 0o644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		inputs = append(inputs, path)
+	}
+	pdfPath := filepath.Join(dir, "doc.pdf")
+	if err := client.AssemblePDFFile(ctx, inputs, pdfPath, PDFLayout{DPI: 72}); err != nil {
+		t.Fatalf("AssemblePDFFile failed: %v", err)
+	}
+
+	sel, err := ParsePageSelection("2,4")
+	if err != nil {
+		t.Fatalf("ParsePageSelection failed: %v", err)
+	}
+
+	out := filepath.Join(dir, "out.png")
+	result, err := client.ConvertPdf(ctx, pdfPath, out, PdfOptions{Pages: sel, DPI: 36})
+	if err != nil {
+		t.Fatalf("ConvertPdf failed: %v", err)
+	}
+	if len(result.Pages) != 2 || result.Pages[0].Index != 1 || result.Pages[1].Index != 3 {
+		t.Fatalf("expected pages 2 and 4 in the result, got %+v", result.Pages)
+	}
+	for _, page := range result.Pages {
+		if page.Err != nil || page.Path != pagePath(out, page.Index, 2) || page.Height != 100 || page.Size == 0 {
+			t.Errorf("unexpected page result %+v", page)
+		}
+	}
+
+	for page, width := range map[int]int{2: 60, 4: 80} {
+		data, err := os.ReadFile(pagePath(out, page-1, 2))
+		if err != nil {
+			t.Fatalf("Page %d was not written: %v", page, err)
+		}
+		// Rasterizing at 36 DPI halves the point size
+		if _, w, h := decodeConfig(t, data); w != width || h != 100 {
+			t.Errorf("page %d: expected %dx100, got %dx%d", page, width, w, h)
+		}
+	}
+	for _, page := range []int{1, 3} {
+		if _, err := os.Stat(pagePath(out, page-1, 2)); !os.IsNotExist(err) {
+			t.Errorf("page %d should not have been written", page)
+		}
+	}
+
+	// "last" resolves against the page count
+	sel, _ = ParsePageSelection("last")
+	single := filepath.Join(dir, "last.png")
+	if _, err := client.ConvertPdf(ctx, pdfPath, single, PdfOptions{Pages: sel, DPI: 72}); err != nil {
+		t.Fatalf("ConvertPdf failed: %v", err)
+	}
+	data, err := os.ReadFile(single)
+	if err != nil {
+		t.Fatalf("Last page was not written: %v", err)
+	}
+	if _, w, _ := decodeConfig(t, data); w != 160 {
+		t.Errorf("expected the last page to be 160 wide, got %d", w)
+	}
+
+	sel, _ = ParsePageSelection("9")
+	if _, err := client.ConvertPdf(ctx, pdfPath, out, PdfOptions{Pages: sel}); err == nil {
+		t.Error("expected an error for a page past the end")
+	}
+	if _, err := client.ConvertPdf(ctx, pdfPath, out, PdfOptions{DPI: -1}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for negative DPI, got %v", err)
+	}
+}
+
+// TestConvertPdfPageErrors tests the reporting of failing pages
+func TestConvertPdfPageErrors(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	var inputs []string
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, filepath.Base(pagePath("in.png", i, 3)))
+		if err := os.WriteFile(path, testPNG(t, 100, 200), 0o644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		inputs = append(inputs, path)
+	}
+	pdfPath := filepath.Join(dir, "doc.pdf")
+	if err := client.AssemblePDFFile(ctx, inputs, pdfPath, PDFLayout{DPI: 72}); err != nil {
+		t.Fatalf("AssemblePDFFile failed: %v", err)
+	}
+
+	// Pages cannot be written into a missing directory
+	out := filepath.Join(dir, "missing", "out.png")
+
+	result, err := client.ConvertPdf(ctx, pdfPath, out, PdfOptions{DPI: 36})
+	var pageErrs PageErrors
+	if !errors.As(err, &pageErrs) || len(pageErrs) != 3 || !errors.Is(err, ErrProcessing) {
+		t.Fatalf("expected PageErrors for 3 pages, got %v", err)
+	}
+	if len(result.Pages) != 3 {
+		t.Fatalf("expected 3 page results, got %d", len(result.Pages))
+	}
+	for i, page := range result.Pages {
+		if page.Index != i || page.Err == nil || page.Path != pagePath(out, i, 3) {
+			t.Errorf("unexpected page result %+v", page)
+		}
+	}
+
+	result, err = client.ConvertPdf(ctx, pdfPath, out, PdfOptions{DPI: 36, Strict: true})
+	if !errors.As(err, &pageErrs) || len(pageErrs) != 1 || pageErrs[0].Index != 0 {
+		t.Errorf("expected the first page to abort a strict conversion, got %v", err)
+	}
+	if len(result.Pages) != 1 {
+		t.Errorf("expected 1 page result, got %d", len(result.Pages))
+	}
+
+	// The legacy API reports failing pages too
+	if err := client.ConvertPdfToImages(pdfPath, out, 0, 100, false); !errors.As(err, &pageErrs) {
+		t.Errorf("expected PageErrors from ConvertPdfToImages, got %v", err)
+	}
+}
+
+// TestPageErrors tests the aggregate page error
+func TestPageErrors(t *testing.T) {
+	errWrite := errors.New("write failed")
+	err := error(PageErrors{
+		{Index: 1, Err: fmt.Errorf("%w: resize failed", ErrProcessing)},
+		{Index: 4, Err: errWrite},
+	})
+
+	if got, want := err.Error(), "2 pages failed: page 2: processing error: resize failed; page 5: write failed"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(err, ErrProcessing) || !errors.Is(err, errWrite) {
+		t.Error("expected PageErrors to wrap every page error")
+	}
+
+	var pageErr PageError
+	if !errors.As(err, &pageErr) || pageErr.Index != 1 {
+		t.Errorf("expected the first PageError, got %+v", pageErr)
+	}
+
+	single := PageErrors{{Index: 0, Err: errWrite}}
+	if got, want := single.Error(), "1 page failed: page 1: write failed"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,675 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// PdfPageMeta describes a page of a PDF document
+type PdfPageMeta struct {
+	// Width and Height are the size of the page's media box in points
+	// (1/72 inch), before Rotate is applied
+	Width  float64
+	Height float64
+
+	// Rotate is the clockwise rotation applied when the page is displayed:
+	// 0, 90, 180 or 270
+	Rotate int
+
+	// Text is set when the page shows text, and Images counts the images
+	// it draws, including those inside forms; fonts and images that are
+	// only listed in its resources do not count. A page with images but no
+	// visible text, such as a scanned receipt, is Scanned. Scanned pages
+	// without Text need OCR to extract their text; with Text, they already
+	// went through OCR, which adds the text as an invisible layer.
+	Text    bool
+	Images  int
+	Scanned bool
+}
+
+// PdfMeta describes a PDF document
+type PdfMeta struct {
+	Version   string // from the header, such as "1.7"
+	PageCount int
+	Pages     []PdfPageMeta
+
+	// Document information. Dates are zero if unknown. The information of
+	// encrypted documents is encrypted too, so it is left empty.
+	Title    string
+	Author   string
+	Subject  string
+	Creator  string
+	Producer string
+	Created  time.Time
+	Modified time.Time
+
+	// Encrypted is set for password-protected documents
+	Encrypted bool
+
+	// Pinged is set when the document structure could not be parsed and
+	// the pages were measured by ImageMagick instead. Page sizes then
+	// include the rotation, and page content is unknown.
+	Pinged bool
+}
+
+// pdfDefaultMediaBox is the page size of pages without a media box: US
+// Letter
+var pdfDefaultMediaBox = pdfArray{int64(0), int64(0), int64(612), int64(792)}
+
+// OpenPdf describes the PDF at path: page count, page sizes and rotation,
+// document information, encryption and whether pages are scanned. Unlike
+// OpenImage and ConvertPdf it does not rasterize: the document structure is
+// read directly, which takes milliseconds even for large documents. If the
+// structure cannot be parsed, such as when it is stored in encrypted object
+// streams, the pages are measured with a low-density ImageMagick ping and
+// Pinged is set; encrypted documents then need WithPdfPassword.
+func (c *Client) OpenPdf(ctx context.Context, path string, opts ...Option) (PdfMeta, error) {
+	var meta PdfMeta
+
+	if path == "" {
+		return meta, fmt.Errorf("%w: PDF path is empty", ErrInvalidInput)
+	}
+
+	s, err := c.settingsFor(opts)
+	if err != nil {
+		return meta, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return meta, fmt.Errorf("%w: failed to open PDF: %v", ErrInvalidInput, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return meta, fmt.Errorf("%w: failed to stat PDF: %v", ErrProcessing, err)
+	}
+	if err := checkInputSize(info.Size(), &s); err != nil {
+		return meta, err
+	}
+
+	s.logger.Info("OpenPdf", "In", path)
+	meta, err = readPdfMeta(f, info.Size())
+	if err == nil || errors.Is(err, ErrInvalidInput) {
+		return meta, err
+	}
+	s.logger.Warn("Failed to parse PDF structure, pinging instead", "error", err)
+
+	if !meta.Encrypted {
+		meta.Encrypted, _ = pdfEncrypted(f, info.Size())
+	}
+	if err := checkContext(ctx); err != nil {
+		return meta, err
+	}
+
+	release, err := c.pool.acquire(ctx)
+	if err != nil {
+		return meta, err
+	}
+	defer release()
+
+	return meta, pingPdf(path, &meta, &s)
+}
+
+// readPdfMeta describes the PDF in ra from its structure. On failure the
+// fields read so far are returned with the error.
+func readPdfMeta(ra io.ReaderAt, size int64) (PdfMeta, error) {
+	var meta PdfMeta
+
+	doc, err := openPdfDocument(ra, size)
+	if err != nil {
+		return meta, err
+	}
+	meta.Version = doc.version
+	_, meta.Encrypted = doc.trailer["Encrypt"]
+
+	pages, err := doc.pages()
+	if err != nil {
+		return meta, err
+	}
+
+	meta.PageCount = len(pages)
+	meta.Pages = make([]PdfPageMeta, len(pages))
+	for i, page := range pages {
+		meta.Pages[i] = doc.pageMeta(page, meta.Encrypted)
+	}
+
+	if !meta.Encrypted {
+		doc.readInfo(&meta)
+	}
+	return meta, nil
+}
+
+// pdfPageCountFromStructure counts the pages of the PDF at path from its
+// page tree
+func pdfPageCountFromStructure(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	doc, err := openPdfDocument(f, info.Size())
+	if err != nil {
+		return 0, err
+	}
+	pages, err := doc.pages()
+	return len(pages), err
+}
+
+// pingPdf measures the pages of the PDF at path with ImageMagick
+func pingPdf(path string, meta *PdfMeta, s *settings) error {
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+
+	// At 72 DPI a pixel is a point
+	if err := mw.SetResolution(72, 72); err != nil {
+		return fmt.Errorf("%w: could not set resolution: %v", ErrProcessing, err)
+	}
+	if err := setPdfPassword(mw, s); err != nil {
+		return err
+	}
+	if err := mw.PingImage(path); err != nil {
+		if perr := passwordError(path, s, err); perr != nil {
+			return perr
+		}
+		return fmt.Errorf("%w: failed to read PDF: %v", ErrProcessing, err)
+	}
+
+	meta.Pinged = true
+	meta.Pages = make([]PdfPageMeta, 0, mw.GetNumberImages())
+	forEachFrame(mw, func() error {
+		meta.Pages = append(meta.Pages, PdfPageMeta{
+			Width:  float64(mw.GetImageWidth()),
+			Height: float64(mw.GetImageHeight()),
+		})
+		return nil
+	})
+	meta.PageCount = len(meta.Pages)
+	return nil
+}
+
+// pdfInheritable lists the page attributes a page inherits from the nodes
+// of the page tree above it
+var pdfInheritable = []pdfName{"MediaBox", "Rotate", "Resources"}
+
+// pages returns the page dictionaries of the document in order, with
+// their inherited attributes filled in
+func (d *pdfDocument) pages() ([]pdfDict, error) {
+	catalog, err := d.resolveDict(d.trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	if catalog == nil {
+		return nil, fmt.Errorf("%w: document catalog not found", errPdfSyntax)
+	}
+
+	var pages []pdfDict
+	visited := make(map[int64]bool)
+
+	var walk func(node any, inherited pdfDict, depth int) error
+	walk = func(node any, inherited pdfDict, depth int) error {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref.num] {
+				return fmt.Errorf("%w: page tree loops", errPdfSyntax)
+			}
+			visited[ref.num] = true
+		}
+		if depth > pdfMaxNesting {
+			return fmt.Errorf("%w: page tree nested too deeply", errPdfSyntax)
+		}
+
+		dict, err := d.resolveDict(node)
+		if err != nil {
+			return err
+		}
+		if dict == nil {
+			return fmt.Errorf("%w: invalid page tree node", errPdfSyntax)
+		}
+
+		attrs := make(pdfDict, len(pdfInheritable))
+		for _, key := range pdfInheritable {
+			if value, ok := dict[key]; ok {
+				attrs[key] = value
+			} else if value, ok := inherited[key]; ok {
+				attrs[key] = value
+			}
+		}
+
+		kids, err := d.resolve(dict["Kids"])
+		if err != nil {
+			return err
+		}
+		isNode := dict["Type"] == pdfName("Pages") || (dict["Type"] != pdfName("Page") && kids != nil)
+		if !isNode {
+			page := make(pdfDict, len(dict)+len(attrs))
+			for key, value := range dict {
+				page[key] = value
+			}
+			for key, value := range attrs {
+				page[key] = value
+			}
+			pages = append(pages, page)
+			return nil
+		}
+
+		list, ok := kids.(pdfArray)
+		if !ok && kids != nil {
+			return fmt.Errorf("%w: invalid page tree node", errPdfSyntax)
+		}
+		for _, kid := range list {
+			if err := walk(kid, attrs, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(catalog["Pages"], nil, 0); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// pageMeta describes a page dictionary returned by pages, whose content is
+// encrypted if encrypted is set
+func (d *pdfDocument) pageMeta(page pdfDict, encrypted bool) PdfPageMeta {
+	var meta PdfPageMeta
+
+	box, _ := d.resolve(page["MediaBox"])
+	corners, ok := box.(pdfArray)
+	if !ok || len(corners) != 4 {
+		corners = pdfDefaultMediaBox
+	}
+	var coords [4]float64
+	for i, v := range corners {
+		v, _ = d.resolve(v)
+		coords[i] = pdfNumber(v)
+	}
+	meta.Width = max(coords[0], coords[2]) - min(coords[0], coords[2])
+	meta.Height = max(coords[1], coords[3]) - min(coords[1], coords[3])
+
+	rotate, _ := d.resolve(page["Rotate"])
+	if r, ok := rotate.(int64); ok {
+		// Normalize to a quarter turn between 0 and 270
+		meta.Rotate = int((r%360+360)%360) / 90 * 90
+	}
+
+	if !encrypted {
+		if content, err := d.pageContent(page["Contents"]); err == nil {
+			scan := &pdfPageScan{meta: &meta, seen: make(map[int64]bool)}
+			d.scanContent(content, page["Resources"], scan, 0, 0)
+			meta.Scanned = meta.Images > 0 && !scan.visible
+			return meta
+		}
+	}
+
+	// Content that is encrypted or cannot be decoded is judged by its
+	// resources
+	d.scanResources(page["Resources"], &meta, make(map[int64]bool), 0)
+	meta.Scanned = meta.Images > 0 && !meta.Text
+	return meta
+}
+
+// pdfPageScan gathers what the content of a page draws
+type pdfPageScan struct {
+	meta    *PdfPageMeta
+	visible bool           // text was shown in a visible render mode
+	seen    map[int64]bool // images and forms already counted
+}
+
+// pageContent returns the content of a page, whose streams are
+// concatenated
+func (d *pdfDocument) pageContent(contents any) ([]byte, error) {
+	obj, err := d.resolve(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	var streams pdfArray
+	switch obj := obj.(type) {
+	case nil:
+		return nil, nil
+	case pdfStream:
+		streams = pdfArray{obj}
+	case pdfArray:
+		streams = obj
+	default:
+		return nil, fmt.Errorf("%w: invalid page contents", errPdfSyntax)
+	}
+
+	var content []byte
+	for _, part := range streams {
+		obj, err := d.resolve(part)
+		if err != nil {
+			return nil, err
+		}
+		stream, ok := obj.(pdfStream)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid page contents", errPdfSyntax)
+		}
+		data, err := d.streamData(stream)
+		if err != nil {
+			return nil, err
+		}
+		if len(content)+len(data) > pdfMaxStreamSize {
+			return nil, fmt.Errorf("%w: page contents too large", errPdfSyntax)
+		}
+		content = append(append(content, data...), '\n')
+	}
+	return content, nil
+}
+
+// scanContent follows the operators of a content stream drawn with
+// resources: text showing operators, in the text render mode set by Tr,
+// and images and forms drawn with Do or inline. Form content is scanned in
+// turn, in the render mode it is drawn with. Malformed content ends the
+// scan.
+func (d *pdfDocument) scanContent(content []byte, resources any, scan *pdfPageScan, mode int64, depth int) {
+	dict, _ := d.resolveDict(resources)
+	xobjects, _ := d.resolveDict(dict["XObject"])
+
+	// Render modes saved by q, beyond which they are only counted
+	var saved []int64
+	var dropped int
+
+	var operand any
+	l := newPdfLexer(bytes.NewReader(content))
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return
+		}
+		op, ok := tok.(pdfKeyword)
+		if !ok {
+			operand = tok
+			continue
+		}
+
+		switch op {
+		case "[", "]", "<<", ">>", "{", "}":
+			// Delimiters of array and dictionary operands
+			continue
+		case "q":
+			if len(saved) < pdfMaxNesting {
+				saved = append(saved, mode)
+			} else {
+				dropped++
+			}
+		case "Q":
+			if dropped > 0 {
+				dropped--
+			} else if n := len(saved); n > 0 {
+				mode, saved = saved[n-1], saved[:n-1]
+			}
+		case "Tr":
+			if m, ok := operand.(int64); ok {
+				mode = m
+			}
+		case "Tj", "TJ", "'", "\"":
+			scan.meta.Text = true
+			// Modes 3 and 7 neither fill nor stroke the glyphs
+			if mode != 3 && mode != 7 {
+				scan.visible = true
+			}
+		case "Do":
+			if name, ok := operand.(pdfName); ok {
+				d.scanXObject(xobjects[name], resources, scan, mode, depth)
+			}
+		case "ID":
+			scan.meta.Images++
+			skipInlineImage(l)
+		}
+		operand = nil
+	}
+}
+
+// scanXObject scans an image or form drawn by content using resources
+func (d *pdfDocument) scanXObject(xobject, resources any, scan *pdfPageScan, mode int64, depth int) {
+	// Count images drawn repeatedly, and forms, once
+	if ref, ok := xobject.(pdfRef); ok {
+		if scan.seen[ref.num] {
+			return
+		}
+		scan.seen[ref.num] = true
+	}
+
+	obj, err := d.resolve(xobject)
+	if err != nil {
+		return
+	}
+	stream, ok := obj.(pdfStream)
+	if !ok {
+		return
+	}
+	switch stream.dict["Subtype"] {
+	case pdfName("Image"):
+		scan.meta.Images++
+	case pdfName("Form"):
+		if depth >= pdfMaxNesting {
+			return
+		}
+		content, err := d.streamData(stream)
+		if err != nil {
+			return
+		}
+		// Forms without resources use those of the page
+		if formResources, ok := stream.dict["Resources"]; ok {
+			resources = formResources
+		}
+		d.scanContent(content, resources, scan, mode, depth+1)
+	}
+}
+
+// skipInlineImage skips the data of an inline image after its "ID"
+// operator, up to the "EI" operator ending it
+func skipInlineImage(l *pdfLexer) {
+	var prev [2]byte
+	for {
+		c, err := l.readByte()
+		if err != nil {
+			return
+		}
+		if isPdfSpace(prev[0]) && prev[1] == 'E' && c == 'I' {
+			next, err := l.readByte()
+			if err != nil {
+				return
+			}
+			if isPdfSpace(next) || isPdfDelimiter(next) {
+				l.unreadByte()
+				return
+			}
+			prev = [2]byte{c, next}
+			continue
+		}
+		prev = [2]byte{prev[1], c}
+	}
+}
+
+// scanResources looks for the fonts and images of a resource dictionary,
+// following the forms it lists, for pages whose content cannot be decoded.
+// Unreadable resources are skipped.
+func (d *pdfDocument) scanResources(resources any, page *PdfPageMeta, seen map[int64]bool, depth int) {
+	dict, _ := d.resolveDict(resources)
+	if dict == nil || depth > pdfMaxNesting {
+		return
+	}
+
+	if fonts, _ := d.resolveDict(dict["Font"]); len(fonts) > 0 {
+		page.Text = true
+	}
+
+	xobjects, _ := d.resolveDict(dict["XObject"])
+	for _, xobject := range xobjects {
+		// Count images drawn repeatedly, and forms, once
+		if ref, ok := xobject.(pdfRef); ok {
+			if seen[ref.num] {
+				continue
+			}
+			seen[ref.num] = true
+		}
+
+		obj, err := d.resolve(xobject)
+		if err != nil {
+			continue
+		}
+		stream, ok := obj.(pdfStream)
+		if !ok {
+			continue
+		}
+		switch stream.dict["Subtype"] {
+		case pdfName("Image"):
+			page.Images++
+		case pdfName("Form"):
+			d.scanResources(stream.dict["Resources"], page, seen, depth+1)
+		}
+	}
+}
+
+// readInfo fills meta from the document information dictionary
+func (d *pdfDocument) readInfo(meta *PdfMeta) {
+	info, _ := d.resolveDict(d.trailer["Info"])
+	if info == nil {
+		return
+	}
+
+	text := func(key pdfName) string {
+		v, _ := d.resolve(info[key])
+		s, _ := v.(pdfString)
+		return decodePdfText(s)
+	}
+
+	meta.Title = text("Title")
+	meta.Author = text("Author")
+	meta.Subject = text("Subject")
+	meta.Creator = text("Creator")
+	meta.Producer = text("Producer")
+	meta.Created, _ = parsePdfDate(text("CreationDate"))
+	meta.Modified, _ = parsePdfDate(text("ModDate"))
+}
+
+// pdfNumber returns the value of an integer or real, or 0
+func pdfNumber(v any) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// pdfDocEncoding maps the bytes of PDFDocEncoding that differ from Latin-1
+var pdfDocEncoding = map[byte]rune{
+	0x80: '•', 0x81: '†', 0x82: '‡', 0x83: '…',
+	0x84: '—', 0x85: '–', 0x86: 'ƒ', 0x87: '⁄',
+	0x88: '‹', 0x89: '›', 0x8a: '−', 0x8b: '‰',
+	0x8c: '„', 0x8d: '“', 0x8e: '”', 0x8f: '‘',
+	0x90: '’', 0x91: '‚', 0x92: '™', 0x93: 'ﬁ',
+	0x94: 'ﬂ', 0x95: 'Ł', 0x96: 'Œ', 0x97: 'Š',
+	0x98: 'Ÿ', 0x99: 'Ž', 0x9a: 'ı', 0x9b: 'ł',
+	0x9c: 'œ', 0x9d: 'š', 0x9e: 'ž', 0x9f: '�',
+	0xa0: '€',
+}
+
+// decodePdfText decodes a PDF text string: UTF-16BE or UTF-8 with a byte
+// order mark, otherwise PDFDocEncoding
+func decodePdfText(s []byte) string {
+	switch {
+	case len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff:
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	case len(s) >= 3 && s[0] == 0xef && s[1] == 0xbb && s[2] == 0xbf:
+		return strings.ToValidUTF8(string(s[3:]), "�")
+	}
+
+	var b strings.Builder
+	for _, c := range s {
+		if r, ok := pdfDocEncoding[c]; ok {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// parsePdfDate parses a PDF date such as "D:20240131093000+01'00'". Every
+// field after the year is optional; dates without a time zone are taken
+// as UTC.
+func parsePdfDate(date string) (time.Time, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(date), "D:")
+
+	// Year, month, day, hour, minute and second, with their defaults
+	fields := [6]int{0, 1, 1, 0, 0, 0}
+	widths := [6]int{4, 2, 2, 2, 2, 2}
+	for i, width := range widths {
+		if len(s) < width || !isDigits(s[:width]) {
+			if i == 0 {
+				return time.Time{}, fmt.Errorf("invalid PDF date %q", date)
+			}
+			break
+		}
+		fields[i] = digits(s[:width])
+		s = s[width:]
+	}
+
+	if fields[1] < 1 || fields[1] > 12 || fields[2] < 1 || fields[2] > 31 ||
+		fields[3] > 23 || fields[4] > 59 || fields[5] > 59 {
+		return time.Time{}, fmt.Errorf("invalid PDF date %q", date)
+	}
+
+	loc := time.UTC
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		// The offset is written as HH'mm', with optional apostrophes
+		zone := strings.ReplaceAll(s[1:], "'", "")
+		if len(zone) < 2 || !isDigits(zone[:2]) {
+			return time.Time{}, fmt.Errorf("invalid PDF date time zone %q", date)
+		}
+		offset := digits(zone[:2]) * 60
+		if len(zone) >= 4 && isDigits(zone[2:4]) {
+			offset += digits(zone[2:4])
+		}
+		if s[0] == '-' {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset*60)
+	}
+
+	return time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, loc), nil
+}
+
+// isDigits reports whether s consists of ASCII digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// digits converts a string checked by isDigits
+func digits(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
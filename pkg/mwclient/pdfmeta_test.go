@@ -0,0 +1,226 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// statementObjects describe a 4-page document: an A4 text page that does
+// not draw the logo it inherits, a rotated Letter scan, an A4 page whose form
+// draws both text and an image and an A4 scan with an invisible OCR layer
+var statementObjects = []string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R 5 0 R 12 0 R] /Count 4 /MediaBox [0 0 595 842] /Resources << /Font << /F1 7 0 R >> /XObject << /Logo 9 0 R >> >> >>",
+	"<< /Type /Page /Parent 2 0 R /Contents 13 0 R >>",
+	"<< /Type /Page /Parent 5 0 R /MediaBox [0 0 612 792] /Contents [14 0 R 15 0 R] >>",
+	"<< /Type /Pages /Parent 2 0 R /Kids [4 0 R 6 0 R] /Count 2 /Rotate 90 /Resources 8 0 R >>",
+	"<< /Type /Page /Parent 5 0 R /Rotate -90 /Resources << /XObject << /Fm1 10 0 R >> >> /Contents 16 0 R >>",
+	"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	"<< /XObject << /Im1 9 0 R /Im2 9 0 R >> >>",
+	"<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 /Length 1 >>\nstream\n\x80\nendstream",
+	"<< /Type /XObject /Subtype /Form /BBox [0 0 10 10] /Resources << /Font << /F1 7 0 R >> /XObject << /Im 9 0 R >> >> " +
+		pdfContent("BT /F1 9 Tf [(To) -20 (tal)] TJ ET /Im Do"),
+	"<< /Title (Statement \\(March\\)) /Author <FEFF004A006F00EB> /Producer (Scanner\\222 9000) /CreationDate (D:20240131093000+01'00') >>",
+	"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 7 0 R >> /XObject << /Scan 9 0 R >> >> /Contents 17 0 R >>",
+	"<< " + pdfContent("BT /F1 12 Tf 72 720 Td (Statement /Logo Do) Tj ET"),
+	"<< " + pdfContent("q 612 0 0 792 0 0 cm /Im1 Do Q"),
+	"<< " + pdfContent("/Im2 Do BI /W 1 /H 1 /CS /G /BPC 8 ID \xff\nEI"),
+	"<< " + pdfContent("q /Fm1 Do Q"),
+	"<< " + pdfContent("q 595 0 0 842 0 0 cm /Scan Do Q BT 3 Tr /F1 12 Tf (Total 42.00) Tj ET"),
+}
+
+// pdfContent ends the dictionary of a content stream holding content
+func pdfContent(content string) string {
+	return fmt.Sprintf("/Length %d >>\nstream\n%s\nendstream", len(content), content)
+}
+
+// TestReadPdfMeta tests describing a PDF from its structure
+func TestReadPdfMeta(t *testing.T) {
+	want := PdfMeta{
+		PageCount: 4,
+		Pages: []PdfPageMeta{
+			{Width: 595, Height: 842, Text: true},
+			{Width: 612, Height: 792, Rotate: 90, Images: 2, Scanned: true},
+			{Width: 595, Height: 842, Rotate: 270, Text: true, Images: 1},
+			{Width: 595, Height: 842, Text: true, Images: 1, Scanned: true},
+		},
+		Title:    "Statement (March)",
+		Author:   "Joë",
+		Producer: "Scanner™ 9000",
+		Created:  time.Date(2024, 1, 31, 8, 30, 0, 0, time.UTC),
+	}
+
+	trailer := "/Root 1 0 R /Info 11 0 R"
+	for name, data := range map[string][]byte{
+		"table":  buildPdf(statementObjects, trailer),
+		"stream": buildCompressedPdf(statementObjects, trailer),
+	} {
+		t.Run(name, func(t *testing.T) {
+			meta, err := readPdfMeta(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("readPdfMeta failed: %v", err)
+			}
+
+			if !meta.Created.Equal(want.Created) {
+				t.Errorf("Created = %v, want %v", meta.Created, want.Created)
+			}
+			meta.Created = want.Created
+			meta.Version = ""
+			if !reflect.DeepEqual(meta, want) {
+				t.Errorf("got %+v, want %+v", meta, want)
+			}
+		})
+	}
+
+	t.Run("encrypted", func(t *testing.T) {
+		objects := append(statementObjects[:len(statementObjects):len(statementObjects)], "<< /Filter /Standard /V 2 /R 3 >>")
+		data := buildPdf(objects, fmt.Sprintf("%s /Encrypt %d 0 R", trailer, len(objects)))
+
+		meta, err := readPdfMeta(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("readPdfMeta failed: %v", err)
+		}
+		if !meta.Encrypted || meta.PageCount != 4 || meta.Version != "1.4" {
+			t.Errorf("expected an encrypted 4-page PDF 1.4, got %+v", meta)
+		}
+		// The information strings are encrypted
+		if meta.Title != "" {
+			t.Errorf("expected no title, got %q", meta.Title)
+		}
+	})
+
+	t.Run("page tree loop", func(t *testing.T) {
+		data := buildPdf([]string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [2 0 R] /Count 1 >>",
+		}, "/Root 1 0 R")
+		if _, err := readPdfMeta(bytes.NewReader(data), int64(len(data))); !errors.Is(err, errPdfSyntax) {
+			t.Errorf("expected errPdfSyntax, got %v", err)
+		}
+	})
+}
+
+// TestParsePdfDate tests parsing PDF dates
+func TestParsePdfDate(t *testing.T) {
+	tests := []struct {
+		date string
+		want time.Time
+	}{
+		{"D:20240131093000+01'00'", time.Date(2024, 1, 31, 8, 30, 0, 0, time.UTC)},
+		{"D:20240131093000-05'30", time.Date(2024, 1, 31, 15, 0, 0, 0, time.UTC)},
+		{"D:20240131093000Z", time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC)},
+		{"D:2024013109", time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{"2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePdfDate(tt.date)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.date, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.date, got, tt.want)
+		}
+	}
+
+	for _, date := range []string{"", "D:", "D:20241301", "D:20240131250000", "D:20240131093000+x"} {
+		if _, err := parsePdfDate(date); err == nil {
+			t.Errorf("%q: expected an error", date)
+		}
+	}
+}
+
+// TestDecodePdfText tests decoding PDF text strings
+func TestDecodePdfText(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"Plain", "Plain"},
+		{"caf\xe9 \x93 \x84 \xa0", "café ﬁ — €"},
+		{"\xfe\xff\x00\x4a\x00\x6f\x00\xeb\xd8\x3d\xde\x00", "Joë😀"},
+		{"\xef\xbb\xbfJo\xc3\xab", "Joë"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := decodePdfText([]byte(tt.s)); got != tt.want {
+			t.Errorf("decodePdfText(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestOpenPdf tests describing PDFs without rasterizing
+func TestOpenPdf(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Pages assembled from images are scanned pages
+	var inputs []string
+	for i := 0; i < 2; i++ {
+		path := filepath.Join(dir, filepath.Base(pagePath("in.png", i, 2)))
+		if err := os.WriteFile(path, testPNG(t, 100+100*i, 200), 0o644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		inputs = append(inputs, path)
+	}
+	pdfPath := filepath.Join(dir, "scan.pdf")
+	if err := client.AssemblePDFFile(ctx, inputs, pdfPath, PDFLayout{DPI: 72}); err != nil {
+		t.Fatalf("AssemblePDFFile failed: %v", err)
+	}
+
+	meta, err := client.OpenPdf(ctx, pdfPath)
+	if err != nil {
+		t.Fatalf("OpenPdf failed: %v", err)
+	}
+	if meta.PageCount != 2 || meta.Encrypted || meta.Pinged {
+		t.Fatalf("expected 2 parsed pages, got %+v", meta)
+	}
+	for i, page := range meta.Pages {
+		if page.Width != float64(100+100*i) || page.Height != 200 || !page.Scanned {
+			t.Errorf("page %d: unexpected %+v", i+1, page)
+		}
+	}
+
+	// Documents whose structure cannot be parsed are pinged
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		t.Fatalf("Failed to read PDF: %v", err)
+	}
+	damaged := filepath.Join(dir, "damaged.pdf")
+	data = bytes.ReplaceAll(data, []byte("startxref"), []byte("startxxxx"))
+	if err := os.WriteFile(damaged, data, 0o644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	meta, err = client.OpenPdf(ctx, damaged)
+	if err != nil {
+		t.Fatalf("OpenPdf failed on a damaged PDF: %v", err)
+	}
+	if !meta.Pinged || meta.PageCount != 2 {
+		t.Errorf("expected 2 pinged pages, got %+v", meta)
+	}
+
+	pngPath := inputs[0]
+	if _, err := client.OpenPdf(ctx, pngPath); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for a PNG, got %v", err)
+	}
+	if _, err := client.OpenPdf(ctx, filepath.Join(dir, "missing.pdf")); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for a missing file, got %v", err)
+	}
+}
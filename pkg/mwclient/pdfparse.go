@@ -0,0 +1,979 @@
+package mwclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// This file holds a minimal PDF object parser: enough to follow the cross
+// reference table, object streams and page tree of a document. Page content
+// is only tokenized, by pdfmeta.go.
+
+// PDF objects are represented as nil (null), bool, int64, float64,
+// pdfString, pdfName, pdfArray, pdfDict, pdfStream and pdfRef
+type (
+	pdfName    string
+	pdfString  []byte
+	pdfArray   []any
+	pdfDict    map[pdfName]any
+	pdfKeyword string // operators and delimiters such as "obj" and "<<"
+)
+
+// pdfRef is an indirect reference to an object
+type pdfRef struct {
+	num, gen int64
+}
+
+// pdfStream is a stream object; its data is read on demand
+type pdfStream struct {
+	dict   pdfDict
+	offset int64 // file offset of the stream data
+}
+
+// Limits protecting the parser from malformed or hostile documents
+const (
+	pdfMaxNesting     = 64       // nested arrays and dictionaries
+	pdfMaxRefDepth    = 32       // references to references
+	pdfMaxXrefSection = 64       // cross reference sections chained by /Prev
+	pdfMaxStreamSize  = 64 << 20 // decoded cross reference and object streams
+	pdfTailSize       = 1024     // bytes searched for "startxref"
+)
+
+// errPdfSyntax is returned for malformed PDF syntax
+var errPdfSyntax = errors.New("malformed PDF")
+
+// pdfLexer reads PDF tokens and objects
+type pdfLexer struct {
+	r       *bufio.Reader
+	pos     int64 // offset of the next byte from the start of the input
+	pending []any // tokens read ahead, last one first
+	nesting int
+}
+
+// newPdfLexer returns a lexer reading from r
+func newPdfLexer(r io.Reader) *pdfLexer {
+	return &pdfLexer{r: bufio.NewReader(r)}
+}
+
+// readByte reads the next byte, keeping track of the position
+func (l *pdfLexer) readByte() (byte, error) {
+	c, err := l.r.ReadByte()
+	if err == nil {
+		l.pos++
+	}
+	return c, err
+}
+
+// unreadByte steps back over the last byte read
+func (l *pdfLexer) unreadByte() {
+	if l.r.UnreadByte() == nil {
+		l.pos--
+	}
+}
+
+// isPdfSpace reports whether c is PDF whitespace
+func isPdfSpace(c byte) bool {
+	return strings.IndexByte("\x00\t\n\f\r ", c) >= 0
+}
+
+// unread pushes tok back to be returned by the next call to token
+func (l *pdfLexer) unread(tok any) {
+	l.pending = append(l.pending, tok)
+}
+
+// token returns the next token: a delimiter or keyword as pdfKeyword, or a
+// name, string, number or boolean
+func (l *pdfLexer) token() (any, error) {
+	if n := len(l.pending); n > 0 {
+		tok := l.pending[n-1]
+		l.pending = l.pending[:n-1]
+		return tok, nil
+	}
+
+	c, err := l.skipSpace()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case '/':
+		return l.name()
+	case '(':
+		return l.literalString()
+	case '<':
+		next, err := l.readByte()
+		if err == nil && next == '<' {
+			return pdfKeyword("<<"), nil
+		}
+		if err == nil {
+			l.unreadByte()
+		}
+		return l.hexString()
+	case '>':
+		if next, err := l.readByte(); err == nil && next == '>' {
+			return pdfKeyword(">>"), nil
+		}
+		return nil, fmt.Errorf("%w: unexpected '>'", errPdfSyntax)
+	case '[', ']', '{', '}':
+		return pdfKeyword(string(rune(c))), nil
+	case ')':
+		return nil, fmt.Errorf("%w: unexpected ')'", errPdfSyntax)
+	}
+
+	// Numbers and keywords run to the next delimiter
+	word := []byte{c}
+	for {
+		c, err := l.readByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if isPdfDelimiter(c) {
+			l.unreadByte()
+			break
+		}
+		word = append(word, c)
+	}
+	return pdfWord(string(word)), nil
+}
+
+// skipSpace skips whitespace and comments and returns the next byte
+func (l *pdfLexer) skipSpace() (byte, error) {
+	for {
+		c, err := l.readByte()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case isPdfSpace(c):
+		case c == '%':
+			for c != '\r' && c != '\n' {
+				if c, err = l.readByte(); err != nil {
+					return 0, err
+				}
+			}
+		default:
+			return c, nil
+		}
+	}
+}
+
+// pdfWord converts a number or keyword
+func pdfWord(word string) any {
+	if strings.IndexByte("+-.0123456789", word[0]) >= 0 {
+		if n, err := strconv.ParseInt(word, 10, 64); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(word, 64); err == nil {
+			return f
+		}
+	}
+
+	switch word {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return pdfKeyword(word)
+}
+
+// name reads a name after its '/', decoding #xx escapes
+func (l *pdfLexer) name() (pdfName, error) {
+	var name []byte
+	for {
+		c, err := l.readByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if isPdfDelimiter(c) {
+			l.unreadByte()
+			break
+		}
+		name = append(name, c)
+	}
+
+	if bytes.IndexByte(name, '#') < 0 {
+		return pdfName(name), nil
+	}
+	decoded := make([]byte, 0, len(name))
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if b, err := hex.DecodeString(string(name[i+1 : i+3])); err == nil {
+				decoded = append(decoded, b[0])
+				i += 2
+				continue
+			}
+		}
+		decoded = append(decoded, name[i])
+	}
+	return pdfName(decoded), nil
+}
+
+// literalString reads a string after its '(', up to the matching ')'
+func (l *pdfLexer) literalString() (pdfString, error) {
+	var s []byte
+	depth := 1
+	for {
+		c, err := l.readByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: unterminated string", errPdfSyntax)
+		}
+
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return s, nil
+			}
+		case '\\':
+			if c, err = l.readByte(); err != nil {
+				return nil, fmt.Errorf("%w: unterminated string", errPdfSyntax)
+			}
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A backslash at the end of a line continues the string
+				if next, err := l.readByte(); err == nil && (c != '\r' || next != '\n') {
+					l.unreadByte()
+				}
+				continue
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				n := int(c - '0')
+				for i := 0; i < 2; i++ {
+					next, err := l.readByte()
+					if err != nil {
+						break
+					}
+					if next < '0' || next > '7' {
+						l.unreadByte()
+						break
+					}
+					n = n*8 + int(next-'0')
+				}
+				c = byte(n)
+			}
+		}
+		s = append(s, c)
+	}
+}
+
+// hexString reads a hexadecimal string after its '<'
+func (l *pdfLexer) hexString() (pdfString, error) {
+	var digits []byte
+	for {
+		c, err := l.readByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: unterminated hex string", errPdfSyntax)
+		}
+		if c == '>' {
+			break
+		}
+		if !isPdfSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+
+	// A missing final digit is taken as 0
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid hex string", errPdfSyntax)
+	}
+	return s, nil
+}
+
+// object reads the next object, resolving "num gen R" into a pdfRef
+func (l *pdfLexer) object() (any, error) {
+	tok, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case pdfKeyword:
+		switch t {
+		case "<<":
+			return l.dict()
+		case "[":
+			return l.array()
+		case "null":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: unexpected %q", errPdfSyntax, string(t))
+	case int64:
+		return l.maybeRef(t), nil
+	}
+	return tok, nil
+}
+
+// maybeRef completes a reference starting with num, or returns num if the
+// following tokens are not "gen R"
+func (l *pdfLexer) maybeRef(num int64) any {
+	gen, err := l.token()
+	if err != nil {
+		return num
+	}
+	if _, ok := gen.(int64); ok {
+		r, err := l.token()
+		if err == nil && r == pdfKeyword("R") {
+			return pdfRef{num: num, gen: gen.(int64)}
+		}
+		if err == nil {
+			l.unread(r)
+		}
+	}
+	l.unread(gen)
+	return num
+}
+
+// nest guards against documents nesting containers too deeply
+func (l *pdfLexer) nest() error {
+	if l.nesting++; l.nesting > pdfMaxNesting {
+		return fmt.Errorf("%w: objects nested too deeply", errPdfSyntax)
+	}
+	return nil
+}
+
+// dict reads a dictionary after its "<<"
+func (l *pdfLexer) dict() (pdfDict, error) {
+	if err := l.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { l.nesting-- }()
+
+	d := make(pdfDict)
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: unterminated dictionary", errPdfSyntax)
+		}
+		if tok == pdfKeyword(">>") {
+			return d, nil
+		}
+		key, ok := tok.(pdfName)
+		if !ok {
+			return nil, fmt.Errorf("%w: dictionary key is not a name", errPdfSyntax)
+		}
+
+		value, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		d[key] = value
+	}
+}
+
+// array reads an array after its '['
+func (l *pdfLexer) array() (pdfArray, error) {
+	if err := l.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { l.nesting-- }()
+
+	var a pdfArray
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: unterminated array", errPdfSyntax)
+		}
+		if tok == pdfKeyword("]") {
+			return a, nil
+		}
+
+		l.unread(tok)
+		value, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, value)
+	}
+}
+
+// pdfXref locates an object: at a file offset, or at an index in an object
+// stream
+type pdfXref struct {
+	offset int64 // file offset, or index in the object stream
+	stream int64 // number of the object stream; 0 if not compressed
+}
+
+// pdfObjStm is a decoded object stream
+type pdfObjStm struct {
+	data    []byte
+	nums    []int64 // object numbers, in stream order
+	offsets []int64 // offsets of the objects in data
+}
+
+// pdfDocument reads objects from a PDF file through its cross reference
+// table
+type pdfDocument struct {
+	ra      io.ReaderAt
+	size    int64
+	version string
+	xref    map[int64]pdfXref
+	trailer pdfDict
+	cache   map[int64]any
+	objStms map[int64]*pdfObjStm
+	loading map[int64]bool // object streams being decoded
+}
+
+// openPdfDocument reads the header and cross reference sections of the PDF
+// in ra. Input that is not a PDF fails with ErrInvalidInput; a malformed
+// cross reference table with errPdfSyntax.
+func openPdfDocument(ra io.ReaderAt, size int64) (*pdfDocument, error) {
+	header := make([]byte, min(size, 32))
+	if _, err := ra.ReadAt(header, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.HasPrefix(header, pdfMagic) {
+		return nil, fmt.Errorf("%w: not a PDF", ErrInvalidInput)
+	}
+
+	d := &pdfDocument{
+		ra:      ra,
+		size:    size,
+		xref:    make(map[int64]pdfXref),
+		trailer: make(pdfDict),
+		cache:   make(map[int64]any),
+		objStms: make(map[int64]*pdfObjStm),
+		loading: make(map[int64]bool),
+	}
+
+	version := header[len(pdfMagic):]
+	if i := bytes.IndexFunc(version, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); i >= 0 {
+		version = version[:i]
+	}
+	d.version = string(version)
+
+	offset, err := d.startXref()
+	if err != nil {
+		return nil, err
+	}
+
+	// Newer sections come first and take precedence over the older ones
+	// they chain to with /Prev
+	seen := make(map[int64]bool)
+	for len(seen) < pdfMaxXrefSection && !seen[offset] {
+		seen[offset] = true
+
+		trailer, err := d.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range trailer {
+			if _, ok := d.trailer[key]; !ok {
+				d.trailer[key] = value
+			}
+		}
+
+		// Hybrid files keep compressed objects in an extra xref stream
+		if stm, ok := trailer["XRefStm"].(int64); ok && !seen[stm] {
+			seen[stm] = true
+			if _, err := d.readXref(stm); err != nil {
+				return nil, err
+			}
+		}
+
+		prev, ok := trailer["Prev"].(int64)
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+
+	if _, ok := d.trailer["Root"].(pdfRef); !ok {
+		return nil, fmt.Errorf("%w: trailer has no document catalog", errPdfSyntax)
+	}
+	return d, nil
+}
+
+// startXref returns the offset of the last cross reference section
+func (d *pdfDocument) startXref() (int64, error) {
+	n := min(d.size, pdfTailSize)
+	tail := make([]byte, n)
+	if _, err := d.ra.ReadAt(tail, d.size-n); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return 0, fmt.Errorf("%w: startxref not found", errPdfSyntax)
+	}
+	tok, err := newPdfLexer(bytes.NewReader(tail[i+len("startxref"):])).token()
+	offset, ok := tok.(int64)
+	if err != nil || !ok || offset < 0 || offset >= d.size {
+		return 0, fmt.Errorf("%w: invalid startxref", errPdfSyntax)
+	}
+	return offset, nil
+}
+
+// section returns a lexer reading the file from offset
+func (d *pdfDocument) section(offset int64) *pdfLexer {
+	return newPdfLexer(io.NewSectionReader(d.ra, offset, d.size-offset))
+}
+
+// addXref records the location of object num unless a newer section has
+func (d *pdfDocument) addXref(num int64, entry pdfXref) {
+	if _, ok := d.xref[num]; !ok {
+		d.xref[num] = entry
+	}
+}
+
+// readXref reads the cross reference section at offset, a table or a
+// stream, and returns its trailer dictionary
+func (d *pdfDocument) readXref(offset int64) (pdfDict, error) {
+	l := d.section(offset)
+	tok, err := l.token()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cross reference section not found", errPdfSyntax)
+	}
+	if tok == pdfKeyword("xref") {
+		return d.readXrefTable(l)
+	}
+
+	l.unread(tok)
+	obj, err := d.indirectObject(l, offset, -1)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(pdfStream)
+	if !ok || stream.dict["Type"] != pdfName("XRef") {
+		return nil, fmt.Errorf("%w: cross reference section not found", errPdfSyntax)
+	}
+	return stream.dict, d.readXrefStream(stream)
+}
+
+// readXrefTable reads the subsections of a classic cross reference table,
+// after its "xref" keyword, and the trailer that follows
+func (d *pdfDocument) readXrefTable(l *pdfLexer) (pdfDict, error) {
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: trailer not found", errPdfSyntax)
+		}
+		if tok == pdfKeyword("trailer") {
+			if tok, err := l.token(); err != nil || tok != pdfKeyword("<<") {
+				return nil, fmt.Errorf("%w: invalid trailer", errPdfSyntax)
+			}
+			return l.dict()
+		}
+
+		first, ok := tok.(int64)
+		countTok, err := l.token()
+		count, ok2 := countTok.(int64)
+		// Every entry takes 20 bytes
+		if err != nil || !ok || !ok2 || first < 0 || count < 0 || count > d.size/20 {
+			return nil, fmt.Errorf("%w: invalid cross reference subsection", errPdfSyntax)
+		}
+
+		for i := int64(0); i < count; i++ {
+			offsetTok, err1 := l.token()
+			_, err2 := l.token()
+			kind, err3 := l.token()
+			offset, ok := offsetTok.(int64)
+			if err := errors.Join(err1, err2, err3); err != nil || !ok {
+				return nil, fmt.Errorf("%w: invalid cross reference entry", errPdfSyntax)
+			}
+			if kind == pdfKeyword("n") {
+				d.addXref(first+i, pdfXref{offset: offset})
+			}
+		}
+	}
+}
+
+// readXrefStream records the entries of a cross reference stream
+func (d *pdfDocument) readXrefStream(stream pdfStream) error {
+	data, err := d.streamData(stream)
+	if err != nil {
+		return err
+	}
+
+	w, ok := stream.dict["W"].(pdfArray)
+	if !ok || len(w) != 3 {
+		return fmt.Errorf("%w: invalid cross reference stream widths", errPdfSyntax)
+	}
+	var widths [3]int
+	rowLen := 0
+	for i, v := range w {
+		n, ok := v.(int64)
+		if !ok || n < 0 || n > 8 {
+			return fmt.Errorf("%w: invalid cross reference stream widths", errPdfSyntax)
+		}
+		widths[i] = int(n)
+		rowLen += int(n)
+	}
+	if rowLen == 0 {
+		return fmt.Errorf("%w: invalid cross reference stream widths", errPdfSyntax)
+	}
+
+	// Subsections are pairs of first object number and count
+	index, ok := stream.dict["Index"].(pdfArray)
+	if !ok {
+		size, _ := stream.dict["Size"].(int64)
+		index = pdfArray{int64(0), size}
+	}
+
+	field := func(row []byte, i int) int64 {
+		var v int64
+		for _, b := range row[:widths[i]] {
+			v = v<<8 | int64(b)
+		}
+		return v
+	}
+
+	for i := 0; i+1 < len(index); i += 2 {
+		first, ok1 := index[i].(int64)
+		count, ok2 := index[i+1].(int64)
+		if !ok1 || !ok2 || first < 0 || count < 0 {
+			return fmt.Errorf("%w: invalid cross reference stream index", errPdfSyntax)
+		}
+
+		for n := int64(0); n < count && len(data) >= rowLen; n++ {
+			row := data[:rowLen]
+			data = data[rowLen:]
+
+			kind := int64(1) // the type defaults to 1 when its width is 0
+			if widths[0] > 0 {
+				kind = field(row, 0)
+			}
+			row = row[widths[0]:]
+			a := field(row, 1)
+			b := field(row[widths[1]:], 2)
+
+			switch kind {
+			case 1:
+				d.addXref(first+n, pdfXref{offset: a})
+			case 2:
+				d.addXref(first+n, pdfXref{stream: a, offset: b})
+			}
+		}
+	}
+	return nil
+}
+
+// indirectObject reads "num gen obj" and the object that follows. num -1
+// accepts any object number.
+func (d *pdfDocument) indirectObject(l *pdfLexer, offset, num int64) (any, error) {
+	numTok, err1 := l.token()
+	_, err2 := l.token()
+	objTok, err3 := l.token()
+	if err := errors.Join(err1, err2, err3); err != nil || objTok != pdfKeyword("obj") {
+		return nil, fmt.Errorf("%w: object not found at offset %d", errPdfSyntax, offset)
+	}
+	if n, ok := numTok.(int64); !ok || (num >= 0 && n != num) {
+		return nil, fmt.Errorf("%w: object %d not found at offset %d", errPdfSyntax, num, offset)
+	}
+
+	obj, err := l.object()
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := obj.(pdfDict)
+	if !ok {
+		return obj, nil
+	}
+	if tok, err := l.token(); err != nil || tok != pdfKeyword("stream") {
+		return dict, nil
+	}
+
+	// The data starts after the end of line following "stream"
+	if c, err := l.readByte(); err == nil && c == '\r' {
+		if c, err := l.readByte(); err == nil && c != '\n' {
+			l.unreadByte()
+		}
+	} else if err == nil && c != '\n' {
+		l.unreadByte()
+	}
+	return pdfStream{dict: dict, offset: offset + l.pos}, nil
+}
+
+// object returns object num, or nil if the document has no such object
+func (d *pdfDocument) object(num int64) (any, error) {
+	if obj, ok := d.cache[num]; ok {
+		return obj, nil
+	}
+
+	entry, ok := d.xref[num]
+	if !ok {
+		return nil, nil
+	}
+
+	var obj any
+	var err error
+	if entry.stream == 0 {
+		if entry.offset < 0 || entry.offset >= d.size {
+			return nil, fmt.Errorf("%w: object %d out of range", errPdfSyntax, num)
+		}
+		obj, err = d.indirectObject(d.section(entry.offset), entry.offset, num)
+	} else {
+		obj, err = d.compressedObject(entry.stream, entry.offset, num)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.cache[num] = obj
+	return obj, nil
+}
+
+// compressedObject reads the object at index in object stream stm
+func (d *pdfDocument) compressedObject(stm, index, num int64) (any, error) {
+	objStm, ok := d.objStms[stm]
+	if !ok {
+		// An object stream stored in itself, or in a stream stored in it,
+		// would be decoded forever
+		if d.loading[stm] {
+			return nil, fmt.Errorf("%w: object stream %d contains itself", errPdfSyntax, stm)
+		}
+		d.loading[stm] = true
+		var err error
+		objStm, err = d.readObjStm(stm)
+		delete(d.loading, stm)
+		if err != nil {
+			return nil, fmt.Errorf("object stream %d: %w", stm, err)
+		}
+		d.objStms[stm] = objStm
+	}
+
+	if index < 0 || index >= int64(len(objStm.nums)) || objStm.nums[index] != num {
+		return nil, fmt.Errorf("%w: object %d not found in object stream %d", errPdfSyntax, num, stm)
+	}
+	start := objStm.offsets[index]
+	if start < 0 || start > int64(len(objStm.data)) {
+		return nil, fmt.Errorf("%w: object %d out of range", errPdfSyntax, num)
+	}
+	return newPdfLexer(bytes.NewReader(objStm.data[start:])).object()
+}
+
+// readObjStm decodes object stream num
+func (d *pdfDocument) readObjStm(num int64) (*pdfObjStm, error) {
+	obj, err := d.object(num)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(pdfStream)
+	if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+		return nil, fmt.Errorf("%w: not an object stream", errPdfSyntax)
+	}
+
+	data, err := d.streamData(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	n, ok1 := stream.dict["N"].(int64)
+	first, ok2 := stream.dict["First"].(int64)
+	if !ok1 || !ok2 || n < 0 || first < 0 || first > int64(len(data)) || n > first {
+		return nil, fmt.Errorf("%w: invalid object stream header", errPdfSyntax)
+	}
+
+	// The header lists pairs of object number and offset from First
+	objStm := &pdfObjStm{data: data[first:]}
+	l := newPdfLexer(bytes.NewReader(data[:first]))
+	for i := int64(0); i < n; i++ {
+		numTok, err1 := l.token()
+		offsetTok, err2 := l.token()
+		num, ok1 := numTok.(int64)
+		offset, ok2 := offsetTok.(int64)
+		if err := errors.Join(err1, err2); err != nil || !ok1 || !ok2 {
+			return nil, fmt.Errorf("%w: invalid object stream header", errPdfSyntax)
+		}
+		objStm.nums = append(objStm.nums, num)
+		objStm.offsets = append(objStm.offsets, offset)
+	}
+	return objStm, nil
+}
+
+// resolve follows references until it reaches a direct object
+func (d *pdfDocument) resolve(obj any) (any, error) {
+	for i := 0; i < pdfMaxRefDepth; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj, nil
+		}
+		var err error
+		if obj, err = d.object(ref.num); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w: references nested too deeply", errPdfSyntax)
+}
+
+// resolveDict resolves obj to a dictionary, or the dictionary of a stream.
+// It returns nil for any other object.
+func (d *pdfDocument) resolveDict(obj any) (pdfDict, error) {
+	obj, err := d.resolve(obj)
+	if err != nil {
+		return nil, err
+	}
+	switch v := obj.(type) {
+	case pdfDict:
+		return v, nil
+	case pdfStream:
+		return v.dict, nil
+	}
+	return nil, nil
+}
+
+// streamData reads and decodes the data of stream
+func (d *pdfDocument) streamData(stream pdfStream) ([]byte, error) {
+	lengthObj, err := d.resolve(stream.dict["Length"])
+	if err != nil {
+		return nil, err
+	}
+	length, ok := lengthObj.(int64)
+	if !ok || length < 0 || length > d.size-stream.offset {
+		return nil, fmt.Errorf("%w: invalid stream length", errPdfSyntax)
+	}
+
+	data := make([]byte, length)
+	if _, err := d.ra.ReadAt(data, stream.offset); err != nil {
+		return nil, err
+	}
+	return decodeStream(data, stream.dict)
+}
+
+// decodeStream decodes stream data encoded as described by dict. Only
+// FlateDecode, with or without a PNG predictor, is supported: the filter of
+// cross reference and object streams.
+func decodeStream(data []byte, dict pdfDict) ([]byte, error) {
+	filter := dict["Filter"]
+	parms := dict["DecodeParms"]
+	if filters, ok := filter.(pdfArray); ok {
+		if len(filters) > 1 {
+			return nil, fmt.Errorf("%w: chained stream filters are not supported", errPdfSyntax)
+		}
+		filter = nil
+		if len(filters) == 1 {
+			filter = filters[0]
+		}
+		if list, ok := parms.(pdfArray); ok && len(list) == 1 {
+			parms = list[0]
+		}
+	}
+
+	switch filter {
+	case nil:
+		return data, nil
+	case pdfName("FlateDecode"), pdfName("Fl"):
+	default:
+		return nil, fmt.Errorf("%w: unsupported stream filter %v", errPdfSyntax, filter)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid compressed stream: %v", errPdfSyntax, err)
+	}
+	defer zr.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(zr, pdfMaxStreamSize+1))
+	// Streams often end without a checksum; keep what was decoded
+	if (err != nil && !errors.Is(err, io.ErrUnexpectedEOF)) || len(decoded) == 0 {
+		return nil, fmt.Errorf("%w: invalid compressed stream: %v", errPdfSyntax, err)
+	}
+	if len(decoded) > pdfMaxStreamSize {
+		return nil, fmt.Errorf("%w: stream too large", errPdfSyntax)
+	}
+
+	p, _ := parms.(pdfDict)
+	predictor, _ := p["Predictor"].(int64)
+	switch {
+	case predictor <= 1:
+		return decoded, nil
+	case predictor >= 10:
+		colors, bits, columns := int64(1), int64(8), int64(1)
+		if v, ok := p["Colors"].(int64); ok {
+			colors = v
+		}
+		if v, ok := p["BitsPerComponent"].(int64); ok {
+			bits = v
+		}
+		if v, ok := p["Columns"].(int64); ok {
+			columns = v
+		}
+		return pngUnpredict(decoded, colors, bits, columns)
+	default:
+		return nil, fmt.Errorf("%w: unsupported predictor %d", errPdfSyntax, predictor)
+	}
+}
+
+// pngUnpredict reverses the PNG predictors applied to each row of data
+func pngUnpredict(data []byte, colors, bits, columns int64) ([]byte, error) {
+	if colors < 1 || colors > 32 || bits < 1 || bits > 16 || columns < 1 || columns > 1<<20 {
+		return nil, fmt.Errorf("%w: invalid predictor parameters", errPdfSyntax)
+	}
+	rowLen := int((colors*bits*columns + 7) / 8)
+	bpp := int(max(1, colors*bits/8))
+
+	out := make([]byte, 0, len(data)/(rowLen+1)*rowLen)
+	prev := make([]byte, rowLen)
+	for len(data) > rowLen {
+		filter := data[0]
+		row := append([]byte(nil), data[1:rowLen+1]...)
+		data = data[rowLen+1:]
+
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+
+			switch filter {
+			case 0: // None
+			case 1: // Sub
+				row[i] += left
+			case 2: // Up
+				row[i] += up
+			case 3: // Average
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4: // Paeth
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("%w: invalid PNG predictor %d", errPdfSyntax, filter)
+			}
+		}
+
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+// paeth is the PNG Paeth predictor
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
@@ -0,0 +1,247 @@
+package mwclient
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildPdf assembles a PDF from the bodies of objects 1 to n, with a
+// classic cross reference table and the given trailer entries
+func buildPdf(objects []string, trailer string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return buf.Bytes()
+}
+
+// buildCompressedPdf is like buildPdf, but stores objects in an object
+// stream, except for streams, and indexes them with a cross reference
+// stream encoded with the PNG Up predictor
+func buildCompressedPdf(objects []string, trailer string) []byte {
+	n := len(objects)
+	stmNum, xrefNum := n+1, n+2
+
+	deflate := func(data []byte) []byte {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(data)
+		zw.Close()
+		return z.Bytes()
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+
+	// Rows of type, offset or object stream, and generation or index
+	rows := make([][3]int, n+3)
+	rows[0] = [3]int{0, 0, 0xffff}
+
+	var header, body bytes.Buffer
+	var compressed int
+	for i, obj := range objects {
+		if strings.Contains(obj, "stream") {
+			rows[i+1] = [3]int{1, buf.Len(), 0}
+			fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+			continue
+		}
+		rows[i+1] = [3]int{2, stmNum, compressed}
+		fmt.Fprintf(&header, "%d %d ", i+1, body.Len())
+		body.WriteString(obj + "\n")
+		compressed++
+	}
+
+	rows[stmNum] = [3]int{1, buf.Len(), 0}
+	data := deflate(append(header.Bytes(), body.Bytes()...))
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\r\n",
+		stmNum, compressed, header.Len(), len(data))
+	buf.Write(data)
+	buf.WriteString("\nendstream\nendobj\n")
+
+	xref := buf.Len()
+	rows[xrefNum] = [3]int{1, xref, 0}
+
+	var table []byte
+	prev := make([]byte, 7)
+	for _, row := range rows {
+		cur := []byte{byte(row[0]), byte(row[1] >> 24), byte(row[1] >> 16), byte(row[1] >> 8), byte(row[1]), byte(row[2] >> 8), byte(row[2])}
+		table = append(table, 2) // Up
+		for i := range cur {
+			table = append(table, cur[i]-prev[i])
+		}
+		prev = cur
+	}
+	data = deflate(table)
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Filter /FlateDecode /DecodeParms << /Columns 7 /Predictor 12 >> /Length %d %s >>\nstream\n",
+		xrefNum, len(rows), len(data), trailer)
+	buf.Write(data)
+	fmt.Fprintf(&buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// TestPdfLexer tests parsing PDF objects
+func TestPdfLexer(t *testing.T) {
+	src := `<< /Name#20x (a\(b\)\n\101\
+c) /Hex <48656C6C6F2> /Array [1 -2.5 3 0 R true null /N] % comment
+/Nested << /Empty [] >> /Int 42 >>`
+
+	obj, err := newPdfLexer(strings.NewReader(src)).object()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	want := pdfDict{
+		"Name x": pdfString("a(b)\nAc"),
+		"Hex":    pdfString("Hello "),
+		"Array":  pdfArray{int64(1), -2.5, pdfRef{num: 3}, true, nil, pdfName("N")},
+		"Nested": pdfDict{"Empty": pdfArray(nil)},
+		"Int":    int64(42),
+	}
+	if !reflect.DeepEqual(obj, want) {
+		t.Errorf("got %#v, want %#v", obj, want)
+	}
+
+	for _, src := range []string{"<< /A 1", "[1 2", "(open", "<< 1 2 >>", ">", strings.Repeat("[", 100)} {
+		if _, err := newPdfLexer(strings.NewReader(src)).object(); err == nil {
+			t.Errorf("%q: expected an error", src)
+		}
+	}
+}
+
+// TestPngUnpredict tests reversing PNG predictors
+func TestPngUnpredict(t *testing.T) {
+	data := []byte{
+		0, 1, 2, 3, // None: 1 2 3
+		1, 1, 1, 1, // Sub: 1 2 3
+		2, 1, 1, 1, // Up: 2 3 4
+		3, 2, 2, 2, // Average: 3 5 6
+		4, 1, 1, 1, // Paeth: 4 6 7
+	}
+	got, err := pngUnpredict(data, 1, 8, 3)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []byte{1, 2, 3, 1, 2, 3, 2, 3, 4, 3, 5, 6, 4, 6, 7}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := pngUnpredict([]byte{9, 1, 2, 3}, 1, 8, 3); !errors.Is(err, errPdfSyntax) {
+		t.Errorf("expected an error for an unknown predictor, got %v", err)
+	}
+}
+
+// TestOpenPdfDocument tests reading objects through cross reference tables
+// and streams
+func TestOpenPdfDocument(t *testing.T) {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		"<< /Title (First) >>",
+		"<< /Length 5 >>\nstream\nhello\nendstream",
+	}
+	trailer := "/Root 1 0 R /Info 3 0 R"
+
+	for name, data := range map[string][]byte{
+		"table":  buildPdf(objects, trailer),
+		"stream": buildCompressedPdf(objects, trailer),
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc, err := openPdfDocument(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("openPdfDocument failed: %v", err)
+			}
+
+			info, err := doc.resolveDict(doc.trailer["Info"])
+			if err != nil || string(info["Title"].(pdfString)) != "First" {
+				t.Errorf("unexpected info %v (%v)", info, err)
+			}
+
+			obj, err := doc.object(4)
+			stream, ok := obj.(pdfStream)
+			if err != nil || !ok {
+				t.Fatalf("expected a stream, got %v (%v)", obj, err)
+			}
+			if content, err := doc.streamData(stream); err != nil || string(content) != "hello" {
+				t.Errorf("unexpected stream data %q (%v)", content, err)
+			}
+
+			if obj, err := doc.object(42); obj != nil || err != nil {
+				t.Errorf("expected null for a missing object, got %v (%v)", obj, err)
+			}
+		})
+	}
+
+	t.Run("incremental update", func(t *testing.T) {
+		data := buildPdf(objects, trailer)
+		prev := bytes.LastIndex(data, []byte("\nxref\n")) + 1
+
+		// Replace the info dictionary, as an editor saving changes would
+		update := len(data)
+		data = append(data, "3 0 obj\n<< /Title (Second) >>\nendobj\n"...)
+		xref := len(data)
+		data = fmt.Appendf(data, "xref\n3 1\n%010d 00000 n \ntrailer\n<< /Size 5 /Root 1 0 R /Info 3 0 R /Prev %d >>\nstartxref\n%d\n%%%%EOF\n",
+			update, prev, xref)
+
+		doc, err := openPdfDocument(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("openPdfDocument failed: %v", err)
+		}
+		info, _ := doc.resolveDict(doc.trailer["Info"])
+		if title, _ := info["Title"].(pdfString); string(title) != "Second" {
+			t.Errorf("expected the updated title, got %q", title)
+		}
+		if _, err := doc.object(4); err != nil {
+			t.Errorf("expected objects of the original section, got %v", err)
+		}
+	})
+
+	t.Run("object stream loop", func(t *testing.T) {
+		// Objects 1 to 3 are stored in object stream 5
+		data := buildCompressedPdf(objects, trailer)
+		for name, xref := range map[string]map[int64]pdfXref{
+			"itself":       {5: {stream: 5}},
+			"each other":   {5: {stream: 7}, 7: {stream: 5}},
+			"own contents": {1: {stream: 1}},
+		} {
+			doc, err := openPdfDocument(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("openPdfDocument failed: %v", err)
+			}
+			maps.Copy(doc.xref, xref)
+			if _, err := doc.object(1); !errors.Is(err, errPdfSyntax) {
+				t.Errorf("%s: expected errPdfSyntax, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		notPdf := []byte("GIF89a")
+		if _, err := openPdfDocument(bytes.NewReader(notPdf), int64(len(notPdf))); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput for a GIF, got %v", err)
+		}
+
+		truncated := buildPdf(objects, trailer)
+		truncated = truncated[:len(truncated)/2]
+		if _, err := openPdfDocument(bytes.NewReader(truncated), int64(len(truncated))); !errors.Is(err, errPdfSyntax) {
+			t.Errorf("expected errPdfSyntax for a truncated PDF, got %v", err)
+		}
+	})
+}
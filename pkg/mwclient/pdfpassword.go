@@ -0,0 +1,316 @@
+package mwclient
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// pdfMagic starts every PDF file
+var pdfMagic = []byte("%PDF-")
+
+// pdfEncryptKey is the trailer entry pointing to the encryption dictionary
+// of an encrypted PDF
+var pdfEncryptKey = []byte("/Encrypt")
+
+// pdfTrailerScanSize is how much of the end of a PDF pdfEncrypted searches
+// for the trailer of the last update
+const pdfTrailerScanSize = 64 << 10
+
+// isPdfDelimiter reports whether c ends a PDF name: whitespace or a
+// delimiter character
+func isPdfDelimiter(c byte) bool {
+	return strings.IndexByte("\x00\t\n\f\r ()<>[]{}/%", c) >= 0
+}
+
+// hasPdfMagic reports whether ra starts with the PDF header
+func hasPdfMagic(ra io.ReaderAt) (bool, error) {
+	header := make([]byte, len(pdfMagic))
+	if _, err := ra.ReadAt(header, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Equal(header, pdfMagic), nil
+}
+
+// pdfEncrypted reports whether the PDF of size bytes in ra is encrypted, by
+// looking for the /Encrypt entry in the trailer of its last update. Only
+// strings and streams are encrypted, never dictionary keys, so no password
+// is needed. Only the trailer is searched, so that stream data mentioning
+// /Encrypt does not count. Input that is not a PDF is reported as not
+// encrypted.
+func pdfEncrypted(ra io.ReaderAt, size int64) (bool, error) {
+	if ok, err := hasPdfMagic(ra); !ok {
+		return false, err
+	}
+
+	start := max(size-pdfTrailerScanSize, int64(len(pdfMagic)))
+	tail := make([]byte, max(size-start, 0))
+	if _, err := ra.ReadAt(tail, start); err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+
+	trailer := pdfTrailerRegion(tail)
+	for i := 0; ; {
+		j := bytes.Index(trailer[i:], pdfEncryptKey)
+		if j < 0 {
+			return false, nil
+		}
+		end := i + j + len(pdfEncryptKey)
+		// Skip longer names such as /EncryptMetadata
+		if end == len(trailer) || isPdfDelimiter(trailer[end]) {
+			return true, nil
+		}
+		i = end
+	}
+}
+
+// pdfTrailerRegion returns the part of the end of a PDF holding the trailer
+// of its last update: from the last "trailer" keyword, or else the
+// dictionary of the last cross reference stream. It returns nil if there
+// is neither.
+func pdfTrailerRegion(tail []byte) []byte {
+	if i := bytes.LastIndex(tail, []byte("trailer")); i >= 0 {
+		return tail[i:]
+	}
+
+	i := bytes.LastIndex(tail, []byte("/XRef"))
+	if i < 0 {
+		return nil
+	}
+	start := bytes.LastIndex(tail[:i], []byte("obj"))
+	end := bytes.Index(tail[i:], []byte("stream"))
+	if start < 0 || end < 0 {
+		return nil
+	}
+	return tail[start : i+end]
+}
+
+// pdfEncryption reports whether the PDF in ra is encrypted, from the
+// Encrypt entry of its trailer, and whether it is locked: whether opening it
+// needs a password. Documents protected by an owner password only, which
+// restricts printing or copying, are encrypted but not locked. If the
+// structure cannot be parsed, its trailer is searched for the Encrypt
+// entry and encrypted documents are taken as locked. Input that is not a
+// PDF is reported as not encrypted.
+func pdfEncryption(ra io.ReaderAt, size int64) (encrypted, locked bool, err error) {
+	if ok, err := hasPdfMagic(ra); !ok {
+		return false, false, err
+	}
+
+	doc, err := openPdfDocument(ra, size)
+	if err != nil {
+		encrypted, err = pdfEncrypted(ra, size)
+		return encrypted, encrypted, err
+	}
+
+	if _, ok := doc.trailer["Encrypt"]; !ok {
+		return false, false, nil
+	}
+	return true, !doc.emptyUserPassword(), nil
+}
+
+// pdfEncryptedFile is like pdfEncryption for the PDF at path, which may
+// carry ImageMagick's "PDF:" format prefix
+func pdfEncryptedFile(path string) (encrypted, locked bool, err error) {
+	f, err := os.Open(pdfFile(path))
+	if err != nil {
+		return false, false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, false, err
+	}
+	return pdfEncryption(f, info.Size())
+}
+
+// checkPdfLocked returns ErrPasswordRequired if the PDF at path needs a
+// password and none is set, so that it is not rasterized in vain
+func checkPdfLocked(path string, s *settings) error {
+	if s.pdfPassword != "" {
+		return nil
+	}
+	if _, locked, _ := pdfEncryptedFile(path); locked {
+		return fmt.Errorf("%w: PDF is encrypted", ErrPasswordRequired)
+	}
+	return nil
+}
+
+// setPdfPassword passes the password from WithPdfPassword to the PDF
+// reader; it must be called before reading
+func setPdfPassword(mw *imagick.MagickWand, s *settings) error {
+	if s.pdfPassword == "" {
+		return nil
+	}
+	if err := mw.SetOption("authenticate", s.pdfPassword); err != nil {
+		return fmt.Errorf("%w: failed to set PDF password: %v", ErrProcessing, err)
+	}
+	return nil
+}
+
+// passwordError classifies a failure to read the PDF at path. If the PDF
+// needs a password it returns ErrPasswordRequired, or ErrWrongPassword if a
+// password was given; otherwise it returns nil.
+func passwordError(path string, s *settings, err error) error {
+	if _, locked, _ := pdfEncryptedFile(path); !locked {
+		return nil
+	}
+	if s.pdfPassword == "" {
+		return fmt.Errorf("%w: %v", ErrPasswordRequired, err)
+	}
+	return fmt.Errorf("%w: %v", ErrWrongPassword, err)
+}
+
+// pdfPasswordPadding pads passwords of the standard security handler to 32
+// bytes
+var pdfPasswordPadding = []byte{
+	0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41, 0x64, 0x00, 0x4e, 0x56, 0xff, 0xfa, 0x01, 0x08,
+	0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80, 0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a,
+}
+
+// emptyUserPassword reports whether the encrypted document opens with an
+// empty user password, by checking it against the /U entry of the standard
+// security handler. Other handlers are reported as needing a password.
+func (d *pdfDocument) emptyUserPassword() bool {
+	enc, err := d.resolveDict(d.trailer["Encrypt"])
+	if err != nil || enc == nil || enc["Filter"] != pdfName("Standard") {
+		return false
+	}
+
+	r, _ := enc["R"].(int64)
+	u, _ := enc["U"].(pdfString)
+	switch {
+	case r >= 2 && r <= 4:
+		return len(u) >= 16 && d.checkUserPasswordRC4(enc, r, u)
+	case r == 5:
+		// Hash of the password and the validation salt
+		if len(u) < 40 {
+			return false
+		}
+		hash := sha256.Sum256(u[32:40])
+		return bytes.Equal(hash[:], u[:32])
+	case r == 6:
+		return len(u) >= 40 && bytes.Equal(pdfHash2B(nil, u[32:40]), u[:32])
+	}
+	return false
+}
+
+// checkUserPasswordRC4 checks an empty user password for revisions 2 to 4
+// of the standard security handler (RC4 and AES-128)
+func (d *pdfDocument) checkUserPasswordRC4(enc pdfDict, r int64, u []byte) bool {
+	o, _ := enc["O"].(pdfString)
+	p, _ := enc["P"].(int64)
+
+	var id []byte
+	if ids, _ := d.resolve(d.trailer["ID"]); ids != nil {
+		if list, ok := ids.(pdfArray); ok && len(list) > 0 {
+			first, _ := d.resolve(list[0])
+			id, _ = first.(pdfString)
+		}
+	}
+
+	// Key length in bytes, 40 bits unless set
+	n := 5
+	if r >= 3 {
+		if bits, ok := enc["Length"].(int64); ok {
+			n = int(bits / 8)
+		} else if r == 4 {
+			n = 16
+		}
+	}
+	if n < 5 || n > 16 {
+		return false
+	}
+
+	// Derive the key from the padded empty password
+	h := md5.New()
+	h.Write(pdfPasswordPadding)
+	h.Write(o)
+	h.Write(binary.LittleEndian.AppendUint32(nil, uint32(p)))
+	h.Write(id)
+	if r >= 4 && enc["EncryptMetadata"] == false {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	key := h.Sum(nil)
+	if r >= 3 {
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key[:n])
+			key = sum[:]
+		}
+	}
+	key = key[:n]
+
+	if r == 2 {
+		c, _ := rc4.NewCipher(key)
+		want := make([]byte, len(pdfPasswordPadding))
+		c.XORKeyStream(want, pdfPasswordPadding)
+		return bytes.Equal(want, u)
+	}
+
+	// Revisions 3 and 4 encrypt the hash of the padding and the ID 20
+	// times, with the key XORed with the round number
+	sum := md5.Sum(append(bytes.Clone(pdfPasswordPadding), id...))
+	want := sum[:]
+	roundKey := make([]byte, n)
+	for i := 0; i < 20; i++ {
+		for j := range key {
+			roundKey[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(roundKey)
+		c.XORKeyStream(want, want)
+	}
+	return bytes.Equal(want, u[:16])
+}
+
+// pdfHash2B computes the password hash of revision 6 of the standard
+// security handler for a user password and salt
+func pdfHash2B(password, salt []byte) []byte {
+	k := sha256.Sum256(append(bytes.Clone(password), salt...))
+	key := k[:]
+
+	for round := 1; ; round++ {
+		k1 := bytes.Repeat(append(bytes.Clone(password), key...), 64)
+
+		block, _ := aes.NewCipher(key[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, key[16:32]).CryptBlocks(e, k1)
+
+		// The first 16 bytes as a big-endian number, modulo 3, pick the
+		// hash; as 256 is 1 modulo 3, that is the sum of the bytes
+		mod := 0
+		for _, b := range e[:16] {
+			mod += int(b)
+		}
+		switch mod % 3 {
+		case 0:
+			sum := sha256.Sum256(e)
+			key = sum[:]
+		case 1:
+			sum := sha512.Sum384(e)
+			key = sum[:]
+		case 2:
+			sum := sha512.Sum512(e)
+			key = sum[:]
+		}
+
+		if round >= 64 && int(e[len(e)-1]) <= round-32 {
+			return key[:32]
+		}
+	}
+}
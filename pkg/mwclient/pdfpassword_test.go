@@ -0,0 +1,202 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// encryptedPdf is the skeleton of an encrypted PDF: enough to be detected,
+// but not to be opened
+const encryptedPdf = "%PDF-1.6\n" +
+	"4 0 obj\n<< /Filter /Standard /V 4 /R 4 /EncryptMetadata false >>\nendobj\n" +
+	"trailer\n<< /Size 5 /Root 1 0 R /Encrypt 4 0 R >>\n%%EOF\n"
+
+// TestPdfEncrypted tests detecting encrypted PDFs from their trailer
+// without a password
+func TestPdfEncrypted(t *testing.T) {
+	xrefStream := "%PDF-1.7\n9 0 obj\n<< /Type /XRef /Size 10 /Encrypt 4 0 R /Length 0 >>\nstream\n\nendstream\nendobj\nstartxref\n9\n%%EOF\n"
+	streamData := "%PDF-1.7\n5 0 obj\n<< /Length 19 >>\nstream\nBT (/Encrypt) Tj ET\nendstream\nendobj\n"
+	farAway := "%PDF-1.7\n<< /Encrypt 4 0 R >>\n" + strings.Repeat(" ", pdfTrailerScanSize) + "trailer\n<< /Size 5 >>\n%%EOF\n"
+
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"encrypted", encryptedPdf, true},
+		{"xref stream", xrefStream, true},
+		{"key ending the file", "%PDF-1.7\ntrailer\n<< /Size 5 /Root 1 0 R /Encrypt", true},
+		{"plain", "%PDF-1.7\ntrailer\n<< /Size 5 /Root 1 0 R >>\n%%EOF\n", false},
+		{"metadata flag only", "%PDF-1.7\ntrailer\n<< /EncryptMetadata false >>", false},
+		{"in stream data", streamData + "trailer\n<< /Size 6 /Root 1 0 R >>\n%%EOF\n", false},
+		{"in stream data without trailer", streamData, false},
+		{"before the tail", farAway, false},
+		{"not a PDF", "GIF89a trailer /Encrypt", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := pdfEncrypted(strings.NewReader(tt.data), int64(len(tt.data)))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestPdfEncryption tests telling encrypted PDFs that need a password from
+// those protected by an owner password only
+func TestPdfEncryption(t *testing.T) {
+	// Entries shared by the test vectors, which were encrypted either with
+	// an empty user password or with "secret"
+	const (
+		owner = "<000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f>"
+		id    = "/ID [<0123456789abcdeffedcba9876543210> <0123456789abcdeffedcba9876543210>]"
+		salts = "a1a2a3a4a5a6a7a8b1b2b3b4b5b6b7b8"
+		zeros = "00000000000000000000000000000000"
+	)
+
+	tests := []struct {
+		name    string
+		encrypt string
+		locked  bool
+	}{
+		{"R2 empty", "/V 1 /R 2 /P -3904 /O " + owner + " /U <0fc447ae2718fc901b8496da91350859576ff6788c0b5cab3ac80490b04ba4bb>", false},
+		{"R2 secret", "/V 1 /R 2 /P -3904 /O " + owner + " /U <3d40f59b8f1f42e63927bd66e8aec6b7522abafbe2e582e3a6fc1d2dd680417c>", true},
+		{"R3 empty", "/V 2 /R 3 /Length 128 /P -3904 /O " + owner + " /U <0135d1bccdd6f5be2468f5d872a4e382" + zeros + ">", false},
+		{"R3 secret", "/V 2 /R 3 /Length 128 /P -3904 /O " + owner + " /U <5ef8ceb2c4232b1f39e3001aaf019e9a" + zeros + ">", true},
+		{"R5 empty", "/V 5 /R 5 /U <65886c9f4507df2b526c2bcb9b33cd5dbab388f3143e039e0120834929c704ca" + salts + ">", false},
+		{"R5 secret", "/V 5 /R 5 /U <438c508bed4bb3c0c272d43486e3d717fa4f4b20466bee1f17969b75f406dbbc" + salts + ">", true},
+		{"R6 empty", "/V 5 /R 6 /U <ed88427292529961aed236c46fa510b269bedff2beb2f0196fce1e7ae3508a15" + salts + ">", false},
+		{"R6 secret", "/V 5 /R 6 /U <4c000bd40708427f88e6866c138bf40111f307eeed3361f00c07c8aec17691af" + salts + ">", true},
+		{"no user hash", "/V 2 /R 3", true},
+		{"other handler", "/Filter /Adobe.PubSec /V 4 /R 4", true},
+	}
+
+	for _, tt := range tests {
+		encrypt := tt.encrypt
+		if !strings.Contains(encrypt, "/Filter") {
+			encrypt = "/Filter /Standard " + encrypt
+		}
+		data := buildPdf([]string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [] /Count 0 >>",
+			"<< " + encrypt + " >>",
+		}, "/Root 1 0 R /Encrypt 3 0 R "+id)
+
+		encrypted, locked, err := pdfEncryption(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if !encrypted || locked != tt.locked {
+			t.Errorf("%s: got encrypted %v, locked %v, want locked %v", tt.name, encrypted, locked, tt.locked)
+		}
+	}
+
+	// The trailer is authoritative: the name in a content stream is text
+	plain := buildPdf([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		"<< /Length 19 >>\nstream\nBT (/Encrypt) Tj ET\nendstream",
+	}, "/Root 1 0 R")
+	encrypted, locked, err := pdfEncryption(bytes.NewReader(plain), int64(len(plain)))
+	if err != nil || encrypted || locked {
+		t.Errorf("expected an unencrypted PDF, got encrypted %v, locked %v (%v)", encrypted, locked, err)
+	}
+
+	// Documents that cannot be parsed have their trailer scanned, and need
+	// a password
+	encrypted, locked, err = pdfEncryption(strings.NewReader(encryptedPdf), int64(len(encryptedPdf)))
+	if err != nil || !encrypted || !locked {
+		t.Errorf("expected a locked PDF from the scan, got encrypted %v, locked %v (%v)", encrypted, locked, err)
+	}
+
+	// Other formats are recognized from their header alone
+	png := "\x89PNG\r\n\x1a\ntrailer << /Encrypt 1 0 R >>"
+	encrypted, locked, err = pdfEncryption(strings.NewReader(png), int64(len(png)))
+	if err != nil || encrypted || locked {
+		t.Errorf("expected a PNG not to be encrypted, got encrypted %v, locked %v (%v)", encrypted, locked, err)
+	}
+}
+
+// TestPdfPassword tests the errors returned for encrypted PDFs
+func TestPdfPassword(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	pdfPath := filepath.Join(dir, "statement.pdf")
+	if err := os.WriteFile(pdfPath, []byte(encryptedPdf), 0o644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	out := filepath.Join(dir, "page.png")
+
+	if _, err := client.ConvertPdf(ctx, pdfPath, out, PdfOptions{}); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired, got %v", err)
+	}
+	_, err := client.ConvertPdf(ctx, pdfPath, out, PdfOptions{}, WithPdfPassword("secret"))
+	if !errors.Is(err, ErrWrongPassword) || !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrWrongPassword, got %v", err)
+	}
+
+	// Selections that need the page count fail the same way
+	sel, _ := ParsePageSelection("last")
+	if _, err := client.ConvertPdf(ctx, pdfPath, out, PdfOptions{Pages: sel}); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired resolving the last page, got %v", err)
+	}
+
+	if err := client.ConvertPdfToImages(pdfPath, out, 0, 100, false); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired from ConvertPdfToImages, got %v", err)
+	}
+
+	err = client.RasterizePdf(ctx, bytes.NewReader([]byte(encryptedPdf)), "png", PdfOptions{},
+		func(int, io.Reader, PageMeta) error { return nil })
+	if !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired from RasterizePdf, got %v", err)
+	}
+
+	// Pipelines and renditions read files the same way
+	if err := client.Pipeline().RunFile(ctx, pdfPath, out); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired from RunFile, got %v", err)
+	}
+	_, err = client.RenditionsFile(ctx, pdfPath, []RenditionSpec{{Name: "thumb", Width: 100, Path: out}})
+	if !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired from RenditionsFile, got %v", err)
+	}
+
+	meta, err := client.OpenImage(pdfPath)
+	if !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrPasswordRequired from OpenImage, got %v", err)
+	}
+	if !meta.Encrypted || meta.FormatName != "PDF" {
+		t.Errorf("expected an encrypted PDF in the metadata, got %+v", meta)
+	}
+
+	// Unencrypted PDFs that cannot be read are still processing errors
+	brokenPath := filepath.Join(dir, "broken.pdf")
+	if err := os.WriteFile(brokenPath, []byte("%PDF-1.7\ngarbage"), 0o644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	_, err = client.ConvertPdf(ctx, brokenPath, out, PdfOptions{})
+	if !errors.Is(err, ErrProcessing) || errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("expected ErrProcessing for a broken PDF, got %v", err)
+	}
+}
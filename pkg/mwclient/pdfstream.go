@@ -0,0 +1,140 @@
+package mwclient
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// PageMeta describes a rasterized page passed to a PageFunc
+type PageMeta struct {
+	Width   uint
+	Height  uint
+	Format  string  // encoded format, such as "PNG"
+	DPI     float64 // rasterization density
+	Size    int64   // encoded size in bytes
+	Montage bool    // the image is a sheet combining selected pages
+}
+
+// PageFunc receives a rasterized page. pageIndex is the 0-based index of
+// the page in the document. img is only valid until the function returns.
+// Returning ErrStopPages stops rasterizing without an error; any other
+// error aborts the call and is returned in a PageErrors.
+type PageFunc func(pageIndex int, img io.Reader, meta PageMeta) error
+
+// RasterizePdf rasterizes the selected pages of a PDF read from r and
+// passes each page, encoded to format, to fn in selection order, so the
+// caller decides where pages go. An empty format means PNG. With Montage,
+// fn is called once per sheet, with the sheet index. The first page
+// that fails aborts the call with a PageErrors, as with Strict.
+//
+// The PDF is spooled to a temporary file, as ImageMagick reads selected
+// pages from a file. Pages larger than the spool threshold are encoded to
+// temporary files rather than memory.
+func (c *Client) RasterizePdf(ctx context.Context, r io.Reader, format string, pdf PdfOptions, fn PageFunc, opts ...Option) error {
+	if r == nil || fn == nil {
+		return fmt.Errorf("%w: reader or page function is nil", ErrInvalidInput)
+	}
+
+	if err := pdf.validate(); err != nil {
+		return err
+	}
+
+	s, err := c.settingsFor(opts)
+	if err != nil {
+		return err
+	}
+
+	release, err := c.pool.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	path, err := spoolFile(r, &s)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	format = strings.ToUpper(format)
+	if format == "" {
+		format = "PNG"
+	}
+
+	dpi := cmp.Or(pdf.DPI, s.pdfDensity)
+
+	// The spooled file has no extension, so name the format explicitly
+	return renderPdf(ctx, "PDF:"+path, pdf, &s, func(page pdfPage) error {
+		if err := prepareEncode(page.mw, format, &s); err != nil {
+			return err
+		}
+
+		img, size, done, err := encodePage(page.mw, &s)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		return fn(page.index, img, PageMeta{
+			Width:   page.mw.GetImageWidth(),
+			Height:  page.mw.GetImageHeight(),
+			Format:  page.mw.GetImageFormat(),
+			DPI:     dpi,
+			Size:    size,
+			Montage: page.montage,
+		})
+	}, func(page pdfPage, err error) error {
+		return PageErrors{{Index: page.index, Err: err}}
+	})
+}
+
+// encodePage encodes mw and returns a reader over the result, its size and
+// a function releasing it. Images larger than the spool threshold are
+// encoded to a temporary file; smaller ones are encoded in memory.
+func encodePage(mw *imagick.MagickWand, s *settings) (io.Reader, int64, func(), error) {
+	if !spoolOutput(mw, s) {
+		blob, err := mw.GetImageBlob()
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("%w: failed to get image blob: %v", ErrProcessing, err)
+		}
+		if len(blob) == 0 {
+			return nil, 0, nil, fmt.Errorf("%w: empty result image", ErrProcessing)
+		}
+		return bytes.NewReader(blob), int64(len(blob)), func() {}, nil
+	}
+
+	f, err := os.CreateTemp(s.tempDir, "mwclient-*")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("%w: failed to create temporary file: %v", ErrProcessing, err)
+	}
+	done := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	if err := mw.WriteImageFile(f); err != nil {
+		done()
+		return nil, 0, nil, fmt.Errorf("%w: failed to write image: %v", ErrProcessing, err)
+	}
+
+	info, err := f.Stat()
+	if err == nil && info.Size() == 0 {
+		err = fmt.Errorf("empty result image")
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		done()
+		return nil, 0, nil, fmt.Errorf("%w: failed to spool page: %v", ErrProcessing, err)
+	}
+
+	return f, info.Size(), done, nil
+}
@@ -0,0 +1,144 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+// TestSpoolFile tests spooling a whole input to a temporary file
+func TestSpoolFile(t *testing.T) {
+	dir := t.TempDir()
+	s := defaultSettings()
+	s.tempDir = dir
+	s.maxInputSize = 64
+
+	path, err := spoolFile(bytes.NewReader(bytes.Repeat([]byte("x"), 64)), &s)
+	if err != nil {
+		t.Fatalf("spoolFile failed: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || len(data) != 64 {
+		t.Errorf("expected the input in %s, got %d bytes (%v)", path, len(data), err)
+	}
+	os.Remove(path)
+
+	_, err = spoolFile(io.LimitReader(zeroReader{}, 65), &s)
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("expected ErrInputTooLarge, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected oversized input to be removed, found %d files", len(entries))
+	}
+}
+
+// TestRasterizePdf tests rasterizing PDF pages from a reader to a callback
+func TestRasterizePdf(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+
+	// Build a 3-page PDF whose pages are 100, 120 and 140 points wide
+	var inputs []io.Reader
+	for i := 0; i < 3; i++ {
+		inputs = append(inputs, bytes.NewReader(testPNG(t, 100+20*i, 200)))
+	}
+	var pdf bytes.Buffer
+	if err := client.AssemblePDF(ctx, inputs, &pdf, PDFLayout{DPI: 72}); err != nil {
+		t.Fatalf("AssemblePDF failed: %v", err)
+	}
+
+	t.Run("Pages", func(t *testing.T) {
+		sel, _ := ParsePageSelection("3,1")
+		var indexes []int
+		err := client.RasterizePdf(ctx, bytes.NewReader(pdf.Bytes()), "jpeg", PdfOptions{Pages: sel, DPI: 72},
+			func(pageIndex int, img io.Reader, meta PageMeta) error {
+				indexes = append(indexes, pageIndex)
+
+				data, err := io.ReadAll(img)
+				if err != nil {
+					return err
+				}
+				format, width, height := decodeConfig(t, data)
+				if format != "jpeg" || meta.Format != "JPEG" {
+					t.Errorf("page %d: expected JPEG, got %s (%s)", pageIndex, format, meta.Format)
+				}
+				if width != 100+20*pageIndex || height != 200 || meta.Width != uint(width) || meta.Height != uint(height) {
+					t.Errorf("page %d: unexpected size %dx%d (meta %dx%d)", pageIndex, width, height, meta.Width, meta.Height)
+				}
+				if meta.Size != int64(len(data)) || meta.DPI != 72 {
+					t.Errorf("page %d: unexpected meta %+v for %d bytes", pageIndex, meta, len(data))
+				}
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("RasterizePdf failed: %v", err)
+		}
+		if len(indexes) != 2 || indexes[0] != 2 || indexes[1] != 0 {
+			t.Errorf("expected pages [2 0], got %v", indexes)
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		calls := 0
+		err := client.RasterizePdf(ctx, bytes.NewReader(pdf.Bytes()), "", PdfOptions{DPI: 36},
+			func(pageIndex int, img io.Reader, meta PageMeta) error {
+				calls++
+				return ErrStopPages
+			})
+		if err != nil {
+			t.Errorf("expected no error after ErrStopPages, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+
+		errUpload := errors.New("upload failed")
+		err = client.RasterizePdf(ctx, bytes.NewReader(pdf.Bytes()), "", PdfOptions{DPI: 36},
+			func(pageIndex int, img io.Reader, meta PageMeta) error {
+				return errUpload
+			})
+		if !errors.Is(err, errUpload) {
+			t.Errorf("expected the callback error, got %v", err)
+		}
+	})
+
+	t.Run("Montage", func(t *testing.T) {
+		calls := 0
+		err := client.RasterizePdf(ctx, bytes.NewReader(pdf.Bytes()), "png", PdfOptions{DPI: 36, Montage: true},
+			func(pageIndex int, img io.Reader, meta PageMeta) error {
+				calls++
+				if !meta.Montage || pageIndex != 0 {
+					t.Errorf("expected a montage at index 0, got index %d, meta %+v", pageIndex, meta)
+				}
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("RasterizePdf failed: %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		err := client.RasterizePdf(ctx, bytes.NewReader(pdf.Bytes()), "png", PdfOptions{}, nil)
+		if !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput with nil callback, got %v", err)
+		}
+
+		noop := func(int, io.Reader, PageMeta) error { return nil }
+		err = client.RasterizePdf(ctx, bytes.NewReader(pdf.Bytes()), "png", PdfOptions{}, noop, WithMaxInputSize(64))
+		if !errors.Is(err, ErrInputTooLarge) {
+			t.Errorf("expected ErrInputTooLarge, got %v", err)
+		}
+	})
+}
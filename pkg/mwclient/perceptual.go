@@ -0,0 +1,278 @@
+package mwclient
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// QualityMetric is a measure of similarity between an encoded image and
+// its source
+type QualityMetric int
+
+const (
+	// MetricSSIM is the structural similarity index, 1 for identical images
+	MetricSSIM QualityMetric = iota
+	// MetricDSSIM is the structural dissimilarity, (1 - SSIM) / 2, 0 for
+	// identical images
+	MetricDSSIM
+	// MetricPSNR is the peak signal-to-noise ratio in decibels
+	MetricPSNR
+)
+
+// String returns the name of the metric
+func (m QualityMetric) String() string {
+	switch m {
+	case MetricSSIM:
+		return "ssim"
+	case MetricDSSIM:
+		return "dssim"
+	case MetricPSNR:
+		return "psnr"
+	default:
+		return fmt.Sprintf("QualityMetric(%d)", int(m))
+	}
+}
+
+// ParseQualityMetric returns the metric with the given name
+func ParseQualityMetric(name string) (QualityMetric, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "ssim":
+		return MetricSSIM, nil
+	case "dssim":
+		return MetricDSSIM, nil
+	case "psnr":
+		return MetricPSNR, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown quality metric %q", ErrInvalidInput, name)
+	}
+}
+
+// higherIsBetter reports whether larger values of the metric mean more
+// similar images
+func (m QualityMetric) higherIsBetter() bool {
+	return m != MetricDSSIM
+}
+
+// QualityTarget is a minimum perceptual similarity between the encoded
+// image and its source
+type QualityTarget struct {
+	Metric QualityMetric
+
+	// Threshold the metric must meet: a minimum for SSIM (e.g. 0.98) and
+	// PSNR (e.g. 40 dB), a maximum for DSSIM (e.g. 0.01)
+	Threshold float64
+
+	// Quality range searched. Zero values use 1 and the client quality.
+	MinQuality uint
+	MaxQuality uint
+}
+
+// QualityResult reports the quality chosen for a QualityTarget
+type QualityResult struct {
+	Quality  uint
+	Metric   float64 // achieved value of the target metric
+	Size     int64
+	Met      bool // false if even the maximum quality misses the threshold
+	Attempts int  // number of trial encodes
+}
+
+// validate checks the target for out-of-range values
+func (t QualityTarget) validate() error {
+	switch t.Metric {
+	case MetricSSIM:
+		if t.Threshold <= 0 || t.Threshold > 1 {
+			return fmt.Errorf("%w: SSIM threshold must be in (0, 1]", ErrInvalidInput)
+		}
+	case MetricDSSIM:
+		if t.Threshold < 0 || t.Threshold >= 0.5 {
+			return fmt.Errorf("%w: DSSIM threshold must be in [0, 0.5)", ErrInvalidInput)
+		}
+	case MetricPSNR:
+		if t.Threshold <= 0 {
+			return fmt.Errorf("%w: PSNR threshold must be positive", ErrInvalidInput)
+		}
+	default:
+		return fmt.Errorf("%w: unknown quality metric %d", ErrInvalidInput, int(t.Metric))
+	}
+
+	if t.MinQuality > 100 || t.MaxQuality > 100 {
+		return fmt.Errorf("%w: quality must be between 1 and 100", ErrInvalidInput)
+	}
+	if t.MaxQuality != 0 && t.MinQuality > t.MaxQuality {
+		return fmt.Errorf("%w: minimum quality exceeds maximum quality", ErrInvalidInput)
+	}
+	return nil
+}
+
+// meets reports whether value satisfies the target threshold
+func (t QualityTarget) meets(value float64) bool {
+	if t.Metric.higherIsBetter() {
+		return value >= t.Threshold
+	}
+	return value <= t.Threshold
+}
+
+// EncodeToQuality encodes the image in format, or the input format if empty,
+// at the lowest quality whose output meets target. The quality, metric and
+// size found are stored in result, which may be nil. If no quality meets
+// the target, the maximum quality is used. Only lossy formats (JPEG, WebP,
+// AVIF) can be tuned.
+func (p *Pipeline) EncodeToQuality(format string, target QualityTarget, result *QualityResult) *Pipeline {
+	p.format = format
+	p.tune = func(ctx context.Context, mw *imagick.MagickWand, s *settings) error {
+		res, err := fitToQuality(ctx, mw, target, s)
+		if result != nil {
+			*result = res
+		}
+		return err
+	}
+	return p
+}
+
+// EncodeToQuality re-encodes an image from a reader at the lowest quality
+// that meets target and writes the result to the provided writer. An empty
+// format keeps the input format.
+func (c *Client) EncodeToQuality(ctx context.Context, r io.Reader, w io.Writer, format string, target QualityTarget, opts ...Option) (QualityResult, error) {
+	var result QualityResult
+
+	if r == nil || w == nil {
+		return result, fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
+	}
+
+	if err := target.validate(); err != nil {
+		return result, err
+	}
+
+	err := c.Pipeline(opts...).AutoOrient().EncodeToQuality(format, target, &result).Run(ctx, r, w)
+	return result, err
+}
+
+// EncodeToQualityFile is like EncodeToQuality but reads from inputPath and
+// writes to outputPath. An empty format uses the output file's extension.
+func (c *Client) EncodeToQualityFile(ctx context.Context, inputPath, outputPath, format string, target QualityTarget, opts ...Option) (QualityResult, error) {
+	var result QualityResult
+
+	if inputPath == "" || outputPath == "" {
+		return result, fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
+	}
+
+	if err := target.validate(); err != nil {
+		return result, err
+	}
+
+	format = cmp.Or(format, formatFromPath(outputPath))
+	err := c.Pipeline(opts...).AutoOrient().EncodeToQuality(format, target, &result).RunFile(ctx, inputPath, outputPath)
+	return result, err
+}
+
+// fitToQuality searches for the lowest quality at which mw meets target and
+// leaves mw set up for it
+func fitToQuality(ctx context.Context, mw *imagick.MagickWand, target QualityTarget, s *settings) (QualityResult, error) {
+	var result QualityResult
+
+	if err := target.validate(); err != nil {
+		return result, err
+	}
+	if format := mw.GetImageFormat(); !isLossy(format) {
+		return result, fmt.Errorf("%w: quality targets need JPEG, WebP or AVIF output, not %s", ErrInvalidInput, format)
+	}
+
+	minQuality := cmp.Or(target.MinQuality, 1)
+	maxQuality := max(cmp.Or(target.MaxQuality, s.quality), minQuality)
+
+	result, err := searchMinQuality(minQuality, maxQuality, target, func(quality uint) (float64, int64, error) {
+		if err := checkContext(ctx); err != nil {
+			return 0, 0, err
+		}
+		return measureQuality(mw, quality, target.Metric)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	s.logger.Debug("Quality target", "Metric", target.Metric, "Value", result.Metric, "Met", result.Met, "Quality", result.Quality, "Size", result.Size)
+	if err := mw.SetImageCompressionQuality(result.Quality); err != nil {
+		return result, fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
+	}
+	return result, nil
+}
+
+// measureQuality encodes mw at quality, decodes the result and compares it
+// with mw. It returns the metric value and the encoded size.
+func measureQuality(mw *imagick.MagickWand, quality uint, metric QualityMetric) (float64, int64, error) {
+	if err := mw.SetImageCompressionQuality(quality); err != nil {
+		return 0, 0, fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
+	}
+	blob, err := mw.GetImageBlob()
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: failed to get image blob: %v", ErrProcessing, err)
+	}
+
+	decoded := imagick.NewMagickWand()
+	defer decoded.Destroy()
+	if err := decoded.ReadImageBlob(blob); err != nil {
+		return 0, 0, fmt.Errorf("%w: failed to decode trial image: %v", ErrProcessing, err)
+	}
+
+	// SSIM is derived from DSSIM, whose definition is stable across
+	// ImageMagick versions
+	imMetric := imagick.METRIC_PEAK_SIGNAL_TO_NOISE_RATIO
+	if metric != MetricPSNR {
+		imMetric = imagick.METRIC_STRUCTURAL_DISSIMILARITY_ERROR
+	}
+
+	diff, distortion := mw.CompareImages(decoded, imMetric)
+	if diff == nil {
+		return 0, 0, fmt.Errorf("%w: failed to compare images", ErrProcessing)
+	}
+	diff.Destroy()
+
+	if metric == MetricSSIM {
+		distortion = 1 - 2*distortion
+	}
+	return distortion, int64(len(blob)), nil
+}
+
+// searchMinQuality binary-searches [lo, hi] for the lowest quality meeting
+// target, assuming similarity grows with quality. If none does, the result
+// is for hi with Met false.
+func searchMinQuality(lo, hi uint, target QualityTarget, measure func(quality uint) (float64, int64, error)) (QualityResult, error) {
+	var result, top QualityResult
+
+	low, high := int(lo), int(hi)
+	for low <= high {
+		mid := low + (high-low)/2
+		value, size, err := measure(uint(mid))
+		result.Attempts++
+		if err != nil {
+			return result, err
+		}
+
+		trial := QualityResult{Quality: uint(mid), Metric: value, Size: size}
+		if uint(mid) == hi {
+			top = trial
+		}
+
+		// An infinite PSNR means the images are identical
+		if target.meets(value) || math.IsInf(value, 1) {
+			result.Quality, result.Metric, result.Size, result.Met = trial.Quality, value, size, true
+			high = mid - 1
+		} else {
+			low = mid + 1
+		}
+	}
+
+	if result.Met {
+		return result, nil
+	}
+
+	// The search only reaches hi when everything below it failed
+	top.Attempts = result.Attempts
+	return top, nil
+}
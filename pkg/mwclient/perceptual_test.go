@@ -0,0 +1,138 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSearchMinQuality tests the lowest-quality search against a synthetic
+// metric that improves linearly with quality
+func TestSearchMinQuality(t *testing.T) {
+	ssim := func(quality uint) (float64, int64, error) {
+		return float64(quality) / 100, int64(quality) * 100, nil
+	}
+	dssim := func(quality uint) (float64, int64, error) {
+		return (1 - float64(quality)/100) / 2, int64(quality) * 100, nil
+	}
+
+	tests := []struct {
+		name        string
+		target      QualityTarget
+		measure     func(uint) (float64, int64, error)
+		wantQuality uint
+		wantMet     bool
+	}{
+		{"ssim", QualityTarget{Metric: MetricSSIM, Threshold: 0.85}, ssim, 85, true},
+		{"ssim at minimum", QualityTarget{Metric: MetricSSIM, Threshold: 0.1}, ssim, 10, true},
+		{"dssim", QualityTarget{Metric: MetricDSSIM, Threshold: 0.05}, dssim, 90, true},
+		{"unreachable", QualityTarget{Metric: MetricSSIM, Threshold: 0.99}, ssim, 95, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := searchMinQuality(10, 95, tt.target, tt.measure)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Quality != tt.wantQuality || got.Met != tt.wantMet {
+				t.Errorf("expected quality %d met=%v, got %+v", tt.wantQuality, tt.wantMet, got)
+			}
+			if got.Size != int64(got.Quality)*100 {
+				t.Errorf("expected the size of quality %d, got %d", got.Quality, got.Size)
+			}
+			if got.Attempts == 0 || got.Attempts > 7 {
+				t.Errorf("expected at most 7 attempts, got %d", got.Attempts)
+			}
+		})
+	}
+
+	identical := func(uint) (float64, int64, error) { return math.Inf(1), 10, nil }
+	got, err := searchMinQuality(10, 95, QualityTarget{Metric: MetricPSNR, Threshold: 60}, identical)
+	if err != nil || !got.Met || got.Quality != 10 {
+		t.Errorf("expected infinite PSNR to meet the target at quality 10, got %+v, %v", got, err)
+	}
+}
+
+// TestQualityTargetValidate tests quality target validation
+func TestQualityTargetValidate(t *testing.T) {
+	invalid := []QualityTarget{
+		{Metric: MetricSSIM},
+		{Metric: MetricSSIM, Threshold: 1.5},
+		{Metric: MetricDSSIM, Threshold: 0.5},
+		{Metric: MetricPSNR, Threshold: -1},
+		{Metric: QualityMetric(9), Threshold: 1},
+		{Metric: MetricSSIM, Threshold: 0.9, MinQuality: 90, MaxQuality: 80},
+	}
+	for _, target := range invalid {
+		if err := target.validate(); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput for %+v, got %v", target, err)
+		}
+	}
+
+	if err := (QualityTarget{Metric: MetricPSNR, Threshold: 40}).validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestParseQualityMetric tests parsing metric names
+func TestParseQualityMetric(t *testing.T) {
+	for _, m := range []QualityMetric{MetricSSIM, MetricDSSIM, MetricPSNR} {
+		got, err := ParseQualityMetric(" " + m.String() + " ")
+		if err != nil || got != m {
+			t.Errorf("ParseQualityMetric(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseQualityMetric("butteraugli"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+}
+
+// TestEncodeToQuality tests picking the lowest quality above an SSIM target
+func TestEncodeToQuality(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	src := testPNG(t, 200, 150)
+
+	var buf bytes.Buffer
+	target := QualityTarget{Metric: MetricSSIM, Threshold: 0.95}
+	result, err := client.EncodeToQuality(ctx, bytes.NewReader(src), &buf, "jpeg", target)
+	if err != nil {
+		t.Fatalf("EncodeToQuality failed: %v", err)
+	}
+	if !result.Met || result.Metric < target.Threshold {
+		t.Errorf("expected SSIM of at least %g, got %+v", target.Threshold, result)
+	}
+	if result.Quality >= DefaultQuality {
+		t.Errorf("expected a quality below %d for a smooth gradient, got %d", DefaultQuality, result.Quality)
+	}
+	if int64(buf.Len()) != result.Size {
+		t.Errorf("expected %d bytes written, got %d", result.Size, buf.Len())
+	}
+
+	// The "jpg" spelling, also implied by a .jpg output path, is JPEG
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in.png")
+	if err := os.WriteFile(inPath, src, 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	if _, err := client.EncodeToQualityFile(ctx, inPath, filepath.Join(dir, "out.jpg"), "", target); err != nil {
+		t.Errorf("EncodeToQualityFile to out.jpg failed: %v", err)
+	}
+
+	_, err = client.EncodeToQuality(ctx, bytes.NewReader(src), &buf, "png", target)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for PNG output, got %v", err)
+	}
+}
@@ -0,0 +1,385 @@
+package mwclient
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// step is a single operation applied to a decoded image
+type step struct {
+	name  string
+	apply func(mw *imagick.MagickWand, s *settings) error
+
+	// whole steps get the wand with all frames and handle animations
+	// themselves; other steps run once per frame
+	whole bool
+}
+
+// Pipeline is a sequence of operations applied to a single decoded image.
+// The image is decoded once, every step runs on the same MagickWand, and the
+// result is encoded once, so chaining steps causes no generation loss.
+//
+// Build a pipeline with Client.Pipeline and the chainable step methods, then
+// execute it with Run or RunFile. A pipeline can be run any number of times.
+type Pipeline struct {
+	client *Client
+	opts   []Option
+	steps  []step
+	format string
+
+	// frame is the only frame kept if singleFrame is set
+	frame       int
+	singleFrame bool
+
+	// tune, if set, adjusts the encoder settings after prepareEncode,
+	// e.g. to meet a size target
+	tune func(ctx context.Context, mw *imagick.MagickWand, s *settings) error
+}
+
+// Pipeline returns an empty pipeline that runs on the client's workers.
+// The options override the client's settings for every run of the pipeline.
+func (c *Client) Pipeline(opts ...Option) *Pipeline {
+	return &Pipeline{client: c, opts: append([]Option(nil), opts...)}
+}
+
+// add appends a step and returns the pipeline for chaining
+func (p *Pipeline) add(name string, apply func(mw *imagick.MagickWand, s *settings) error) *Pipeline {
+	p.steps = append(p.steps, step{name: name, apply: apply})
+	return p
+}
+
+// addWhole is like add for steps that handle every frame of an animation
+// themselves
+func (p *Pipeline) addWhole(name string, apply func(mw *imagick.MagickWand, s *settings) error) *Pipeline {
+	p.steps = append(p.steps, step{name: name, apply: apply, whole: true})
+	return p
+}
+
+// AutoOrient rotates the image according to its EXIF orientation.
+// Failures are logged and do not abort the pipeline.
+func (p *Pipeline) AutoOrient() *Pipeline {
+	return p.add("AutoOrient", func(mw *imagick.MagickWand, s *settings) error {
+		if err := mw.AutoOrientImage(); err != nil {
+			s.logger.Error("Auto-orientation failed", "error", err)
+			// Continue despite error
+		}
+		return nil
+	})
+}
+
+// Resize resizes the image to width x height using the configured filter.
+// If either dimension is zero it is calculated from the other one, keeping
+// the aspect ratio.
+func (p *Pipeline) Resize(width, height uint) *Pipeline {
+	return p.add("Resize", func(mw *imagick.MagickWand, s *settings) error {
+		if width == 0 && height == 0 {
+			return fmt.Errorf("%w: invalid dimensions", ErrInvalidInput)
+		}
+
+		imageWidth := mw.GetImageWidth()
+		imageHeight := mw.GetImageHeight()
+		if imageWidth == 0 || imageHeight == 0 {
+			return fmt.Errorf("%w: image has no dimensions", ErrProcessing)
+		}
+
+		// Calculate the missing dimension, keeping aspect ratio
+		targetWidth, targetHeight := width, height
+		if targetWidth == 0 {
+			targetWidth = max(1, imageWidth*targetHeight/imageHeight)
+		}
+		if targetHeight == 0 {
+			targetHeight = max(1, imageHeight*targetWidth/imageWidth)
+		}
+
+		if err := mw.ResizeImage(targetWidth, targetHeight, s.filter); err != nil {
+			return fmt.Errorf("%w: failed to resize image: %v", ErrProcessing, err)
+		}
+		return nil
+	})
+}
+
+// Fit resizes the image to fit inside width x height, keeping the aspect
+// ratio. A zero dimension leaves that axis unconstrained.
+func (p *Pipeline) Fit(width, height uint) *Pipeline {
+	return p.ResizeTo(ResizeSpec{Width: width, Height: height, Mode: ResizeFit})
+}
+
+// fitDimensions scales srcWidth x srcHeight to the largest size that fits
+// inside maxWidth x maxHeight, keeping the aspect ratio. A zero maximum
+// leaves that axis unconstrained.
+func fitDimensions(srcWidth, srcHeight, maxWidth, maxHeight uint) (uint, uint) {
+	if srcWidth == 0 || srcHeight == 0 {
+		return 0, 0
+	}
+
+	// Compare maxWidth/srcWidth with maxHeight/srcHeight without floats
+	if maxHeight == 0 || (maxWidth != 0 && uint64(maxWidth)*uint64(srcHeight) <= uint64(maxHeight)*uint64(srcWidth)) {
+		return maxWidth, max(1, uint(uint64(srcHeight)*uint64(maxWidth)/uint64(srcWidth)))
+	}
+	return max(1, uint(uint64(srcWidth)*uint64(maxHeight)/uint64(srcHeight))), maxHeight
+}
+
+// Crop keeps the width x height region whose top-left corner is at x, y
+func (p *Pipeline) Crop(width, height uint, x, y int) *Pipeline {
+	return p.add("Crop", func(mw *imagick.MagickWand, s *settings) error {
+		if width == 0 || height == 0 {
+			return fmt.Errorf("%w: invalid crop dimensions", ErrInvalidInput)
+		}
+		if err := mw.CropImage(width, height, x, y); err != nil {
+			return fmt.Errorf("%w: failed to crop image: %v", ErrProcessing, err)
+		}
+		// Drop the virtual canvas offset left behind by the crop
+		if err := mw.ResetImagePage(""); err != nil {
+			return fmt.Errorf("%w: failed to reset image page: %v", ErrProcessing, err)
+		}
+		return nil
+	})
+}
+
+// Rotate rotates the image clockwise by degrees, filling the uncovered
+// corners with the configured background color
+func (p *Pipeline) Rotate(degrees float64) *Pipeline {
+	return p.add("Rotate", func(mw *imagick.MagickWand, s *settings) error {
+		bg := imagick.NewPixelWand()
+		defer bg.Destroy()
+		bg.SetColor(s.background)
+
+		if err := mw.RotateImage(bg, degrees); err != nil {
+			return fmt.Errorf("%w: failed to rotate image: %v", ErrProcessing, err)
+		}
+		return nil
+	})
+}
+
+// Composite draws the overlay image (e.g. a watermark) over the image with
+// its top-left corner at x, y
+func (p *Pipeline) Composite(overlay []byte, x, y int) *Pipeline {
+	return p.add("Composite", func(mw *imagick.MagickWand, s *settings) error {
+		if len(overlay) == 0 {
+			return fmt.Errorf("%w: overlay is empty", ErrInvalidInput)
+		}
+
+		ow := imagick.NewMagickWand()
+		defer ow.Destroy()
+
+		if err := ow.ReadImageBlob(overlay); err != nil {
+			return fmt.Errorf("%w: failed to read overlay: %v", ErrProcessing, err)
+		}
+		return composite(mw, ow, x, y)
+	})
+}
+
+// CompositeFile is like Composite but reads the overlay from a file path
+func (p *Pipeline) CompositeFile(overlayPath string, x, y int) *Pipeline {
+	return p.add("Composite", func(mw *imagick.MagickWand, s *settings) error {
+		if overlayPath == "" {
+			return fmt.Errorf("%w: overlay path is empty", ErrInvalidInput)
+		}
+
+		ow := imagick.NewMagickWand()
+		defer ow.Destroy()
+
+		if err := ow.ReadImage(overlayPath); err != nil {
+			return fmt.Errorf("%w: failed to read overlay: %v", ErrProcessing, err)
+		}
+		return composite(mw, ow, x, y)
+	})
+}
+
+// composite draws ow over mw with its top-left corner at x, y
+func composite(mw, ow *imagick.MagickWand, x, y int) error {
+	if err := mw.CompositeImage(ow, imagick.COMPOSITE_OP_OVER, true, x, y); err != nil {
+		return fmt.Errorf("%w: failed to composite image: %v", ErrProcessing, err)
+	}
+	return nil
+}
+
+// Strip removes all profiles and comments (EXIF, ICC, XMP, ...) from the image
+func (p *Pipeline) Strip() *Pipeline {
+	return p.add("Strip", func(mw *imagick.MagickWand, s *settings) error {
+		if err := mw.StripImage(); err != nil {
+			return fmt.Errorf("%w: failed to strip image: %v", ErrProcessing, err)
+		}
+		return nil
+	})
+}
+
+// Quality sets the compression quality (1-100) of the encoded result,
+// overriding the client's setting
+func (p *Pipeline) Quality(quality uint) *Pipeline {
+	p.opts = append(p.opts, WithQuality(quality))
+	return p
+}
+
+// Encode sets the output format. Without it the input format is kept, or
+// for RunFile the format is taken from the output file extension.
+func (p *Pipeline) Encode(format string) *Pipeline {
+	p.format = format
+	return p
+}
+
+// Run decodes the image from r, applies the steps and writes the encoded
+// result to w
+func (p *Pipeline) Run(ctx context.Context, r io.Reader, w io.Writer) error {
+	if r == nil || w == nil {
+		return fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
+	}
+
+	return p.run(ctx, p.format, func(mw *imagick.MagickWand, s *settings) error {
+		return readImage(mw, r, s)
+	}, func(mw *imagick.MagickWand, s *settings) error {
+		_, err := writeImage(mw, w, s)
+		return err
+	})
+}
+
+// RunFile reads the image from inputPath, applies the steps and writes the
+// result to outputPath
+func (p *Pipeline) RunFile(ctx context.Context, inputPath, outputPath string) error {
+	if inputPath == "" || outputPath == "" {
+		return fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
+	}
+
+	// Without Encode the output extension picks the format
+	format := cmp.Or(p.format, formatFromPath(outputPath))
+
+	return p.run(ctx, format, func(mw *imagick.MagickWand, s *settings) error {
+		return readImageFile(mw, inputPath, s)
+	}, func(mw *imagick.MagickWand, s *settings) error {
+		s.logger.Info("WriteImage", "Out", outputPath)
+		return writeImageFile(mw, outputPath)
+	})
+}
+
+// readImageFile decodes the image at path into mw. Every page of a PDF is
+// read, opened with the password from WithPdfPassword.
+func readImageFile(mw *imagick.MagickWand, path string, s *settings) error {
+	if err := checkFileSize(path, s); err != nil {
+		return err
+	}
+	if err := checkPdfLocked(path, s); err != nil {
+		return err
+	}
+	if err := setPdfPassword(mw, s); err != nil {
+		return err
+	}
+
+	s.logger.Info("ReadImage", "In", path)
+	if err := mw.ReadImage(path); err != nil {
+		if perr := passwordError(path, s, err); perr != nil {
+			return perr
+		}
+		return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
+	}
+	return nil
+}
+
+// run acquires a worker, decodes the image with read, applies the steps,
+// and encodes the result in format, or the input format if empty, with
+// write. Cancellation is checked between steps.
+func (p *Pipeline) run(ctx context.Context, format string, read, write func(mw *imagick.MagickWand, s *settings) error) error {
+	s, err := p.client.settingsFor(p.opts)
+	if err != nil {
+		return err
+	}
+
+	release, err := p.client.pool.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	mw := imagick.NewMagickWand()
+	defer func() { mw.Destroy() }()
+
+	if err := read(mw, &s); err != nil {
+		return err
+	}
+
+	// Render animation frames to full canvases
+	if mw, err = coalesceFrames(mw); err != nil {
+		return err
+	}
+	if p.singleFrame {
+		if mw, err = selectFrame(mw, p.frame); err != nil {
+			return err
+		}
+	}
+
+	for _, st := range p.steps {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+
+		s.logger.Debug("Pipeline step", "Step", st.name)
+		if st.whole {
+			err = st.apply(mw, &s)
+		} else {
+			err = forEachFrame(mw, func() error { return st.apply(mw, &s) })
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := prepareEncode(mw, format, &s); err != nil {
+		return err
+	}
+
+	if p.tune != nil {
+		if mw.GetNumberImages() > 1 {
+			return fmt.Errorf("%w: size and quality targets do not support multi-frame images", ErrInvalidInput)
+		}
+		if err := p.tune(ctx, mw, &s); err != nil {
+			return err
+		}
+	}
+
+	if mw, err = optimizeFrames(mw); err != nil {
+		return err
+	}
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	return write(mw, &s)
+}
+
+// prepareEncode applies the output policies, the output format, if not
+// empty, and the encoder options for the resulting format to every frame
+// of mw
+func prepareEncode(mw *imagick.MagickWand, format string, s *settings) error {
+	if format != "" {
+		s.logger.Info("SetImageFormat", "Format", format)
+	}
+
+	return forEachFrame(mw, func() error {
+		if err := applyOutputPolicies(mw, s); err != nil {
+			return err
+		}
+
+		// Set the output format if specified
+		if format != "" {
+			if err := mw.SetImageFormat(format); err != nil {
+				return fmt.Errorf("%w: failed to set image format: %v", ErrProcessing, err)
+			}
+		}
+
+		return applyEncoderOptions(mw, mw.GetImageFormat(), s)
+	})
+}
+
+// applyOutputPolicies applies the color management and strip policy to mw.
+// Color conversion comes first so an allowlisted ICC profile is the
+// converted one.
+func applyOutputPolicies(mw *imagick.MagickWand, s *settings) error {
+	if err := convertColor(mw, s.color); err != nil {
+		return err
+	}
+	return applyStripPolicy(mw, s.strip)
+}
@@ -0,0 +1,144 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testPNG returns a width x height PNG with a horizontal gradient
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// decodeConfig returns the format and dimensions of an encoded image
+func decodeConfig(t *testing.T, data []byte) (string, int, int) {
+	t.Helper()
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode result image: %v", err)
+	}
+	return format, cfg.Width, cfg.Height
+}
+
+// TestPipelineInvalidInput tests the error cases for Pipeline
+func TestPipelineInvalidInput(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	var buf bytes.Buffer
+
+	err := client.Pipeline().Run(ctx, nil, &buf)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with nil reader, got %v", err)
+	}
+
+	err = client.Pipeline().RunFile(ctx, "", "output.png")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with empty path, got %v", err)
+	}
+
+	err = client.Pipeline().Run(ctx, bytes.NewReader([]byte("not an image")), &buf)
+	if !errors.Is(err, ErrProcessing) {
+		t.Errorf("expected ErrProcessing with invalid image data, got %v", err)
+	}
+
+	src := testPNG(t, 40, 20)
+
+	err = client.Pipeline().Resize(0, 0).Run(ctx, bytes.NewReader(src), &buf)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with zero dimensions, got %v", err)
+	}
+
+	err = client.Pipeline().Composite(nil, 0, 0).Run(ctx, bytes.NewReader(src), &buf)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with empty overlay, got %v", err)
+	}
+}
+
+// TestPipelineRun tests chaining several steps on one decoded image
+func TestPipelineRun(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	src := testPNG(t, 400, 200)
+	watermark := testPNG(t, 10, 10)
+
+	p := client.Pipeline(WithQuality(80)).
+		AutoOrient().
+		Resize(200, 0).
+		Crop(100, 80, 10, 10).
+		Rotate(90).
+		Composite(watermark, 5, 5).
+		Strip().
+		Encode("jpeg")
+
+	// A pipeline can be run more than once
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		if err := p.Run(context.Background(), bytes.NewReader(src), &buf); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		format, width, height := decodeConfig(t, buf.Bytes())
+		if format != "jpeg" {
+			t.Errorf("expected jpeg output, got %s", format)
+		}
+		if width != 80 || height != 100 {
+			t.Errorf("expected 80x100 output, got %dx%d", width, height)
+		}
+	}
+
+	// RunFile with the format taken from the output extension
+	tempDir := t.TempDir()
+	inputPath := filepath.Join(tempDir, "input.png")
+	outputPath := filepath.Join(tempDir, "output.png")
+	if err := os.WriteFile(inputPath, src, 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	err := client.Pipeline().Resize(0, 50).RunFile(context.Background(), inputPath, outputPath)
+	if err != nil {
+		t.Fatalf("RunFile failed: %v", err)
+	}
+
+	out, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Output file was not created: %v", err)
+	}
+	if _, width, height := decodeConfig(t, out); width != 100 || height != 50 {
+		t.Errorf("expected 100x50 output, got %dx%d", width, height)
+	}
+}
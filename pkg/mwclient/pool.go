@@ -0,0 +1,94 @@
+package mwclient
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// PoolStats is a snapshot of a client's worker pool
+type PoolStats struct {
+	Workers   int           // maximum number of operations in flight
+	InFlight  int           // operations currently holding a worker
+	Queued    int           // operations waiting for a worker
+	Processed int64         // operations that have acquired a worker so far
+	TotalWait time.Duration // cumulative time spent waiting for a worker
+	MaxWait   time.Duration // longest single wait for a worker
+}
+
+// AverageWait returns the mean time an operation waited for a worker
+func (s PoolStats) AverageWait() time.Duration {
+	if s.Processed == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Processed)
+}
+
+// pool bounds the number of MagickWands processed concurrently
+type pool struct {
+	slots chan struct{}
+
+	queued    atomic.Int64
+	inFlight  atomic.Int64
+	processed atomic.Int64
+	totalWait atomic.Int64
+	maxWait   atomic.Int64
+}
+
+// newPool creates a pool with the given number of workers (at least one)
+func newPool(workers int) *pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &pool{slots: make(chan struct{}, workers)}
+}
+
+// acquire blocks until a worker is free or ctx is done.
+// The returned function must be called to release the worker.
+func (p *pool) acquire(ctx context.Context) (func(), error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	p.queued.Add(1)
+
+	select {
+	case p.slots <- struct{}{}:
+		p.queued.Add(-1)
+	case <-ctx.Done():
+		p.queued.Add(-1)
+		return nil, checkContext(ctx)
+	}
+
+	wait := int64(time.Since(start))
+	p.totalWait.Add(wait)
+	for {
+		cur := p.maxWait.Load()
+		if wait <= cur || p.maxWait.CompareAndSwap(cur, wait) {
+			break
+		}
+	}
+	p.processed.Add(1)
+	p.inFlight.Add(1)
+
+	var once atomic.Bool
+	return func() {
+		if once.CompareAndSwap(false, true) {
+			p.inFlight.Add(-1)
+			<-p.slots
+		}
+	}, nil
+}
+
+// stats returns a snapshot of the pool's counters
+func (p *pool) stats() PoolStats {
+	return PoolStats{
+		Workers:   cap(p.slots),
+		InFlight:  int(p.inFlight.Load()),
+		Queued:    int(p.queued.Load()),
+		Processed: p.processed.Load(),
+		TotalWait: time.Duration(p.totalWait.Load()),
+		MaxWait:   time.Duration(p.maxWait.Load()),
+	}
+}
@@ -0,0 +1,103 @@
+package mwclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestPoolBoundsConcurrency tests that no more than Workers operations run at once
+func TestPoolBoundsConcurrency(t *testing.T) {
+	p := newPool(3)
+
+	var running, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := p.acquire(context.Background())
+			if err != nil {
+				t.Errorf("acquire failed: %v", err)
+				return
+			}
+			defer release()
+
+			n := running.Add(1)
+			for {
+				cur := peak.Load()
+				if n <= cur || peak.CompareAndSwap(cur, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 concurrent operations, got %d", peak.Load())
+	}
+
+	stats := p.stats()
+	if stats.Workers != 3 {
+		t.Errorf("expected 3 workers, got %d", stats.Workers)
+	}
+	if stats.Processed != 12 {
+		t.Errorf("expected 12 processed, got %d", stats.Processed)
+	}
+	if stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("expected idle pool, got %+v", stats)
+	}
+	if stats.MaxWait <= 0 || stats.AverageWait() > stats.MaxWait {
+		t.Errorf("unexpected wait times: %+v", stats)
+	}
+}
+
+// TestPoolQueueAndCancel tests queue introspection and cancellation while waiting
+func TestPoolQueueAndCancel(t *testing.T) {
+	p := newPool(0) // clamped to one worker
+
+	release, err := p.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := p.acquire(ctx)
+		done <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for p.stats().Queued != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("waiter was never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := p.stats().InFlight; got != 1 {
+		t.Errorf("expected 1 in flight, got %d", got)
+	}
+
+	cancel()
+	err = <-done
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, ErrProcessing) {
+		t.Errorf("expected canceled processing error, got %v", err)
+	}
+	if got := p.stats().Queued; got != 0 {
+		t.Errorf("expected empty queue, got %d", got)
+	}
+
+	// Releasing twice must not free a second slot
+	release()
+	release()
+	if got := p.stats().InFlight; got != 0 {
+		t.Errorf("expected 0 in flight, got %d", got)
+	}
+}
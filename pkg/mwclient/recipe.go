@@ -0,0 +1,373 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecipeVersion is the recipe schema version understood by this package
+const RecipeVersion = 1
+
+// Recipe is a declarative description of a processing job, e.g.
+//
+//	{"ops":[{"resize":{"width":800,"mode":"fit"}},{"format":"webp","quality":80}]}
+//
+// Recipes are parsed with ParseRecipeJSON or ParseRecipeYAML and executed
+// with Client.RunRecipe, or compiled to a Pipeline with Client.RecipePipeline.
+type Recipe struct {
+	Version int        `json:"version,omitempty" yaml:"version,omitempty"`
+	Name    string     `json:"name,omitempty" yaml:"name,omitempty"`
+	Ops     []RecipeOp `json:"ops" yaml:"ops"`
+}
+
+// RecipeOp is a single operation of a Recipe. Exactly one operation must be
+// set per entry; format and quality together form the encode operation,
+// which may only appear as the last entry. The pdf operation may only appear
+// as the first entry.
+type RecipeOp struct {
+	Pdf        *RecipePdf    `json:"pdf,omitempty" yaml:"pdf,omitempty"`
+	AutoOrient bool          `json:"auto_orient,omitempty" yaml:"auto_orient,omitempty"`
+	Resize     *RecipeResize `json:"resize,omitempty" yaml:"resize,omitempty"`
+	Crop       *RecipeCrop   `json:"crop,omitempty" yaml:"crop,omitempty"`
+	Rotate     *float64      `json:"rotate,omitempty" yaml:"rotate,omitempty"`
+	Strip      bool          `json:"strip,omitempty" yaml:"strip,omitempty"`
+	Format     string        `json:"format,omitempty" yaml:"format,omitempty"`
+	Quality    uint          `json:"quality,omitempty" yaml:"quality,omitempty"`
+}
+
+// RecipeResize describes a resize operation. Mode is one of the names
+// accepted by ParseResizeMode; see ResizeSpec for the other fields.
+type RecipeResize struct {
+	Width      uint        `json:"width,omitempty" yaml:"width,omitempty"`
+	Height     uint        `json:"height,omitempty" yaml:"height,omitempty"`
+	Mode       string      `json:"mode,omitempty" yaml:"mode,omitempty"`
+	OnlyShrink bool        `json:"only_shrink,omitempty" yaml:"only_shrink,omitempty"`
+	Background string      `json:"background,omitempty" yaml:"background,omitempty"`
+	Gravity    string      `json:"gravity,omitempty" yaml:"gravity,omitempty"`
+	Focal      *FocalPoint `json:"focal,omitempty" yaml:"focal,omitempty"`
+}
+
+// spec converts the recipe resize to a ResizeSpec
+func (r RecipeResize) spec() (ResizeSpec, error) {
+	mode, err := ParseResizeMode(r.Mode)
+	if err != nil {
+		return ResizeSpec{}, err
+	}
+	gravity, err := ParseGravity(r.Gravity)
+	if err != nil {
+		return ResizeSpec{}, err
+	}
+	return ResizeSpec{
+		Width:      r.Width,
+		Height:     r.Height,
+		Mode:       mode,
+		OnlyShrink: r.OnlyShrink,
+		Background: r.Background,
+		Gravity:    gravity,
+		Focal:      r.Focal,
+	}, nil
+}
+
+// RecipeCrop describes a crop of Width x Height pixels, placed either at
+// offset X, Y or by Gravity or Focal as for CropSpec
+type RecipeCrop struct {
+	Width   uint        `json:"width" yaml:"width"`
+	Height  uint        `json:"height" yaml:"height"`
+	X       int         `json:"x,omitempty" yaml:"x,omitempty"`
+	Y       int         `json:"y,omitempty" yaml:"y,omitempty"`
+	Gravity string      `json:"gravity,omitempty" yaml:"gravity,omitempty"`
+	Focal   *FocalPoint `json:"focal,omitempty" yaml:"focal,omitempty"`
+}
+
+// placed reports whether the crop is placed by gravity or focal point
+func (r RecipeCrop) placed() bool {
+	return r.Gravity != "" || r.Focal != nil
+}
+
+// spec converts a placed recipe crop to a CropSpec
+func (r RecipeCrop) spec() (CropSpec, error) {
+	gravity, err := ParseGravity(r.Gravity)
+	if err != nil {
+		return CropSpec{}, err
+	}
+	return CropSpec{Width: r.Width, Height: r.Height, Gravity: gravity, Focal: r.Focal}, nil
+}
+
+// RecipePdf describes how a PDF input is rasterized, as for PdfOptions. Pages
+// is a selection in the form accepted by ParsePageSelection, and Montage, if
+// set, combines the pages into a montage. The ops that follow run on every
+// page, or every sheet of the montage.
+type RecipePdf struct {
+	Pages   string         `json:"pages,omitempty" yaml:"pages,omitempty"`
+	DPI     float64        `json:"dpi,omitempty" yaml:"dpi,omitempty"`
+	Height  int            `json:"height,omitempty" yaml:"height,omitempty"`
+	Montage *RecipeMontage `json:"montage,omitempty" yaml:"montage,omitempty"`
+	Strict  bool           `json:"strict,omitempty" yaml:"strict,omitempty"`
+}
+
+// options converts the recipe PDF op to PdfOptions
+func (r RecipePdf) options() (PdfOptions, error) {
+	sel, err := ParsePageSelection(r.Pages)
+	if err != nil {
+		return PdfOptions{}, err
+	}
+	pdf := PdfOptions{Pages: sel, DPI: r.DPI, Height: r.Height, Strict: r.Strict}
+	if r.Montage != nil {
+		pdf.Montage = true
+		pdf.Layout = r.Montage.layout()
+	}
+	return pdf, nil
+}
+
+// RecipeMontage describes the layout of a montage; see MontageLayout for
+// the fields
+type RecipeMontage struct {
+	Columns     int    `json:"columns,omitempty" yaml:"columns,omitempty"`
+	Rows        int    `json:"rows,omitempty" yaml:"rows,omitempty"`
+	Spacing     int    `json:"spacing,omitempty" yaml:"spacing,omitempty"`
+	Background  string `json:"background,omitempty" yaml:"background,omitempty"`
+	Border      int    `json:"border,omitempty" yaml:"border,omitempty"`
+	BorderColor string `json:"border_color,omitempty" yaml:"border_color,omitempty"`
+	Shadow      bool   `json:"shadow,omitempty" yaml:"shadow,omitempty"`
+	Captions    bool   `json:"captions,omitempty" yaml:"captions,omitempty"`
+	MaxWidth    int    `json:"max_width,omitempty" yaml:"max_width,omitempty"`
+	MaxHeight   int    `json:"max_height,omitempty" yaml:"max_height,omitempty"`
+}
+
+// layout converts the recipe montage to a MontageLayout
+func (r RecipeMontage) layout() MontageLayout {
+	return MontageLayout(r)
+}
+
+// ParseRecipeJSON parses and validates a JSON recipe. Unknown fields are rejected.
+func ParseRecipeJSON(data []byte) (*Recipe, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var recipe Recipe
+	if err := dec.Decode(&recipe); err != nil {
+		return nil, fmt.Errorf("%w: invalid recipe: %v", ErrInvalidInput, err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: invalid recipe: unexpected data after recipe", ErrInvalidInput)
+	}
+
+	if err := recipe.Validate(); err != nil {
+		return nil, err
+	}
+	return &recipe, nil
+}
+
+// ParseRecipeYAML parses and validates a YAML recipe. Unknown fields and
+// further documents are rejected.
+func ParseRecipeYAML(data []byte) (*Recipe, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var recipe Recipe
+	if err := dec.Decode(&recipe); err != nil {
+		return nil, fmt.Errorf("%w: invalid recipe: %v", ErrInvalidInput, err)
+	}
+	var extra any
+	if err := dec.Decode(&extra); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: invalid recipe: unexpected document after recipe", ErrInvalidInput)
+	}
+
+	if err := recipe.Validate(); err != nil {
+		return nil, err
+	}
+	return &recipe, nil
+}
+
+// Validate checks the recipe against the schema. Errors wrap ErrInvalidInput
+// and name the offending operation, e.g. "ops[1].resize".
+func (r *Recipe) Validate() error {
+	if r.Version != 0 && r.Version != RecipeVersion {
+		return fmt.Errorf("%w: unsupported recipe version %d", ErrInvalidInput, r.Version)
+	}
+
+	if len(r.Ops) == 0 {
+		return fmt.Errorf("%w: recipe has no ops", ErrInvalidInput)
+	}
+
+	for i, op := range r.Ops {
+		if field, err := op.validate(); err != nil {
+			return fmt.Errorf("%w: ops[%d]%s: %v", ErrInvalidInput, i, field, err)
+		}
+
+		if op.isEncode() && i != len(r.Ops)-1 {
+			return fmt.Errorf("%w: ops[%d]: format and quality must be the last op", ErrInvalidInput, i)
+		}
+		if op.Pdf != nil && i != 0 {
+			return fmt.Errorf("%w: ops[%d]: pdf must be the first op", ErrInvalidInput, i)
+		}
+		if op.Format != "" && r.isPdf() {
+			return fmt.Errorf("%w: ops[%d].format: the output extension picks the format of PDF pages", ErrInvalidInput, i)
+		}
+	}
+
+	return nil
+}
+
+// isPdf reports whether the recipe rasterizes a PDF
+func (r *Recipe) isPdf() bool {
+	return len(r.Ops) > 0 && r.Ops[0].Pdf != nil
+}
+
+// isEncode reports whether the op is the encode operation
+func (op RecipeOp) isEncode() bool {
+	return op.Format != "" || op.Quality != 0
+}
+
+// validate checks a single op and returns the offending field, if any
+func (op RecipeOp) validate() (string, error) {
+	set := 0
+	for _, ok := range []bool{op.Pdf != nil, op.AutoOrient, op.Resize != nil, op.Crop != nil, op.Rotate != nil, op.Strip, op.isEncode()} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case set == 0:
+		return "", errors.New("op is empty")
+	case set > 1:
+		return "", errors.New("op must contain exactly one operation")
+	}
+
+	switch {
+	case op.Pdf != nil:
+		if _, err := ParsePageSelection(op.Pdf.Pages); err != nil {
+			return ".pdf", fmt.Errorf("invalid pages %q", op.Pdf.Pages)
+		}
+		pdf, _ := op.Pdf.options()
+		if err := pdf.check(); err != nil {
+			return ".pdf", err
+		}
+
+	case op.Resize != nil:
+		if _, err := ParseResizeMode(op.Resize.Mode); err != nil {
+			return ".resize", fmt.Errorf("unknown mode %q", op.Resize.Mode)
+		}
+		if _, err := ParseGravity(op.Resize.Gravity); err != nil {
+			return ".resize", fmt.Errorf("unknown gravity %q", op.Resize.Gravity)
+		}
+		spec, _ := op.Resize.spec()
+		if err := spec.check(); err != nil {
+			return ".resize", err
+		}
+
+	case op.Crop != nil:
+		if op.Crop.Width == 0 || op.Crop.Height == 0 {
+			return ".crop", errors.New("width and height are required")
+		}
+		if op.Crop.X < 0 || op.Crop.Y < 0 {
+			return ".crop", errors.New("offset must not be negative")
+		}
+		if op.Crop.placed() {
+			if op.Crop.X != 0 || op.Crop.Y != 0 {
+				return ".crop", errors.New("offset cannot be combined with gravity or focal")
+			}
+			if _, err := ParseGravity(op.Crop.Gravity); err != nil {
+				return ".crop", fmt.Errorf("unknown gravity %q", op.Crop.Gravity)
+			}
+			spec, _ := op.Crop.spec()
+			if err := checkPlacement(spec.Gravity, spec.Focal); err != nil {
+				return ".crop", err
+			}
+		}
+
+	case op.isEncode():
+		if op.Quality > 100 {
+			return ".quality", errors.New("must be between 1 and 100")
+		}
+	}
+
+	return "", nil
+}
+
+// RecipePipeline validates the recipe and compiles it to a Pipeline. Recipes
+// with a pdf op produce a file per page and can only be run by
+// RunRecipeFile.
+func (c *Client) RecipePipeline(recipe *Recipe, opts ...Option) (*Pipeline, error) {
+	if recipe == nil {
+		return nil, fmt.Errorf("%w: recipe is nil", ErrInvalidInput)
+	}
+
+	if err := recipe.Validate(); err != nil {
+		return nil, err
+	}
+	if recipe.isPdf() {
+		return nil, fmt.Errorf("%w: recipes with a pdf op must be run with RunRecipeFile", ErrInvalidInput)
+	}
+
+	return c.recipePipeline(recipe.Ops, opts), nil
+}
+
+// recipePipeline compiles validated ops, except a pdf op, to a Pipeline
+func (c *Client) recipePipeline(ops []RecipeOp, opts []Option) *Pipeline {
+	p := c.Pipeline(opts...)
+	for _, op := range ops {
+		switch {
+		case op.AutoOrient:
+			p.AutoOrient()
+		case op.Resize != nil:
+			spec, _ := op.Resize.spec() // validated above
+			p.ResizeTo(spec)
+		case op.Crop != nil && op.Crop.placed():
+			spec, _ := op.Crop.spec() // validated above
+			p.CropTo(spec)
+		case op.Crop != nil:
+			p.Crop(op.Crop.Width, op.Crop.Height, op.Crop.X, op.Crop.Y)
+		case op.Rotate != nil:
+			p.Rotate(*op.Rotate)
+		case op.Strip:
+			p.Strip()
+		case op.isEncode():
+			if op.Format != "" {
+				p.Encode(op.Format)
+			}
+			if op.Quality != 0 {
+				p.Quality(op.Quality)
+			}
+		}
+	}
+
+	return p
+}
+
+// RunRecipe executes the recipe against the image read from r and writes the
+// result to w
+func (c *Client) RunRecipe(ctx context.Context, recipe *Recipe, r io.Reader, w io.Writer, opts ...Option) error {
+	p, err := c.RecipePipeline(recipe, opts...)
+	if err != nil {
+		return err
+	}
+	return p.Run(ctx, r, w)
+}
+
+// RunRecipeFile executes the recipe against the image at inputPath and writes
+// the result to outputPath. A recipe with a pdf op converts the PDF at
+// inputPath as ConvertPdf does, running the other ops on every page.
+func (c *Client) RunRecipeFile(ctx context.Context, recipe *Recipe, inputPath, outputPath string, opts ...Option) error {
+	if recipe != nil && recipe.isPdf() {
+		if err := recipe.Validate(); err != nil {
+			return err
+		}
+		pdf, _ := recipe.Ops[0].Pdf.options() // validated above
+		p := c.recipePipeline(recipe.Ops[1:], opts)
+		_, err := c.convertPdf(ctx, inputPath, outputPath, pdf, p.steps, p.opts)
+		return err
+	}
+
+	p, err := c.RecipePipeline(recipe, opts...)
+	if err != nil {
+		return err
+	}
+	return p.RunFile(ctx, inputPath, outputPath)
+}
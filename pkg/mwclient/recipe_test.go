@@ -0,0 +1,275 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseRecipeJSON tests parsing a valid JSON recipe
+func TestParseRecipeJSON(t *testing.T) {
+	recipe, err := ParseRecipeJSON([]byte(`{
+		"version": 1,
+		"name": "card",
+		"ops": [
+			{"auto_orient": true},
+			{"resize": {"width": 800, "mode": "fit"}},
+			{"crop": {"width": 800, "height": 600, "gravity": "north_east"}},
+			{"rotate": 0},
+			{"strip": true},
+			{"format": "webp", "quality": 80}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if recipe.Name != "card" || len(recipe.Ops) != 6 {
+		t.Fatalf("unexpected recipe: %+v", recipe)
+	}
+	if r := recipe.Ops[1].Resize; r == nil || r.Width != 800 || r.Mode != "fit" {
+		t.Errorf("unexpected resize op: %+v", recipe.Ops[1])
+	}
+	if c := recipe.Ops[2].Crop; c == nil || c.Gravity != "north_east" {
+		t.Errorf("unexpected crop op: %+v", recipe.Ops[2])
+	}
+	if recipe.Ops[3].Rotate == nil {
+		t.Error("expected rotate op with zero degrees to be set")
+	}
+	if last := recipe.Ops[5]; last.Format != "webp" || last.Quality != 80 {
+		t.Errorf("unexpected encode op: %+v", last)
+	}
+}
+
+// TestParseRecipeYAML tests parsing a valid YAML recipe
+func TestParseRecipeYAML(t *testing.T) {
+	recipe, err := ParseRecipeYAML([]byte(`
+version: 1
+ops:
+  - resize:
+      width: 400
+      height: 300
+      mode: stretch
+  - resize:
+      width: 200
+      height: 200
+      mode: cover
+      only_shrink: true
+      focal:
+        x: 0.3
+        y: 0.6
+  - format: jpeg
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(recipe.Ops) != 3 || recipe.Ops[0].Resize.Mode != "stretch" || !recipe.Ops[1].Resize.OnlyShrink || recipe.Ops[1].Resize.Focal == nil || recipe.Ops[2].Format != "jpeg" {
+		t.Errorf("unexpected recipe: %+v", recipe)
+	}
+
+	_, err = ParseRecipeYAML([]byte("ops:\n  - strip: true\n---\nops:\n  - format: png\n"))
+	if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), "unexpected document") {
+		t.Errorf("expected a second document to be rejected, got %v", err)
+	}
+}
+
+// TestParseRecipePdf tests parsing a recipe that rasterizes a PDF
+func TestParseRecipePdf(t *testing.T) {
+	recipe, err := ParseRecipeYAML([]byte(`
+ops:
+  - pdf:
+      pages: 1-3,last
+      dpi: 150
+      montage:
+        columns: 2
+        spacing: 10
+        border_color: black
+        captions: true
+  - resize:
+      width: 1200
+      mode: fit
+  - quality: 80
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pdf, err := recipe.Ops[0].Pdf.options()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := MontageLayout{Columns: 2, Spacing: 10, BorderColor: "black", Captions: true}
+	if pdf.Pages.String() != "1-3,last" || pdf.DPI != 150 || !pdf.Montage || pdf.Layout != want {
+		t.Errorf("unexpected PDF options: %+v", pdf)
+	}
+
+	// Pages are written to files, so pipelines cannot run the recipe
+	c := &Client{settings: defaultSettings()}
+	if _, err := c.RecipePipeline(recipe); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput compiling a PDF recipe, got %v", err)
+	}
+}
+
+// TestRecipeValidation tests that invalid recipes are rejected
+func TestRecipeValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipe  string
+		wantErr string
+	}{
+		{"malformed", `{"ops": [`, "invalid recipe"},
+		{"unknown field", `{"ops": [{"blur": 3}]}`, "unknown field"},
+		{"trailing data", `{"ops": [{"strip": true}]} {}`, "unexpected data"},
+		{"unsupported version", `{"version": 2, "ops": [{"strip": true}]}`, "version 2"},
+		{"no ops", `{"ops": []}`, "no ops"},
+		{"empty op", `{"ops": [{}]}`, "ops[0]: op is empty"},
+		{"two operations", `{"ops": [{"strip": true, "auto_orient": true}]}`, "exactly one operation"},
+		{"resize without size", `{"ops": [{"resize": {"mode": "fit"}}]}`, "ops[0].resize"},
+		{"stretch without height", `{"ops": [{"resize": {"width": 10, "mode": "stretch"}}]}`, "required for stretch"},
+		{"unknown mode", `{"ops": [{"resize": {"width": 10, "mode": "zoom"}}]}`, `unknown mode "zoom"`},
+		{"pad without height", `{"ops": [{"resize": {"width": 10, "mode": "pad"}}]}`, "required for pad"},
+		{"crop without size", `{"ops": [{"crop": {"width": 10}}]}`, "ops[0].crop"},
+		{"negative crop offset", `{"ops": [{"crop": {"width": 10, "height": 10, "x": -1}}]}`, "negative"},
+		{"offset with gravity", `{"ops": [{"crop": {"width": 10, "height": 10, "x": 5, "gravity": "north"}}]}`, "cannot be combined"},
+		{"unknown gravity", `{"ops": [{"crop": {"width": 10, "height": 10, "gravity": "up"}}]}`, `unknown gravity "up"`},
+		{"focal out of range", `{"ops": [{"resize": {"width": 10, "height": 10, "mode": "fill", "focal": {"x": 2, "y": 0}}}]}`, "focal point"},
+		{"quality out of range", `{"ops": [{"quality": 101}]}`, "ops[0].quality"},
+		{"encode not last", `{"ops": [{"format": "png"}, {"strip": true}]}`, "ops[0]: format and quality must be the last op"},
+		{"pdf not first", `{"ops": [{"strip": true}, {"pdf": {}}]}`, "ops[1]: pdf must be the first op"},
+		{"invalid pages", `{"ops": [{"pdf": {"pages": "0"}}]}`, `ops[0].pdf: invalid pages "0"`},
+		{"negative DPI", `{"ops": [{"pdf": {"dpi": -1}}]}`, "ops[0].pdf: DPI must not be negative"},
+		{"negative montage spacing", `{"ops": [{"pdf": {"montage": {"spacing": -2}}}]}`, "montage spacing must not be negative"},
+		{"unknown montage field", `{"ops": [{"pdf": {"montage": {"cols": 2}}}]}`, "unknown field"},
+		{"format of PDF pages", `{"ops": [{"pdf": {}}, {"format": "png"}]}`, "ops[1].format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRecipeJSON([]byte(tt.recipe))
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("expected ErrInvalidInput, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	_, err := ParseRecipeYAML([]byte("ops:\n  - sharpen: 1\n"))
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for unknown YAML field, got %v", err)
+	}
+}
+
+// TestFitDimensions tests the fit-inside geometry
+func TestFitDimensions(t *testing.T) {
+	tests := []struct {
+		srcW, srcH, maxW, maxH uint
+		wantW, wantH           uint
+	}{
+		{400, 200, 200, 200, 200, 100},
+		{200, 400, 200, 200, 100, 200},
+		{400, 200, 800, 0, 800, 400},
+		{400, 200, 0, 50, 100, 50},
+		{400, 200, 100, 100, 100, 50},
+		{1000, 1, 10, 10, 10, 1},
+		{0, 200, 100, 100, 0, 0},
+	}
+
+	for _, tt := range tests {
+		w, h := fitDimensions(tt.srcW, tt.srcH, tt.maxW, tt.maxH)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitDimensions(%d, %d, %d, %d) = %dx%d, want %dx%d",
+				tt.srcW, tt.srcH, tt.maxW, tt.maxH, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+// TestRunRecipe tests executing a recipe against an image
+func TestRunRecipe(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	recipe, err := ParseRecipeJSON([]byte(`{"ops":[{"resize":{"width":100,"height":100,"mode":"fit"}},{"format":"jpeg","quality":80}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := client.RunRecipe(context.Background(), recipe, bytes.NewReader(testPNG(t, 400, 200)), &buf); err != nil {
+		t.Fatalf("RunRecipe failed: %v", err)
+	}
+
+	format, width, height := decodeConfig(t, buf.Bytes())
+	if format != "jpeg" || width != 100 || height != 50 {
+		t.Errorf("expected 100x50 jpeg, got %dx%d %s", width, height, format)
+	}
+
+	if err := client.RunRecipe(context.Background(), nil, &buf, &buf); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with nil recipe, got %v", err)
+	}
+}
+
+// TestRunRecipePdf tests executing a recipe against the pages of a PDF
+func TestRunRecipePdf(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	var inputs []string
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, filepath.Base(pagePath("in.png", i, 3)))
+		if err := os.WriteFile(path, testPNG(t, 200, 100), 0o644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		inputs = append(inputs, path)
+	}
+	pdfPath := filepath.Join(dir, "doc.pdf")
+	if err := client.AssemblePDFFile(ctx, inputs, pdfPath, PDFLayout{DPI: 72}); err != nil {
+		t.Fatalf("AssemblePDFFile failed: %v", err)
+	}
+
+	recipe, err := ParseRecipeJSON([]byte(`{"ops":[
+		{"pdf": {"pages": "2-3", "dpi": 72}},
+		{"resize": {"width": 50, "mode": "fit"}},
+		{"quality": 80}
+	]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := filepath.Join(dir, "out.jpg")
+	if err := client.RunRecipeFile(ctx, recipe, pdfPath, out); err != nil {
+		t.Fatalf("RunRecipeFile failed: %v", err)
+	}
+	for _, index := range []int{1, 2} {
+		data, err := os.ReadFile(pagePath(out, index, 2))
+		if err != nil {
+			t.Fatalf("Page %d was not written: %v", index+1, err)
+		}
+		if format, w, h := decodeConfig(t, data); format != "jpeg" || w != 50 || h != 25 {
+			t.Errorf("page %d: expected 50x25 jpeg, got %dx%d %s", index+1, w, h, format)
+		}
+	}
+
+	if err := client.RunRecipe(ctx, recipe, bytes.NewReader(nil), &bytes.Buffer{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput running a PDF recipe on a reader, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package mwclient
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// RenditionSpec describes one output of a multi-rendition job
+type RenditionSpec struct {
+	Name       string // unique name identifying the rendition
+	Width      uint
+	Height     uint
+	Mode       ResizeMode
+	OnlyShrink bool
+	Format     string // empty keeps the input format
+	Quality    uint   // 0 uses the client's quality
+	Path       string // output path, used by RenditionsFile
+}
+
+// resizeSpec returns the resize part of the rendition
+func (spec RenditionSpec) resizeSpec() ResizeSpec {
+	return ResizeSpec{Width: spec.Width, Height: spec.Height, Mode: spec.Mode, OnlyShrink: spec.OnlyShrink}
+}
+
+// RenditionResult describes the outcome of one rendition
+type RenditionResult struct {
+	Name   string
+	Width  uint
+	Height uint
+	Format string
+	Size   int64 // encoded size in bytes
+	Err    error
+}
+
+// validateRenditions checks the specs of a multi-rendition job
+func validateRenditions(specs []RenditionSpec, needPath bool) error {
+	if len(specs) == 0 {
+		return fmt.Errorf("%w: no renditions", ErrInvalidInput)
+	}
+
+	names := make(map[string]bool, len(specs))
+	for i, spec := range specs {
+		if spec.Name == "" {
+			return fmt.Errorf("%w: rendition %d has no name", ErrInvalidInput, i)
+		}
+		if names[spec.Name] {
+			return fmt.Errorf("%w: duplicate rendition name %q", ErrInvalidInput, spec.Name)
+		}
+		names[spec.Name] = true
+
+		if needPath && spec.Path == "" {
+			return fmt.Errorf("%w: rendition %q has no output path", ErrInvalidInput, spec.Name)
+		}
+		if spec.Quality > 100 {
+			return fmt.Errorf("%w: rendition %q: quality must be between 1 and 100", ErrInvalidInput, spec.Name)
+		}
+		if err := spec.resizeSpec().validate(); err != nil {
+			return fmt.Errorf("rendition %q: %w", spec.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Renditions produces every rendition in specs from a single decode of the
+// image read from r. The image is decoded and auto-oriented once, and each
+// rendition is resized from a copy of that master. dst is called once per
+// rendition to obtain its writer.
+//
+// A failing rendition is reported in its result and does not stop the
+// others. The returned error is only set when no rendition could be
+// attempted, e.g. for invalid specs, an undecodable input or cancellation.
+func (c *Client) Renditions(ctx context.Context, r io.Reader, specs []RenditionSpec, dst func(spec RenditionSpec) (io.Writer, error), opts ...Option) ([]RenditionResult, error) {
+	if r == nil || dst == nil {
+		return nil, fmt.Errorf("%w: reader or destination is nil", ErrInvalidInput)
+	}
+
+	if err := validateRenditions(specs, false); err != nil {
+		return nil, err
+	}
+
+	return c.renditions(ctx, specs, func(mw *imagick.MagickWand, s *settings) error {
+		return readImage(mw, r, s)
+	}, func(mw *imagick.MagickWand, spec RenditionSpec, s *settings) (int64, error) {
+		w, err := dst(spec)
+		if err != nil {
+			return 0, err
+		}
+		if w == nil {
+			return 0, fmt.Errorf("%w: no writer for rendition %q", ErrInvalidInput, spec.Name)
+		}
+		return writeImage(mw, w, s)
+	}, opts)
+}
+
+// RenditionsFile is like Renditions but reads the image from inputPath and
+// writes each rendition to its Path
+func (c *Client) RenditionsFile(ctx context.Context, inputPath string, specs []RenditionSpec, opts ...Option) ([]RenditionResult, error) {
+	if inputPath == "" {
+		return nil, fmt.Errorf("%w: input path is empty", ErrInvalidInput)
+	}
+
+	if err := validateRenditions(specs, true); err != nil {
+		return nil, err
+	}
+
+	// Without a format the output extension picks it
+	specs = append([]RenditionSpec(nil), specs...)
+	for i := range specs {
+		specs[i].Format = cmp.Or(specs[i].Format, formatFromPath(specs[i].Path))
+	}
+
+	return c.renditions(ctx, specs, func(mw *imagick.MagickWand, s *settings) error {
+		return readImageFile(mw, inputPath, s)
+	}, func(mw *imagick.MagickWand, spec RenditionSpec, s *settings) (int64, error) {
+		s.logger.Info("WriteImage", "Out", spec.Path)
+		if err := writeImageFile(mw, spec.Path); err != nil {
+			return 0, err
+		}
+
+		info, err := os.Stat(spec.Path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat output: %w", err)
+		}
+		return info.Size(), nil
+	}, opts)
+}
+
+// renditions decodes the master with read and produces each rendition from
+// a clone of it, writing the result with write
+func (c *Client) renditions(ctx context.Context, specs []RenditionSpec,
+	read func(mw *imagick.MagickWand, s *settings) error,
+	write func(mw *imagick.MagickWand, spec RenditionSpec, s *settings) (int64, error),
+	opts []Option) ([]RenditionResult, error) {
+
+	s, err := c.settingsFor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := c.pool.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	master := imagick.NewMagickWand()
+	defer func() { master.Destroy() }()
+
+	if err := read(master, &s); err != nil {
+		return nil, err
+	}
+	if master, err = coalesceFrames(master); err != nil {
+		return nil, err
+	}
+
+	// Auto-orient the master once for every rendition
+	forEachFrame(master, func() error {
+		if err := master.AutoOrientImage(); err != nil {
+			s.logger.Error("Auto-orientation failed", "error", err)
+			// Continue despite error
+		}
+		return nil
+	})
+
+	results := make([]RenditionResult, len(specs))
+	for i, spec := range specs {
+		results[i].Name = spec.Name
+
+		if err := checkContext(ctx); err != nil {
+			return results, err
+		}
+
+		results[i] = rendition(master, spec, s, write)
+		if results[i].Err != nil {
+			s.logger.Error("Rendition failed", "error", results[i].Err, "rendition", spec.Name)
+		}
+	}
+
+	return results, nil
+}
+
+// rendition produces a single rendition from a clone of master
+func rendition(master *imagick.MagickWand, spec RenditionSpec, s settings,
+	write func(mw *imagick.MagickWand, spec RenditionSpec, s *settings) (int64, error)) RenditionResult {
+
+	result := RenditionResult{Name: spec.Name}
+
+	mw := master.Clone()
+	defer func() { mw.Destroy() }()
+
+	if spec.Quality != 0 {
+		// The rendition's quality also wins over per-format encoder options
+		s.quality = spec.Quality
+		s.jpeg.Quality, s.webp.Quality, s.avif.Quality = spec.Quality, spec.Quality, spec.Quality
+	}
+
+	err := forEachFrame(mw, func() error { return resizeImage(mw, spec.resizeSpec(), &s) })
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if err := prepareEncode(mw, spec.Format, &s); err != nil {
+		result.Err = err
+		return result
+	}
+
+	if mw, err = optimizeFrames(mw); err != nil {
+		result.Err = err
+		return result
+	}
+
+	size, err := write(mw, spec, &s)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Width = mw.GetImageWidth()
+	result.Height = mw.GetImageHeight()
+	result.Format = mw.GetImageFormat()
+	result.Size = size
+	return result
+}
@@ -0,0 +1,136 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestValidateRenditions tests that invalid rendition specs are rejected
+func TestValidateRenditions(t *testing.T) {
+	tests := []struct {
+		name     string
+		specs    []RenditionSpec
+		needPath bool
+	}{
+		{"no renditions", nil, false},
+		{"missing name", []RenditionSpec{{Width: 100}}, false},
+		{"duplicate name", []RenditionSpec{{Name: "a", Width: 100}, {Name: "a", Width: 200}}, false},
+		{"missing path", []RenditionSpec{{Name: "a", Width: 100}}, true},
+		{"quality out of range", []RenditionSpec{{Name: "a", Width: 100, Quality: 101}}, false},
+		{"missing size", []RenditionSpec{{Name: "a"}}, false},
+		{"fill needs both sides", []RenditionSpec{{Name: "a", Width: 100, Mode: ResizeFill}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRenditions(tt.specs, tt.needPath); !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("expected ErrInvalidInput, got %v", err)
+			}
+		})
+	}
+
+	valid := []RenditionSpec{{Name: "a", Width: 100, Path: "a.png"}, {Name: "b", Height: 50, Path: "b.png"}}
+	if err := validateRenditions(valid, true); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestRenditions tests producing several renditions from one decode
+func TestRenditions(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	specs := []RenditionSpec{
+		{Name: "w100", Width: 100, Format: "jpeg", Quality: 70},
+		{Name: "square", Width: 50, Height: 50, Mode: ResizeFill, Format: "png"},
+		{Name: "broken", Width: 10, Format: "not-a-format"},
+	}
+
+	outputs := map[string]*bytes.Buffer{}
+	results, err := client.Renditions(context.Background(), bytes.NewReader(testPNG(t, 400, 200)), specs,
+		func(spec RenditionSpec) (io.Writer, error) {
+			outputs[spec.Name] = &bytes.Buffer{}
+			return outputs[spec.Name], nil
+		})
+	if err != nil {
+		t.Fatalf("Renditions failed: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if r := results[0]; r.Err != nil || r.Width != 100 || r.Height != 50 || r.Size != int64(outputs["w100"].Len()) {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if r := results[1]; r.Err != nil || r.Width != 50 || r.Height != 50 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if format, _, _ := decodeConfig(t, outputs["square"].Bytes()); format != "png" {
+		t.Errorf("expected png, got %s", format)
+	}
+	if results[2].Err == nil {
+		t.Error("expected the broken rendition to fail")
+	}
+
+	// File variant
+	tempDir := t.TempDir()
+	inputPath := filepath.Join(tempDir, "input.png")
+	if err := os.WriteFile(inputPath, testPNG(t, 400, 200), 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	results, err = client.RenditionsFile(context.Background(), inputPath, []RenditionSpec{
+		{Name: "small", Height: 20, Path: filepath.Join(tempDir, "small.png")},
+	})
+	if err != nil {
+		t.Fatalf("RenditionsFile failed: %v", err)
+	}
+	if r := results[0]; r.Err != nil || r.Width != 40 || r.Size == 0 {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	_, err = client.RenditionsFile(context.Background(), "nonexistent.png", []RenditionSpec{{Name: "a", Width: 10, Path: "a.png"}})
+	if !errors.Is(err, ErrProcessing) {
+		t.Errorf("expected ErrProcessing with non-existent input, got %v", err)
+	}
+}
+
+// TestRenditionQuality tests that the quality of a rendition wins over the
+// per-format encoder options of the client
+func TestRenditionQuality(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New(WithJPEGOptions(JPEGOptions{Quality: 95}))
+	defer client.Close()
+
+	specs := []RenditionSpec{
+		{Name: "client", Width: 300, Format: "jpeg"},
+		{Name: "low", Width: 300, Format: "jpeg", Quality: 20},
+	}
+	results, err := client.Renditions(context.Background(), bytes.NewReader(testPNG(t, 400, 200)), specs,
+		func(spec RenditionSpec) (io.Writer, error) { return io.Discard, nil })
+	if err != nil {
+		t.Fatalf("Renditions failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("rendition %s failed: %v", r.Name, r.Err)
+		}
+	}
+	if results[1].Size >= results[0].Size {
+		t.Errorf("expected quality 20 to encode smaller than 95, got %d and %d bytes", results[1].Size, results[0].Size)
+	}
+}
@@ -0,0 +1,258 @@
+package mwclient
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// ResizeMode controls how an image is fitted to the requested dimensions
+type ResizeMode int
+
+const (
+	// ResizeFit scales the image to fit inside the box, keeping the aspect
+	// ratio. The result may be smaller than the box on one axis.
+	ResizeFit ResizeMode = iota
+	// ResizeFill scales the image to cover the box, keeping the aspect
+	// ratio, and crops the overflow ("cover").
+	ResizeFill
+	// ResizePad scales the image to fit inside the box and pads it to the
+	// exact box size with the background color ("letterbox").
+	ResizePad
+	// ResizeStretch scales the image to exactly the box, ignoring the
+	// aspect ratio.
+	ResizeStretch
+)
+
+// String returns the name of the mode as accepted by ParseResizeMode
+func (m ResizeMode) String() string {
+	switch m {
+	case ResizeFit:
+		return "fit"
+	case ResizeFill:
+		return "fill"
+	case ResizePad:
+		return "pad"
+	case ResizeStretch:
+		return "stretch"
+	default:
+		return fmt.Sprintf("ResizeMode(%d)", int(m))
+	}
+}
+
+// ParseResizeMode parses a mode name. "cover" is accepted as an alias of
+// "fill" and the empty string as "fit".
+func ParseResizeMode(name string) (ResizeMode, error) {
+	switch strings.ToLower(name) {
+	case "", "fit":
+		return ResizeFit, nil
+	case "fill", "cover":
+		return ResizeFill, nil
+	case "pad":
+		return ResizePad, nil
+	case "stretch":
+		return ResizeStretch, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown resize mode %q", ErrInvalidInput, name)
+	}
+}
+
+// ResizeSpec describes a resize to a target box
+type ResizeSpec struct {
+	Width  uint
+	Height uint
+	Mode   ResizeMode
+
+	// OnlyShrink never scales the image up. Images smaller than the box are
+	// kept at their size, and still cropped to the box's aspect ratio or
+	// padded as the mode requires.
+	OnlyShrink bool
+
+	// Background is the padding color for ResizePad. Empty uses the
+	// client's background color.
+	Background string
+
+	// Gravity and Focal choose which part of the image survives the crop of
+	// ResizeFill, as for CropSpec. The default keeps the center.
+	Gravity Gravity
+	Focal   *FocalPoint
+}
+
+// validate checks that the spec describes a usable box
+func (spec ResizeSpec) validate() error {
+	if err := spec.check(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return nil
+}
+
+// check is like validate but returns an error without the sentinel
+func (spec ResizeSpec) check() error {
+	switch spec.Mode {
+	case ResizeFit:
+		if spec.Width == 0 && spec.Height == 0 {
+			return errors.New("width or height is required")
+		}
+	case ResizeFill, ResizePad, ResizeStretch:
+		if spec.Width == 0 || spec.Height == 0 {
+			return fmt.Errorf("width and height are required for %s", spec.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown resize mode %v", spec.Mode)
+	}
+	return checkPlacement(spec.Gravity, spec.Focal)
+}
+
+// resizePlan is the geometry of a resize: scale to width x height, then
+// optionally crop a region of it, then optionally pad it onto a canvas
+type resizePlan struct {
+	width, height uint
+
+	cropWidth, cropHeight uint // zero when no crop is needed
+	cropX, cropY          int
+
+	canvasWidth, canvasHeight uint // zero when no padding is needed
+	padX, padY                int  // position of the image on the canvas
+}
+
+// planResize calculates the geometry for resizing srcWidth x srcHeight
+// according to spec. Crops are placed by the spec's gravity or focal point;
+// padding is centered.
+func planResize(srcWidth, srcHeight uint, spec ResizeSpec) resizePlan {
+	var plan resizePlan
+	if srcWidth == 0 || srcHeight == 0 {
+		return plan
+	}
+
+	switch spec.Mode {
+	case ResizeFit, ResizePad:
+		plan.width, plan.height = fitDimensions(srcWidth, srcHeight, spec.Width, spec.Height)
+		if spec.OnlyShrink && plan.width > srcWidth {
+			plan.width, plan.height = srcWidth, srcHeight
+		}
+
+		if spec.Mode == ResizePad && (plan.width != spec.Width || plan.height != spec.Height) {
+			plan.canvasWidth, plan.canvasHeight = spec.Width, spec.Height
+			plan.padX = (int(spec.Width) - int(plan.width)) / 2
+			plan.padY = (int(spec.Height) - int(plan.height)) / 2
+		}
+
+	case ResizeFill:
+		plan.width, plan.height = coverDimensions(srcWidth, srcHeight, spec.Width, spec.Height)
+		cropWidth, cropHeight := spec.Width, spec.Height
+		if spec.OnlyShrink && plan.width > srcWidth {
+			// Keep the source size and crop the largest region with the
+			// box's aspect ratio instead
+			plan.width, plan.height = srcWidth, srcHeight
+			cropWidth, cropHeight = fitDimensions(spec.Width, spec.Height, srcWidth, srcHeight)
+		}
+
+		if cropWidth != plan.width || cropHeight != plan.height {
+			plan.cropWidth, plan.cropHeight = cropWidth, cropHeight
+			plan.cropX, plan.cropY = cropOrigin(plan.width, plan.height, cropWidth, cropHeight, spec.Gravity, spec.Focal)
+		}
+
+	case ResizeStretch:
+		plan.width, plan.height = spec.Width, spec.Height
+		if spec.OnlyShrink {
+			plan.width, plan.height = min(plan.width, srcWidth), min(plan.height, srcHeight)
+		}
+	}
+
+	return plan
+}
+
+// coverDimensions scales srcWidth x srcHeight to the smallest size that
+// covers boxWidth x boxHeight, keeping the aspect ratio
+func coverDimensions(srcWidth, srcHeight, boxWidth, boxHeight uint) (uint, uint) {
+	// Compare boxWidth/srcWidth with boxHeight/srcHeight without floats
+	if uint64(boxWidth)*uint64(srcHeight) >= uint64(boxHeight)*uint64(srcWidth) {
+		h := (uint64(srcHeight)*uint64(boxWidth) + uint64(srcWidth) - 1) / uint64(srcWidth)
+		return boxWidth, max(boxHeight, uint(h))
+	}
+	w := (uint64(srcWidth)*uint64(boxHeight) + uint64(srcHeight) - 1) / uint64(srcHeight)
+	return max(boxWidth, uint(w)), boxHeight
+}
+
+// ResizeTo resizes the image according to spec
+func (p *Pipeline) ResizeTo(spec ResizeSpec) *Pipeline {
+	return p.add("Resize", func(mw *imagick.MagickWand, s *settings) error {
+		return resizeImage(mw, spec, s)
+	})
+}
+
+// resizeImage resizes, crops and pads mw according to spec
+func resizeImage(mw *imagick.MagickWand, spec ResizeSpec, s *settings) error {
+	if err := spec.validate(); err != nil {
+		return err
+	}
+
+	srcWidth, srcHeight := mw.GetImageWidth(), mw.GetImageHeight()
+	plan := planResize(srcWidth, srcHeight, spec)
+	if plan.width == 0 || plan.height == 0 {
+		return fmt.Errorf("%w: image has no dimensions", ErrProcessing)
+	}
+
+	if plan.width != srcWidth || plan.height != srcHeight {
+		if err := mw.ResizeImage(plan.width, plan.height, s.filter); err != nil {
+			return fmt.Errorf("%w: failed to resize image: %v", ErrProcessing, err)
+		}
+	}
+
+	if plan.cropWidth != 0 {
+		if err := mw.CropImage(plan.cropWidth, plan.cropHeight, plan.cropX, plan.cropY); err != nil {
+			return fmt.Errorf("%w: failed to crop image: %v", ErrProcessing, err)
+		}
+		if err := mw.ResetImagePage(""); err != nil {
+			return fmt.Errorf("%w: failed to reset image page: %v", ErrProcessing, err)
+		}
+	}
+
+	if plan.canvasWidth != 0 {
+		bg := imagick.NewPixelWand()
+		defer bg.Destroy()
+		bg.SetColor(cmp.Or(spec.Background, s.background))
+
+		if err := mw.SetImageBackgroundColor(bg); err != nil {
+			return fmt.Errorf("%w: failed to set background color: %v", ErrProcessing, err)
+		}
+		// A negative offset places the image inside the larger canvas
+		if err := mw.ExtentImage(plan.canvasWidth, plan.canvasHeight, -plan.padX, -plan.padY); err != nil {
+			return fmt.Errorf("%w: failed to pad image: %v", ErrProcessing, err)
+		}
+	}
+
+	return nil
+}
+
+// Resize resizes an image from a reader according to spec and writes the
+// result to the provided writer. An empty format keeps the input format.
+func (c *Client) Resize(ctx context.Context, r io.Reader, w io.Writer, spec ResizeSpec, format string, opts ...Option) error {
+	if r == nil || w == nil {
+		return fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
+	}
+
+	if err := spec.validate(); err != nil {
+		return err
+	}
+
+	return c.Pipeline(opts...).AutoOrient().ResizeTo(spec).Encode(format).Run(ctx, r, w)
+}
+
+// ResizeFile is like Resize but reads from inputPath and writes to outputPath
+func (c *Client) ResizeFile(ctx context.Context, inputPath, outputPath string, spec ResizeSpec, format string, opts ...Option) error {
+	if inputPath == "" || outputPath == "" {
+		return fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
+	}
+
+	if err := spec.validate(); err != nil {
+		return err
+	}
+
+	return c.Pipeline(opts...).AutoOrient().ResizeTo(spec).Encode(format).RunFile(ctx, inputPath, outputPath)
+}
@@ -0,0 +1,181 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestParseResizeMode tests parsing mode names
+func TestParseResizeMode(t *testing.T) {
+	tests := map[string]ResizeMode{
+		"":        ResizeFit,
+		"fit":     ResizeFit,
+		"FILL":    ResizeFill,
+		"cover":   ResizeFill,
+		"pad":     ResizePad,
+		"stretch": ResizeStretch,
+	}
+	for name, want := range tests {
+		got, err := ParseResizeMode(name)
+		if err != nil || got != want {
+			t.Errorf("ParseResizeMode(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseResizeMode("zoom"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for unknown mode, got %v", err)
+	}
+}
+
+// TestPlanResize tests the resize geometry of every mode
+func TestPlanResize(t *testing.T) {
+	tests := []struct {
+		name       string
+		srcW, srcH uint
+		spec       ResizeSpec
+		want       resizePlan
+	}{
+		{
+			name: "fit landscape",
+			srcW: 400, srcH: 200,
+			spec: ResizeSpec{Width: 100, Height: 100, Mode: ResizeFit},
+			want: resizePlan{width: 100, height: 50},
+		},
+		{
+			name: "fit upscales by default",
+			srcW: 40, srcH: 20,
+			spec: ResizeSpec{Width: 100, Height: 100, Mode: ResizeFit},
+			want: resizePlan{width: 100, height: 50},
+		},
+		{
+			name: "fit only shrink",
+			srcW: 40, srcH: 20,
+			spec: ResizeSpec{Width: 100, Height: 100, Mode: ResizeFit, OnlyShrink: true},
+			want: resizePlan{width: 40, height: 20},
+		},
+		{
+			name: "fill crops overflow",
+			srcW: 400, srcH: 200,
+			spec: ResizeSpec{Width: 100, Height: 100, Mode: ResizeFill},
+			want: resizePlan{width: 200, height: 100, cropWidth: 100, cropHeight: 100, cropX: 50},
+		},
+		{
+			name: "fill portrait",
+			srcW: 200, srcH: 400,
+			spec: ResizeSpec{Width: 100, Height: 50, Mode: ResizeFill},
+			want: resizePlan{width: 100, height: 200, cropWidth: 100, cropHeight: 50, cropY: 75},
+		},
+		{
+			name: "fill same aspect needs no crop",
+			srcW: 400, srcH: 200,
+			spec: ResizeSpec{Width: 200, Height: 100, Mode: ResizeFill},
+			want: resizePlan{width: 200, height: 100},
+		},
+		{
+			name: "fill only shrink crops at source size",
+			srcW: 80, srcH: 40,
+			spec: ResizeSpec{Width: 100, Height: 100, Mode: ResizeFill, OnlyShrink: true},
+			want: resizePlan{width: 80, height: 40, cropWidth: 40, cropHeight: 40, cropX: 20},
+		},
+		{
+			name: "pad letterbox",
+			srcW: 400, srcH: 200,
+			spec: ResizeSpec{Width: 100, Height: 100, Mode: ResizePad},
+			want: resizePlan{width: 100, height: 50, canvasWidth: 100, canvasHeight: 100, padY: 25},
+		},
+		{
+			name: "pad only shrink centers small image",
+			srcW: 40, srcH: 20,
+			spec: ResizeSpec{Width: 100, Height: 100, Mode: ResizePad, OnlyShrink: true},
+			want: resizePlan{width: 40, height: 20, canvasWidth: 100, canvasHeight: 100, padX: 30, padY: 40},
+		},
+		{
+			name: "stretch",
+			srcW: 400, srcH: 200,
+			spec: ResizeSpec{Width: 100, Height: 100, Mode: ResizeStretch},
+			want: resizePlan{width: 100, height: 100},
+		},
+		{
+			name: "stretch only shrink",
+			srcW: 400, srcH: 50,
+			spec: ResizeSpec{Width: 100, Height: 100, Mode: ResizeStretch, OnlyShrink: true},
+			want: resizePlan{width: 100, height: 50},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planResize(tt.srcW, tt.srcH, tt.spec)
+			if got != tt.want {
+				t.Errorf("planResize = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestResizeSpecValidation tests that incomplete specs are rejected
+func TestResizeSpecValidation(t *testing.T) {
+	tests := []ResizeSpec{
+		{Mode: ResizeFit},
+		{Width: 100, Mode: ResizeFill},
+		{Height: 100, Mode: ResizePad},
+		{Width: 100, Mode: ResizeStretch},
+		{Width: 100, Height: 100, Mode: ResizeMode(42)},
+	}
+	for _, spec := range tests {
+		if err := spec.validate(); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput for %+v, got %v", spec, err)
+		}
+	}
+}
+
+// TestResize tests resizing with each mode
+func TestResize(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	src := testPNG(t, 400, 200)
+
+	tests := []struct {
+		spec          ResizeSpec
+		width, height int
+	}{
+		{ResizeSpec{Width: 100, Height: 100, Mode: ResizeFit}, 100, 50},
+		{ResizeSpec{Width: 100, Height: 100, Mode: ResizeFill}, 100, 100},
+		{ResizeSpec{Width: 100, Height: 100, Mode: ResizePad, Background: "black"}, 100, 100},
+		{ResizeSpec{Width: 100, Height: 100, Mode: ResizeStretch}, 100, 100},
+		{ResizeSpec{Width: 800, Height: 800, Mode: ResizeFit, OnlyShrink: true}, 400, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec.Mode.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := client.Resize(context.Background(), bytes.NewReader(src), &buf, tt.spec, "png")
+			if err != nil {
+				t.Fatalf("Resize failed: %v", err)
+			}
+
+			if _, width, height := decodeConfig(t, buf.Bytes()); width != tt.width || height != tt.height {
+				t.Errorf("expected %dx%d, got %dx%d", tt.width, tt.height, width, height)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	err := client.Resize(context.Background(), bytes.NewReader(src), &buf, ResizeSpec{Mode: ResizeFill}, "png")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with empty box, got %v", err)
+	}
+
+	err = client.ResizeFile(context.Background(), "", "output.png", ResizeSpec{Width: 10}, "")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with empty path, got %v", err)
+	}
+}
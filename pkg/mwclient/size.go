@@ -0,0 +1,275 @@
+package mwclient
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Size target defaults
+const (
+	DefaultMinQuality   = 30
+	DefaultMinDimension = 64
+
+	// maxDownscaleSteps bounds the number of downscale rounds
+	maxDownscaleSteps = 8
+)
+
+// SizeTarget is a maximum encoded size, reached by lowering the quality and,
+// as a last resort, the dimensions
+type SizeTarget struct {
+	MaxBytes int64
+
+	// Quality range searched. Zero values use DefaultMinQuality and the
+	// client quality.
+	MinQuality uint
+	MaxQuality uint
+
+	// Downscale shrinks the image when even MinQuality is too large, but
+	// never below MinDimension (DefaultMinDimension if zero) on either side
+	Downscale    bool
+	MinDimension uint
+}
+
+// SizeResult reports how a SizeTarget was met
+type SizeResult struct {
+	Quality  uint
+	Size     int64
+	Width    uint
+	Height   uint
+	Attempts int // number of trial encodes
+}
+
+// validate checks the target for out-of-range values
+func (t SizeTarget) validate() error {
+	if t.MaxBytes <= 0 {
+		return fmt.Errorf("%w: maximum size must be positive", ErrInvalidInput)
+	}
+	if t.MinQuality > 100 || t.MaxQuality > 100 {
+		return fmt.Errorf("%w: quality must be between 1 and 100", ErrInvalidInput)
+	}
+	if t.MaxQuality != 0 && t.MinQuality > t.MaxQuality {
+		return fmt.Errorf("%w: minimum quality exceeds maximum quality", ErrInvalidInput)
+	}
+	return nil
+}
+
+// EncodeToSize encodes the image in format, or the input format if empty,
+// at the highest quality whose output fits target. The quality and size
+// found are stored in result, which may be nil. Only lossy formats (JPEG,
+// WebP, AVIF) can be tuned. The image is encoded once more on write.
+func (p *Pipeline) EncodeToSize(format string, target SizeTarget, result *SizeResult) *Pipeline {
+	p.format = format
+	p.tune = func(ctx context.Context, mw *imagick.MagickWand, s *settings) error {
+		res, err := fitToSize(ctx, mw, target, s)
+		if result != nil {
+			*result = res
+		}
+		return err
+	}
+	return p
+}
+
+// EncodeToSize re-encodes an image from a reader to fit target and writes
+// the result to the provided writer. An empty format keeps the input format.
+func (c *Client) EncodeToSize(ctx context.Context, r io.Reader, w io.Writer, format string, target SizeTarget, opts ...Option) (SizeResult, error) {
+	var result SizeResult
+
+	if r == nil || w == nil {
+		return result, fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
+	}
+
+	if err := target.validate(); err != nil {
+		return result, err
+	}
+
+	err := c.Pipeline(opts...).AutoOrient().EncodeToSize(format, target, &result).Run(ctx, r, w)
+	return result, err
+}
+
+// EncodeToSizeFile is like EncodeToSize but reads from inputPath and writes
+// to outputPath. An empty format uses the output file's extension.
+func (c *Client) EncodeToSizeFile(ctx context.Context, inputPath, outputPath, format string, target SizeTarget, opts ...Option) (SizeResult, error) {
+	var result SizeResult
+
+	if inputPath == "" || outputPath == "" {
+		return result, fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
+	}
+
+	if err := target.validate(); err != nil {
+		return result, err
+	}
+
+	format = cmp.Or(format, formatFromPath(outputPath))
+	err := c.Pipeline(opts...).AutoOrient().EncodeToSize(format, target, &result).RunFile(ctx, inputPath, outputPath)
+	return result, err
+}
+
+// isLossy reports whether format has a quality setting worth searching
+func isLossy(format string) bool {
+	switch strings.ToUpper(format) {
+	case "JPEG", "JPG", "WEBP", "AVIF":
+		return true
+	}
+	return false
+}
+
+// fitToSize searches for the highest quality, and if allowed the largest
+// size, at which mw encodes within target, and leaves mw set up for it
+func fitToSize(ctx context.Context, mw *imagick.MagickWand, target SizeTarget, s *settings) (SizeResult, error) {
+	var result SizeResult
+
+	if err := target.validate(); err != nil {
+		return result, err
+	}
+	if format := mw.GetImageFormat(); !isLossy(format) {
+		return result, fmt.Errorf("%w: size targets need JPEG, WebP or AVIF output, not %s", ErrInvalidInput, format)
+	}
+
+	minQuality := cmp.Or(target.MinQuality, DefaultMinQuality)
+	maxQuality := max(cmp.Or(target.MaxQuality, s.quality), minQuality)
+	minDimension := cmp.Or(target.MinDimension, DefaultMinDimension)
+
+	srcWidth, srcHeight := mw.GetImageWidth(), mw.GetImageHeight()
+	width, height := srcWidth, srcHeight
+	scale := 1.0
+
+	for step := 0; ; step++ {
+		search := searchSize(ctx, mw, width, height, minQuality, maxQuality, target.MaxBytes, s)
+		result.Attempts += search.attempts
+		if search.err != nil {
+			return result, search.err
+		}
+
+		if search.fits {
+			result.Quality, result.Size = search.quality, search.size
+			result.Width, result.Height = width, height
+			s.logger.Debug("Size target met", "Quality", result.Quality, "Size", result.Size, "Width", width, "Height", height)
+			return result, applySize(mw, result, srcWidth, srcHeight, s)
+		}
+
+		if !target.Downscale || step == maxDownscaleSteps {
+			break
+		}
+
+		scale = nextScale(scale, search.size, target.MaxBytes)
+		width = uint(math.Round(float64(srcWidth) * scale))
+		height = uint(math.Round(float64(srcHeight) * scale))
+		if width < minDimension || height < minDimension {
+			break
+		}
+	}
+
+	return result, fmt.Errorf("%w: no encoding fits in %d bytes", ErrSizeUnreachable, target.MaxBytes)
+}
+
+// searchSize runs searchQuality on mw resized to width x height. The
+// original is left untouched; downscaled trials run on a copy.
+func searchSize(ctx context.Context, mw *imagick.MagickWand, width, height, lo, hi uint, maxBytes int64, s *settings) qualitySearch {
+	trial := mw
+	if width != mw.GetImageWidth() || height != mw.GetImageHeight() {
+		trial = mw.Clone()
+		defer trial.Destroy()
+		if err := trial.ResizeImage(width, height, s.filter); err != nil {
+			return qualitySearch{err: fmt.Errorf("%w: failed to downscale image: %v", ErrProcessing, err)}
+		}
+	}
+
+	return searchQuality(lo, hi, maxBytes, func(quality uint) (int64, error) {
+		if err := checkContext(ctx); err != nil {
+			return 0, err
+		}
+		return encodedSize(trial, quality)
+	})
+}
+
+// applySize sets mw to the quality and dimensions in result
+func applySize(mw *imagick.MagickWand, result SizeResult, srcWidth, srcHeight uint, s *settings) error {
+	if result.Width != srcWidth || result.Height != srcHeight {
+		if err := mw.ResizeImage(result.Width, result.Height, s.filter); err != nil {
+			return fmt.Errorf("%w: failed to downscale image: %v", ErrProcessing, err)
+		}
+	}
+	if err := mw.SetImageCompressionQuality(result.Quality); err != nil {
+		return fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
+	}
+	return nil
+}
+
+// encodedSize returns the size of mw encoded at quality
+func encodedSize(mw *imagick.MagickWand, quality uint) (int64, error) {
+	if err := mw.SetImageCompressionQuality(quality); err != nil {
+		return 0, fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
+	}
+	blob, err := mw.GetImageBlob()
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to get image blob: %v", ErrProcessing, err)
+	}
+	return int64(len(blob)), nil
+}
+
+// qualitySearch is the outcome of searchQuality. If nothing fits, size is
+// the size at the lowest quality.
+type qualitySearch struct {
+	fits     bool
+	quality  uint
+	size     int64
+	attempts int
+	err      error
+}
+
+// searchQuality binary-searches [lo, hi] for the highest quality whose
+// encoded size is at most maxBytes. Size is assumed to grow with quality.
+func searchQuality(lo, hi uint, maxBytes int64, encode func(quality uint) (int64, error)) qualitySearch {
+	var result qualitySearch
+
+	// Most images fit at the top quality; try it first
+	size, err := encode(hi)
+	result.attempts++
+	if err != nil {
+		result.err = err
+		return result
+	}
+	if size <= maxBytes {
+		result.fits, result.quality, result.size = true, hi, size
+		return result
+	}
+	result.size = size
+
+	low, high := int(lo), int(hi)-1
+	for low <= high {
+		mid := low + (high-low)/2
+		size, err := encode(uint(mid))
+		result.attempts++
+		if err != nil {
+			result.err = err
+			return result
+		}
+
+		if size <= maxBytes {
+			result.fits, result.quality, result.size = true, uint(mid), size
+			low = mid + 1
+		} else {
+			if !result.fits {
+				result.size = size
+			}
+			high = mid - 1
+		}
+	}
+
+	return result
+}
+
+// nextScale returns the next downscale factor, given the smallest size
+// reached at the current one. Encoded size is roughly proportional to the
+// pixel count, so the side shrinks by the square root of the overshoot,
+// with a margin and at least 10% per round.
+func nextScale(scale float64, size, maxBytes int64) float64 {
+	factor := math.Sqrt(float64(maxBytes)/float64(size)) * 0.95
+	return scale * min(factor, 0.9)
+}
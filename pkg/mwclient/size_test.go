@@ -0,0 +1,175 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSearchQuality tests the quality binary search against a synthetic
+// encoder whose size grows linearly with quality
+func TestSearchQuality(t *testing.T) {
+	linear := func(quality uint) (int64, error) { return int64(quality) * 1000, nil }
+
+	tests := []struct {
+		name        string
+		maxBytes    int64
+		wantFits    bool
+		wantQuality uint
+		wantSize    int64
+		maxAttempts int
+	}{
+		{"top quality fits", 100000, true, 90, 90000, 1},
+		{"middle", 55500, true, 55, 55000, 7},
+		{"lowest quality fits", 30000, true, 30, 30000, 7},
+		{"nothing fits", 29999, false, 0, 30000, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := searchQuality(30, 90, tt.maxBytes, linear)
+			if got.err != nil {
+				t.Fatalf("unexpected error: %v", got.err)
+			}
+			if got.fits != tt.wantFits || got.quality != tt.wantQuality || got.size != tt.wantSize {
+				t.Errorf("expected fits=%v quality=%d size=%d, got fits=%v quality=%d size=%d",
+					tt.wantFits, tt.wantQuality, tt.wantSize, got.fits, got.quality, got.size)
+			}
+			if got.attempts > tt.maxAttempts {
+				t.Errorf("expected at most %d attempts, got %d", tt.maxAttempts, got.attempts)
+			}
+		})
+	}
+
+	failing := func(quality uint) (int64, error) {
+		if quality < 90 {
+			return 0, ErrProcessing
+		}
+		return 1 << 20, nil
+	}
+	if got := searchQuality(30, 90, 1000, failing); !errors.Is(got.err, ErrProcessing) {
+		t.Errorf("expected the encoder error, got %v", got.err)
+	}
+}
+
+// TestNextScale tests the downscale factor estimate
+func TestNextScale(t *testing.T) {
+	// 4x too large: halve the sides, with a margin
+	if got := nextScale(1, 400, 100); got < 0.47 || got > 0.48 {
+		t.Errorf("expected about 0.475, got %f", got)
+	}
+	// Barely too large: still shrink by at least 10%
+	if got := nextScale(0.5, 101, 100); got != 0.45 {
+		t.Errorf("expected 0.45, got %f", got)
+	}
+}
+
+// TestSizeTargetValidate tests size target validation
+func TestSizeTargetValidate(t *testing.T) {
+	invalid := []SizeTarget{
+		{},
+		{MaxBytes: -1},
+		{MaxBytes: 1000, MinQuality: 101},
+		{MaxBytes: 1000, MinQuality: 80, MaxQuality: 60},
+	}
+	for _, target := range invalid {
+		if err := target.validate(); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput for %+v, got %v", target, err)
+		}
+	}
+
+	if err := (SizeTarget{MaxBytes: 200 << 10, MinQuality: 40}).validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestIsLossy tests recognizing formats with a quality setting, as
+// ImageMagick or output extensions spell them
+func TestIsLossy(t *testing.T) {
+	for _, format := range []string{"JPEG", "JPG", "jpg", "jpeg", "WEBP", "webp", "AVIF"} {
+		if !isLossy(format) {
+			t.Errorf("%s: expected a lossy format", format)
+		}
+	}
+	for _, format := range []string{"PNG", "gif", "TIFF", ""} {
+		if isLossy(format) {
+			t.Errorf("%s: expected a lossless format", format)
+		}
+	}
+}
+
+// TestEncodeToSize tests meeting a size target by quality and by downscaling
+func TestEncodeToSize(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	src := testPNG(t, 400, 300)
+
+	var full bytes.Buffer
+	if err := client.ConvertFormatCtx(ctx, bytes.NewReader(src), &full, "jpeg"); err != nil {
+		t.Fatalf("ConvertFormat failed: %v", err)
+	}
+
+	// Reachable by quality alone
+	target := SizeTarget{MaxBytes: int64(full.Len()) * 6 / 10, MinQuality: 5}
+	var buf bytes.Buffer
+	result, err := client.EncodeToSize(ctx, bytes.NewReader(src), &buf, "jpeg", target)
+	if err != nil {
+		t.Fatalf("EncodeToSize failed: %v", err)
+	}
+	if result.Size > target.MaxBytes || int64(buf.Len()) != result.Size {
+		t.Errorf("expected at most %d bytes, got result %d, output %d", target.MaxBytes, result.Size, buf.Len())
+	}
+	if result.Quality >= DefaultQuality || result.Width != 400 || result.Height != 300 {
+		t.Errorf("expected a lower quality at full size, got %+v", result)
+	}
+
+	// Only reachable by downscaling
+	target = SizeTarget{MaxBytes: 1500}
+	_, err = client.EncodeToSize(ctx, bytes.NewReader(src), &buf, "jpeg", target)
+	if !errors.Is(err, ErrSizeUnreachable) {
+		t.Errorf("expected ErrSizeUnreachable without downscaling, got %v", err)
+	}
+
+	target.Downscale = true
+	buf.Reset()
+	result, err = client.EncodeToSize(ctx, bytes.NewReader(src), &buf, "jpeg", target)
+	if err != nil {
+		t.Fatalf("EncodeToSize with downscaling failed: %v", err)
+	}
+	if _, w, h := decodeConfig(t, buf.Bytes()); w >= 400 || uint(w) != result.Width || uint(h) != result.Height {
+		t.Errorf("expected a downscaled %dx%d output, got %dx%d", result.Width, result.Height, w, h)
+	}
+	if int64(buf.Len()) > target.MaxBytes {
+		t.Errorf("expected at most %d bytes, got %d", target.MaxBytes, buf.Len())
+	}
+
+	// The "jpg" spelling, also implied by a .jpg output path, is JPEG
+	buf.Reset()
+	if _, err := client.EncodeToSize(ctx, bytes.NewReader(src), &buf, "jpg", target); err != nil {
+		t.Errorf("EncodeToSize to jpg failed: %v", err)
+	}
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in.png")
+	if err := os.WriteFile(inPath, src, 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	if _, err := client.EncodeToSizeFile(ctx, inPath, filepath.Join(dir, "out.jpg"), "", target); err != nil {
+		t.Errorf("EncodeToSizeFile to out.jpg failed: %v", err)
+	}
+
+	// Lossless formats cannot be tuned
+	_, err = client.EncodeToSize(ctx, bytes.NewReader(src), &buf, "png", target)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for PNG output, got %v", err)
+	}
+}
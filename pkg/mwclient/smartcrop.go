@@ -0,0 +1,363 @@
+package mwclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// Smart crop tuning
+const (
+	smartCropAnalysisSize = 256 // long side of the downscaled analysis copy
+	smartCropPositions    = 9   // candidate windows along the free axis
+
+	smartCropEntropyWeight = 0.4
+	smartCropEdgeWeight    = 0.4
+	smartCropSkinWeight    = 0.2
+)
+
+// Rect is a rectangular region of an image
+type Rect struct {
+	X      int
+	Y      int
+	Width  uint
+	Height uint
+}
+
+// SmartCropScore is the score of one candidate window. Entropy and Edges
+// are relative to the best candidate (0-1); Skin is the fraction of pixels
+// with skin tones.
+type SmartCropScore struct {
+	Rect    Rect
+	Entropy float64
+	Edges   float64
+	Skin    float64
+	Score   float64
+}
+
+// SmartCropResult records a smart crop decision for auditing.
+// Rect and the candidate rectangles are in auto-oriented source coordinates.
+type SmartCropResult struct {
+	Rect       Rect
+	Score      float64
+	Candidates []SmartCropScore
+}
+
+// SmartCrop crops the image to the most salient region with the aspect ratio
+// of width x height and resizes it to exactly width x height. Candidate
+// windows are scored by entropy, edge density and skin tones, all computed
+// locally on a downscaled copy. If result is not nil the decision is stored
+// in it when the pipeline runs. Animations are analyzed on their first
+// frame and every frame is cropped to the same region.
+func (p *Pipeline) SmartCrop(width, height uint, result *SmartCropResult) *Pipeline {
+	return p.addWhole("SmartCrop", func(mw *imagick.MagickWand, s *settings) error {
+		if width == 0 || height == 0 {
+			return fmt.Errorf("%w: invalid dimensions", ErrInvalidInput)
+		}
+
+		mw.SetFirstIterator()
+		res, err := smartCrop(mw, width, height)
+		if err != nil {
+			return err
+		}
+		if result != nil {
+			*result = res
+		}
+
+		rect := res.Rect
+		return forEachFrame(mw, func() error {
+			if err := mw.CropImage(rect.Width, rect.Height, rect.X, rect.Y); err != nil {
+				return fmt.Errorf("%w: failed to crop image: %v", ErrProcessing, err)
+			}
+			if err := mw.ResetImagePage(""); err != nil {
+				return fmt.Errorf("%w: failed to reset image page: %v", ErrProcessing, err)
+			}
+
+			if rect.Width != width || rect.Height != height {
+				if err := mw.ResizeImage(width, height, s.filter); err != nil {
+					return fmt.Errorf("%w: failed to resize image: %v", ErrProcessing, err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// SmartCrop smart-crops an image from a reader to width x height (see
+// Pipeline.SmartCrop), writes the result to the provided writer and returns
+// the chosen region
+func (c *Client) SmartCrop(ctx context.Context, r io.Reader, w io.Writer, width, height uint, format string, opts ...Option) (SmartCropResult, error) {
+	var result SmartCropResult
+
+	if r == nil || w == nil {
+		return result, fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
+	}
+
+	if width == 0 || height == 0 {
+		return result, fmt.Errorf("%w: invalid dimensions", ErrInvalidInput)
+	}
+
+	err := c.Pipeline(opts...).AutoOrient().SmartCrop(width, height, &result).Encode(format).Run(ctx, r, w)
+	return result, err
+}
+
+// SmartCropFile is like SmartCrop but reads from inputPath and writes to outputPath
+func (c *Client) SmartCropFile(ctx context.Context, inputPath, outputPath string, width, height uint, format string, opts ...Option) (SmartCropResult, error) {
+	var result SmartCropResult
+
+	if inputPath == "" || outputPath == "" {
+		return result, fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
+	}
+
+	if width == 0 || height == 0 {
+		return result, fmt.Errorf("%w: invalid dimensions", ErrInvalidInput)
+	}
+
+	err := c.Pipeline(opts...).AutoOrient().SmartCrop(width, height, &result).Encode(format).RunFile(ctx, inputPath, outputPath)
+	return result, err
+}
+
+// smartCrop scores candidate windows with the aspect ratio of width x height
+// and returns the best one in source coordinates
+func smartCrop(mw *imagick.MagickWand, width, height uint) (SmartCropResult, error) {
+	var result SmartCropResult
+
+	srcWidth, srcHeight := mw.GetImageWidth(), mw.GetImageHeight()
+	if srcWidth == 0 || srcHeight == 0 {
+		return result, fmt.Errorf("%w: image has no dimensions", ErrProcessing)
+	}
+
+	// Analyze a small copy; saliency does not need full resolution
+	analysis := mw.Clone()
+	defer analysis.Destroy()
+
+	aWidth, aHeight := srcWidth, srcHeight
+	if max(srcWidth, srcHeight) > smartCropAnalysisSize {
+		aWidth, aHeight = fitDimensions(srcWidth, srcHeight, smartCropAnalysisSize, smartCropAnalysisSize)
+		if err := analysis.ResizeImage(aWidth, aHeight, imagick.FILTER_TRIANGLE); err != nil {
+			return result, fmt.Errorf("%w: failed to resize analysis image: %v", ErrProcessing, err)
+		}
+	}
+
+	pixels, err := analysis.ExportImagePixels(0, 0, aWidth, aHeight, "RGB", imagick.PIXEL_CHAR)
+	if err != nil {
+		return result, fmt.Errorf("%w: failed to export pixels: %v", ErrProcessing, err)
+	}
+	rgb, ok := pixels.([]byte)
+	if !ok || len(rgb) < int(aWidth*aHeight*3) {
+		return result, fmt.Errorf("%w: unexpected pixel data", ErrProcessing)
+	}
+
+	edges := analysis.Clone()
+	defer edges.Destroy()
+
+	if err := edges.TransformImageColorspace(imagick.COLORSPACE_GRAY); err != nil {
+		return result, fmt.Errorf("%w: failed to convert analysis image: %v", ErrProcessing, err)
+	}
+	if err := edges.EdgeImage(1); err != nil {
+		return result, fmt.Errorf("%w: failed to detect edges: %v", ErrProcessing, err)
+	}
+
+	pixels, err = edges.ExportImagePixels(0, 0, aWidth, aHeight, "I", imagick.PIXEL_CHAR)
+	if err != nil {
+		return result, fmt.Errorf("%w: failed to export edge pixels: %v", ErrProcessing, err)
+	}
+	gray, ok := pixels.([]byte)
+	if !ok || len(gray) < int(aWidth*aHeight) {
+		return result, fmt.Errorf("%w: unexpected edge pixel data", ErrProcessing)
+	}
+
+	winWidth, winHeight := fitDimensions(width, height, aWidth, aHeight)
+	for _, rect := range smartCropCandidates(aWidth, aHeight, winWidth, winHeight, smartCropPositions) {
+		entropy, err := regionStatistic(analysis, rect, func(w *imagick.MagickWand) (float64, error) {
+			return w.GetImageEntropy()
+		})
+		if err != nil {
+			return result, err
+		}
+
+		result.Candidates = append(result.Candidates, SmartCropScore{
+			Rect:    rect,
+			Entropy: entropy,
+			Edges:   edgeDensity(gray, aWidth, rect),
+			Skin:    skinFraction(rgb, aWidth, rect),
+		})
+	}
+
+	best := rankSmartCrop(result.Candidates)
+
+	// Map the analysis windows back to source coordinates
+	for i := range result.Candidates {
+		result.Candidates[i].Rect = scaleRect(result.Candidates[i].Rect, srcWidth, srcHeight, aWidth, aHeight, width, height)
+	}
+	result.Rect = result.Candidates[best].Rect
+	result.Score = result.Candidates[best].Score
+
+	return result, nil
+}
+
+// regionStatistic computes stat on a copy of the rect region of mw
+func regionStatistic(mw *imagick.MagickWand, rect Rect, stat func(w *imagick.MagickWand) (float64, error)) (float64, error) {
+	region := mw.Clone()
+	defer region.Destroy()
+
+	if err := region.CropImage(rect.Width, rect.Height, rect.X, rect.Y); err != nil {
+		return 0, fmt.Errorf("%w: failed to crop analysis region: %v", ErrProcessing, err)
+	}
+
+	v, err := stat(region)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to compute region statistics: %v", ErrProcessing, err)
+	}
+	return v, nil
+}
+
+// smartCropCandidates returns up to n windows of winWidth x winHeight
+// evenly spread along the free axis of a width x height image, ordered by
+// distance from the center so that ties favor a centered crop
+func smartCropCandidates(width, height, winWidth, winHeight uint, n int) []Rect {
+	spareX := max(0, int(width)-int(winWidth))
+	spareY := max(0, int(height)-int(winHeight))
+
+	horizontal := spareX >= spareY
+	spare := spareY
+	if horizontal {
+		spare = spareX
+	}
+
+	positions := max(1, min(n, spare+1))
+	rects := make([]Rect, 0, positions)
+	for i := 0; i < positions; i++ {
+		offset := spare / 2
+		if positions > 1 {
+			offset = i * spare / (positions - 1)
+		}
+
+		rect := Rect{X: spareX / 2, Y: spareY / 2, Width: winWidth, Height: winHeight}
+		if horizontal {
+			rect.X = offset
+		} else {
+			rect.Y = offset
+		}
+		rects = append(rects, rect)
+	}
+
+	distance := func(r Rect) int {
+		if horizontal {
+			return abs(2*r.X - spare)
+		}
+		return abs(2*r.Y - spare)
+	}
+	sort.SliceStable(rects, func(i, j int) bool {
+		return distance(rects[i]) < distance(rects[j])
+	})
+	return rects
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// isSkin reports whether an RGB color is a skin tone, using the Kovac et al.
+// daylight rule
+func isSkin(r, g, b uint8) bool {
+	maxC := max(r, g, b)
+	minC := min(r, g, b)
+	diff := int(r) - int(g)
+	return r > 95 && g > 40 && b > 20 && maxC-minC > 15 && diff > 15 && r > b
+}
+
+// skinFraction returns the fraction of skin tone pixels of rect in packed
+// RGB pixels with the given row width
+func skinFraction(rgb []byte, width uint, rect Rect) float64 {
+	total, skin := 0, 0
+	for y := rect.Y; y < rect.Y+int(rect.Height); y++ {
+		for x := rect.X; x < rect.X+int(rect.Width); x++ {
+			i := (y*int(width) + x) * 3
+			if i+2 >= len(rgb) {
+				continue
+			}
+			total++
+			if isSkin(rgb[i], rgb[i+1], rgb[i+2]) {
+				skin++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(skin) / float64(total)
+}
+
+// edgeDensity returns the mean edge strength (0-1) of rect in 8-bit edge
+// magnitudes with the given row width
+func edgeDensity(gray []byte, width uint, rect Rect) float64 {
+	total, sum := 0, 0
+	for y := rect.Y; y < rect.Y+int(rect.Height); y++ {
+		for x := rect.X; x < rect.X+int(rect.Width); x++ {
+			i := y*int(width) + x
+			if i >= len(gray) {
+				continue
+			}
+			total++
+			sum += int(gray[i])
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(sum) / float64(total) / 255
+}
+
+// rankSmartCrop normalizes entropy and edge scores relative to the best
+// candidate, sets each candidate's Score and returns the index of the
+// winner. Earlier candidates win ties.
+func rankSmartCrop(candidates []SmartCropScore) int {
+	var maxEntropy, maxEdges float64
+	for _, c := range candidates {
+		maxEntropy = math.Max(maxEntropy, c.Entropy)
+		maxEdges = math.Max(maxEdges, c.Edges)
+	}
+
+	best := 0
+	for i := range candidates {
+		c := &candidates[i]
+		if maxEntropy > 0 {
+			c.Entropy /= maxEntropy
+		}
+		if maxEdges > 0 {
+			c.Edges /= maxEdges
+		}
+		c.Score = smartCropEntropyWeight*c.Entropy + smartCropEdgeWeight*c.Edges + smartCropSkinWeight*c.Skin
+
+		if c.Score > candidates[best].Score {
+			best = i
+		}
+	}
+	return best
+}
+
+// scaleRect maps a window of an aWidth x aHeight analysis image to the
+// largest window with the aspect ratio of width x height at the same relative
+// position in a srcWidth x srcHeight image
+func scaleRect(rect Rect, srcWidth, srcHeight, aWidth, aHeight, width, height uint) Rect {
+	winWidth, winHeight := fitDimensions(width, height, srcWidth, srcHeight)
+
+	x := int(math.Round(float64(rect.X) * float64(srcWidth) / float64(aWidth)))
+	y := int(math.Round(float64(rect.Y) * float64(srcHeight) / float64(aHeight)))
+
+	return Rect{
+		X:      max(0, min(x, int(srcWidth)-int(winWidth))),
+		Y:      max(0, min(y, int(srcHeight)-int(winHeight))),
+		Width:  winWidth,
+		Height: winHeight,
+	}
+}
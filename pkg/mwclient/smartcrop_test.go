@@ -0,0 +1,167 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// TestSmartCropCandidates tests the placement of candidate windows
+func TestSmartCropCandidates(t *testing.T) {
+	rects := smartCropCandidates(200, 100, 100, 100, 5)
+	if len(rects) != 5 {
+		t.Fatalf("expected 5 candidates, got %d", len(rects))
+	}
+	// Centered window first, then spreading outwards
+	if rects[0].X != 50 || rects[0].Y != 0 {
+		t.Errorf("expected centered first candidate, got %+v", rects[0])
+	}
+	seen := map[int]bool{}
+	for _, r := range rects {
+		if r.Width != 100 || r.Height != 100 || r.Y != 0 || r.X < 0 || r.X > 100 {
+			t.Errorf("unexpected candidate %+v", r)
+		}
+		seen[r.X] = true
+	}
+	for _, x := range []int{0, 25, 50, 75, 100} {
+		if !seen[x] {
+			t.Errorf("missing candidate at x=%d", x)
+		}
+	}
+
+	// Vertical free axis
+	rects = smartCropCandidates(100, 300, 100, 100, 3)
+	if len(rects) != 3 || rects[0].Y != 100 {
+		t.Errorf("unexpected vertical candidates %+v", rects)
+	}
+
+	// No free axis yields the single full window
+	rects = smartCropCandidates(100, 100, 100, 100, 9)
+	if len(rects) != 1 || rects[0] != (Rect{Width: 100, Height: 100}) {
+		t.Errorf("unexpected candidates for exact fit %+v", rects)
+	}
+}
+
+// TestSkinFraction tests the skin tone heuristic
+func TestSkinFraction(t *testing.T) {
+	if !isSkin(224, 172, 140) {
+		t.Error("expected a typical skin tone to match")
+	}
+	if isSkin(40, 120, 200) || isSkin(128, 128, 128) {
+		t.Error("expected blue and gray not to match")
+	}
+
+	// 2x1 image: one skin pixel, one blue pixel
+	rgb := []byte{224, 172, 140, 40, 120, 200}
+	if got := skinFraction(rgb, 2, Rect{Width: 2, Height: 1}); got != 0.5 {
+		t.Errorf("expected 0.5, got %v", got)
+	}
+	if got := skinFraction(rgb, 2, Rect{X: 1, Width: 1, Height: 1}); got != 0 {
+		t.Errorf("expected 0, got %v", got)
+	}
+}
+
+// TestEdgeDensity tests averaging edge magnitudes over a region
+func TestEdgeDensity(t *testing.T) {
+	// 2x2 image: a strong edge on the left column only
+	gray := []byte{255, 0, 255, 0}
+	if got := edgeDensity(gray, 2, Rect{Width: 2, Height: 2}); got != 0.5 {
+		t.Errorf("expected 0.5, got %v", got)
+	}
+	if got := edgeDensity(gray, 2, Rect{Width: 1, Height: 2}); got != 1 {
+		t.Errorf("expected 1, got %v", got)
+	}
+	if got := edgeDensity(gray, 2, Rect{X: 1, Width: 1, Height: 2}); got != 0 {
+		t.Errorf("expected 0, got %v", got)
+	}
+}
+
+// TestRankSmartCrop tests scoring and tie-breaking
+func TestRankSmartCrop(t *testing.T) {
+	candidates := []SmartCropScore{
+		{Entropy: 2, Edges: 10},
+		{Entropy: 4, Edges: 20},
+		{Entropy: 4, Edges: 5, Skin: 0.1},
+	}
+	if best := rankSmartCrop(candidates); best != 1 {
+		t.Errorf("expected candidate 1, got %d (%+v)", best, candidates)
+	}
+	if candidates[1].Entropy != 1 || candidates[1].Edges != 1 {
+		t.Errorf("expected normalized scores, got %+v", candidates[1])
+	}
+
+	// Flat images keep the first (centered) candidate
+	flat := []SmartCropScore{{}, {}, {}}
+	if best := rankSmartCrop(flat); best != 0 {
+		t.Errorf("expected candidate 0 for a flat image, got %d", best)
+	}
+}
+
+// TestScaleRect tests mapping analysis windows to source coordinates
+func TestScaleRect(t *testing.T) {
+	got := scaleRect(Rect{X: 128, Width: 128, Height: 128}, 1000, 500, 256, 128, 1, 1)
+	want := Rect{X: 500, Y: 0, Width: 500, Height: 500}
+	if got != want {
+		t.Errorf("scaleRect = %+v, want %+v", got, want)
+	}
+
+	// Clamped to the image bounds
+	got = scaleRect(Rect{X: 200, Width: 128, Height: 128}, 1000, 500, 256, 128, 1, 1)
+	if got.X != 500 {
+		t.Errorf("expected clamped x=500, got %+v", got)
+	}
+}
+
+// TestSmartCrop tests that the detailed region survives a smart crop
+func TestSmartCrop(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	// Flat gray on the left, a checkerboard on the right
+	img := image.NewGray(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := uint8(128)
+			if x >= 200 && (x/5+y/5)%2 == 0 {
+				c = 255
+			} else if x >= 200 {
+				c = 0
+			}
+			img.SetGray(x, y, color.Gray{Y: c})
+		}
+	}
+	var src bytes.Buffer
+	if err := png.Encode(&src, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	var buf bytes.Buffer
+	result, err := client.SmartCrop(context.Background(), bytes.NewReader(src.Bytes()), &buf, 50, 50, "png")
+	if err != nil {
+		t.Fatalf("SmartCrop failed: %v", err)
+	}
+
+	if result.Rect.X < 150 || result.Rect.Width != 100 || result.Rect.Height != 100 {
+		t.Errorf("expected the detailed right side to be chosen, got %+v", result.Rect)
+	}
+	if len(result.Candidates) == 0 {
+		t.Error("expected candidate scores for auditing")
+	}
+	if _, width, height := decodeConfig(t, buf.Bytes()); width != 50 || height != 50 {
+		t.Errorf("expected 50x50, got %dx%d", width, height)
+	}
+
+	_, err = client.SmartCrop(context.Background(), bytes.NewReader(src.Bytes()), &buf, 0, 50, "png")
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with zero width, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// sizer is implemented by readers that know their total size, such as
+// *bytes.Reader, *strings.Reader and *io.SectionReader
+type sizer interface {
+	Size() int64
+}
+
+// RunReaderAt is like Run but reads the first size bytes of ra. The size is
+// checked against WithMaxInputSize before anything is read.
+func (p *Pipeline) RunReaderAt(ctx context.Context, ra io.ReaderAt, size int64, w io.Writer) error {
+	if ra == nil || w == nil {
+		return fmt.Errorf("%w: reader or writer is nil", ErrInvalidInput)
+	}
+	if size < 0 {
+		return fmt.Errorf("%w: negative input size", ErrInvalidInput)
+	}
+	return p.Run(ctx, io.NewSectionReader(ra, 0, size), w)
+}
+
+// readImage decodes the image read from r into mw.
+//
+// Regular files positioned at their start are decoded straight from their
+// descriptor, which ImageMagick reads from the beginning. Other inputs,
+// including files already partly read, are buffered in memory up to the
+// spool threshold and spooled to a temporary file beyond it, so the Go heap
+// never holds more than the threshold.
+func readImage(mw *imagick.MagickWand, r io.Reader, s *settings) error {
+	if f, ok := r.(*os.File); ok && atStart(f) {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			if err := checkInputSize(info.Size(), s); err != nil {
+				return err
+			}
+			if err := mw.ReadImageFile(f); err != nil {
+				return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
+			}
+			return nil
+		}
+	}
+	if sr, ok := r.(sizer); ok {
+		if err := checkInputSize(sr.Size(), s); err != nil {
+			return err
+		}
+	}
+
+	data, path, err := spoolInput(r, s)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		// ImageMagick has decoded the file once ReadImage returns
+		defer os.Remove(path)
+		if err := mw.ReadImage(path); err != nil {
+			return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
+		}
+		return nil
+	}
+
+	if err := mw.ReadImageBlob(data); err != nil {
+		return fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
+	}
+	return nil
+}
+
+// atStart reports whether f is positioned at its first byte
+func atStart(f *os.File) bool {
+	offset, err := f.Seek(0, io.SeekCurrent)
+	return err == nil && offset == 0
+}
+
+// checkInputSize returns ErrInputTooLarge if size exceeds the input cap
+func checkInputSize(size int64, s *settings) error {
+	if s.maxInputSize > 0 && size > s.maxInputSize {
+		return fmt.Errorf("%w: more than %d bytes", ErrInputTooLarge, s.maxInputSize)
+	}
+	return nil
+}
+
+// checkFileSize returns ErrInputTooLarge if the file at path exceeds the
+// input cap. Paths that cannot be stated, such as ImageMagick's "file[0]"
+// syntax, are left to ImageMagick.
+func checkFileSize(path string, s *settings) error {
+	if s.maxInputSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	return checkInputSize(info.Size(), s)
+}
+
+// spoolInput reads r, enforcing the input cap. Inputs up to the spool
+// threshold are returned in memory; larger ones are copied to a temporary
+// file whose path is returned instead. The caller removes the file.
+func spoolInput(r io.Reader, s *settings) ([]byte, string, error) {
+	if s.maxInputSize > 0 {
+		// Read one byte past the cap so oversized inputs are detected
+		r = io.LimitReader(r, s.maxInputSize+1)
+	}
+
+	head := r
+	if s.spoolThreshold > 0 {
+		head = io.LimitReader(r, s.spoolThreshold+1)
+	}
+	data, err := io.ReadAll(head)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image data: %w", err)
+	}
+	if err := checkInputSize(int64(len(data)), s); err != nil {
+		return nil, "", err
+	}
+	if s.spoolThreshold == 0 || int64(len(data)) <= s.spoolThreshold {
+		return data, "", nil
+	}
+
+	// Spool the buffered head followed by the rest of the input
+	path, err := spoolFile(io.MultiReader(bytes.NewReader(data), r), s)
+	if err != nil {
+		return nil, "", err
+	}
+	return nil, path, nil
+}
+
+// spoolFile copies r to a temporary file, enforcing the input cap, and
+// returns its path. The caller removes the file.
+func spoolFile(r io.Reader, s *settings) (string, error) {
+	if s.maxInputSize > 0 {
+		r = io.LimitReader(r, s.maxInputSize+1)
+	}
+
+	f, err := os.CreateTemp(s.tempDir, "mwclient-*")
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to create temporary file: %v", ErrProcessing, err)
+	}
+	path := f.Name()
+
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = checkInputSize(n, s)
+	} else {
+		err = fmt.Errorf("failed to read image data: %w", err)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// writeImage encodes mw and writes the result to w, returning the number of
+// bytes written.
+//
+// Images whose uncompressed size exceeds the spool threshold are encoded to a
+// temporary file and copied to w in chunks; smaller ones are encoded in
+// memory.
+func writeImage(mw *imagick.MagickWand, w io.Writer, s *settings) (int64, error) {
+	if spoolOutput(mw, s) {
+		return streamImage(mw, w, s)
+	}
+
+	// Get the image blob, with every frame of an animation
+	blob, err := mw.GetImagesBlob()
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to get image blob: %v", ErrProcessing, err)
+	}
+	if len(blob) == 0 {
+		return 0, fmt.Errorf("%w: empty result image", ErrProcessing)
+	}
+
+	// Write the result
+	n, err := w.Write(blob)
+	if err != nil {
+		return int64(n), fmt.Errorf("failed to write image data: %w", err)
+	}
+	return int64(n), nil
+}
+
+// spoolOutput reports whether the uncompressed size of the image in mw
+// exceeds the spool threshold
+func spoolOutput(mw *imagick.MagickWand, s *settings) bool {
+	raw := int64(mw.GetImageWidth()) * int64(mw.GetImageHeight()) * 4
+	return s.spoolThreshold > 0 && raw > s.spoolThreshold
+}
+
+// streamImage encodes mw to a temporary file and copies it to w
+func streamImage(mw *imagick.MagickWand, w io.Writer, s *settings) (int64, error) {
+	f, err := os.CreateTemp(s.tempDir, "mwclient-*")
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to create temporary file: %v", ErrProcessing, err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	if err := mw.WriteImagesFile(f); err != nil {
+		return 0, fmt.Errorf("%w: failed to write image: %v", ErrProcessing, err)
+	}
+	return copyResult(f, w)
+}
+
+// copyResult copies the encoded image in f, from the start, to w
+func copyResult(f *os.File, w io.Writer) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to stat temporary file: %v", ErrProcessing, err)
+	}
+	if info.Size() == 0 {
+		return 0, fmt.Errorf("%w: empty result image", ErrProcessing)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("%w: failed to rewind temporary file: %v", ErrProcessing, err)
+	}
+
+	n, err := io.Copy(w, f)
+	if err != nil {
+		return n, fmt.Errorf("failed to write image data: %w", err)
+	}
+	return n, nil
+}
+
+// writeImageFile writes mw, with every frame of an animation, to path
+func writeImageFile(mw *imagick.MagickWand, path string) error {
+	if err := mw.WriteImages(path, true); err != nil {
+		return fmt.Errorf("%w: failed to write image: %v", ErrProcessing, err)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// zeroReader is an endless reader of zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// TestSpoolInput tests that inputs are buffered or spooled by size
+func TestSpoolInput(t *testing.T) {
+	dir := t.TempDir()
+	s := defaultSettings()
+	s.spoolThreshold = 16
+	s.tempDir = dir
+
+	data, path, err := spoolInput(strings.NewReader("small input"), &s)
+	if err != nil {
+		t.Fatalf("spoolInput failed: %v", err)
+	}
+	if path != "" || string(data) != "small input" {
+		t.Errorf("expected in-memory input, got %q, path %q", data, path)
+	}
+
+	large := strings.Repeat("0123456789", 10)
+	data, path, err = spoolInput(strings.NewReader(large), &s)
+	if err != nil {
+		t.Fatalf("spoolInput failed: %v", err)
+	}
+	if data != nil || filepath.Dir(path) != dir {
+		t.Fatalf("expected input spooled to %s, got path %q", dir, path)
+	}
+	spooled, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read spooled input: %v", err)
+	}
+	if string(spooled) != large {
+		t.Errorf("spooled input differs from the original")
+	}
+	os.Remove(path)
+
+	s.spoolThreshold = 0
+	data, path, err = spoolInput(strings.NewReader(large), &s)
+	if err != nil {
+		t.Fatalf("spoolInput failed: %v", err)
+	}
+	if path != "" || string(data) != large {
+		t.Errorf("expected in-memory input with spooling disabled, got path %q", path)
+	}
+}
+
+// TestSpoolInputMaxSize tests that oversized inputs are rejected and leave
+// no temporary files behind
+func TestSpoolInputMaxSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int64
+	}{
+		{"in memory", 0},
+		{"spooled", 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := defaultSettings()
+			s.spoolThreshold = tt.threshold
+			s.tempDir = dir
+			s.maxInputSize = 64
+
+			_, path, err := spoolInput(strings.NewReader(strings.Repeat("x", 64)), &s)
+			if err != nil {
+				t.Errorf("expected input at the cap to be accepted, got %v", err)
+			}
+			if path != "" {
+				os.Remove(path)
+			}
+
+			_, _, err = spoolInput(io.LimitReader(zeroReader{}, 65), &s)
+			if !errors.Is(err, ErrInputTooLarge) || !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("expected ErrInputTooLarge, got %v", err)
+			}
+
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("expected oversized input to be removed, found %d files", len(entries))
+			}
+		})
+	}
+}
+
+// TestCheckFileSize tests the input cap for path inputs
+func TestCheckFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.bin")
+	if err := os.WriteFile(path, make([]byte, 65), 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	s := defaultSettings()
+	if err := checkFileSize(path, &s); err != nil {
+		t.Errorf("expected no limit by default, got %v", err)
+	}
+
+	s.maxInputSize = 64
+	if err := checkFileSize(path, &s); !errors.Is(err, ErrInputTooLarge) || !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInputTooLarge, got %v", err)
+	}
+
+	// ImageMagick reports paths it cannot read itself
+	if err := checkFileSize(path+"[0]", &s); err != nil {
+		t.Errorf("expected unreadable paths to be left to ImageMagick, got %v", err)
+	}
+
+	s.maxInputSize = 65
+	if err := checkFileSize(path, &s); err != nil {
+		t.Errorf("expected input at the cap to be accepted, got %v", err)
+	}
+}
+
+// TestSpoolInputMemory tests that spooling a large input keeps the Go heap
+// bounded by the spool threshold rather than the input size
+func TestSpoolInputMemory(t *testing.T) {
+	const (
+		inputSize = 64 << 20
+		threshold = 1 << 20
+	)
+
+	s := defaultSettings()
+	s.spoolThreshold = threshold
+	s.tempDir = t.TempDir()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	_, path, err := spoolInput(io.LimitReader(zeroReader{}, inputSize), &s)
+	if err != nil {
+		t.Fatalf("spoolInput failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 8*threshold {
+		t.Errorf("spooling a %d byte input allocated %d bytes", inputSize, allocated)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat spooled input: %v", err)
+	}
+	if info.Size() != inputSize {
+		t.Errorf("expected %d spooled bytes, got %d", inputSize, info.Size())
+	}
+}
+
+// TestCopyResult tests copying an encoded result from a temporary file
+func TestCopyResult(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "result-*")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer f.Close()
+
+	_, err = copyResult(f, io.Discard)
+	if !errors.Is(err, ErrProcessing) {
+		t.Errorf("expected ErrProcessing for an empty result, got %v", err)
+	}
+
+	if _, err := f.WriteString("encoded image"); err != nil {
+		t.Fatalf("failed to write temporary file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := copyResult(f, &buf)
+	if err != nil {
+		t.Fatalf("copyResult failed: %v", err)
+	}
+	if n != int64(buf.Len()) || buf.String() != "encoded image" {
+		t.Errorf("expected the whole result to be copied, got %q (%d bytes)", buf.String(), n)
+	}
+}
+
+// TestRunStreaming tests the spooled and direct-file input paths and the
+// chunked output path
+func TestRunStreaming(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	dir := t.TempDir()
+	client := New(WithSpoolThreshold(1024), WithTempDir(dir))
+	defer client.Close()
+
+	ctx := context.Background()
+	src := testPNG(t, 400, 300)
+
+	// Spooled reader input and streamed output
+	var buf bytes.Buffer
+	if err := client.Pipeline().Encode("png").Run(ctx, bytes.NewReader(src), &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if format, w, h := decodeConfig(t, buf.Bytes()); format != "png" || w != 400 || h != 300 {
+		t.Errorf("expected a 400x300 png, got %dx%d %s", w, h, format)
+	}
+
+	// Direct file input
+	inputPath := filepath.Join(t.TempDir(), "input.png")
+	if err := os.WriteFile(inputPath, src, 0o644); err != nil {
+		t.Fatalf("Failed to write test image: %v", err)
+	}
+	f, err := os.Open(inputPath)
+	if err != nil {
+		t.Fatalf("Failed to open test image: %v", err)
+	}
+	defer f.Close()
+
+	buf.Reset()
+	if err := client.Pipeline().Resize(200, 0).Run(ctx, f, &buf); err != nil {
+		t.Fatalf("Run with file input failed: %v", err)
+	}
+	if _, w, h := decodeConfig(t, buf.Bytes()); w != 200 || h != 150 {
+		t.Errorf("expected 200x150, got %dx%d", w, h)
+	}
+
+	// A file positioned past its start is read from its offset
+	prefixedPath := filepath.Join(t.TempDir(), "prefixed.bin")
+	if err := os.WriteFile(prefixedPath, append([]byte("header:"), src...), 0o644); err != nil {
+		t.Fatalf("Failed to write test image: %v", err)
+	}
+	pf, err := os.Open(prefixedPath)
+	if err != nil {
+		t.Fatalf("Failed to open test image: %v", err)
+	}
+	defer pf.Close()
+	if _, err := pf.Seek(int64(len("header:")), io.SeekStart); err != nil {
+		t.Fatalf("Failed to seek: %v", err)
+	}
+	buf.Reset()
+	if err := client.Pipeline().Encode("png").Run(ctx, pf, &buf); err != nil {
+		t.Fatalf("Run with a positioned file failed: %v", err)
+	}
+	if _, w, h := decodeConfig(t, buf.Bytes()); w != 400 || h != 300 {
+		t.Errorf("expected 400x300, got %dx%d", w, h)
+	}
+
+	// ReaderAt input
+	buf.Reset()
+	if err := client.Pipeline().RunReaderAt(ctx, bytes.NewReader(src), int64(len(src)), &buf); err != nil {
+		t.Fatalf("RunReaderAt failed: %v", err)
+	}
+
+	// No temporary files are left behind
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no temporary files, found %d", len(entries))
+	}
+
+	// Size cap
+	err = client.Pipeline(WithMaxInputSize(100)).Run(ctx, bytes.NewReader(src), &buf)
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("expected ErrInputTooLarge, got %v", err)
+	}
+
+	// Size cap for path inputs
+	outputPath := filepath.Join(t.TempDir(), "output.png")
+	err = client.Pipeline(WithMaxInputSize(100)).RunFile(ctx, inputPath, outputPath)
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("expected ErrInputTooLarge from RunFile, got %v", err)
+	}
+	if _, err := client.OpenImage(inputPath, WithMaxInputSize(100)); !errors.Is(err, ErrInputTooLarge) {
+		t.Errorf("expected ErrInputTooLarge from OpenImage, got %v", err)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("expected no output for an oversized input")
+	}
+}
@@ -0,0 +1,172 @@
+package mwclient
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// StripMode selects which metadata is removed before encoding
+type StripMode int
+
+const (
+	// KeepMetadata writes all metadata unchanged
+	KeepMetadata StripMode = iota
+	// StripAllMetadata removes all profiles, comments and text chunks
+	StripAllMetadata
+	// StripExceptAllowlist removes everything not allowed by the policy
+	StripExceptAllowlist
+)
+
+// String returns the name of the strip mode
+func (m StripMode) String() string {
+	switch m {
+	case KeepMetadata:
+		return "keep"
+	case StripAllMetadata:
+		return "all"
+	case StripExceptAllowlist:
+		return "allowlist"
+	default:
+		return fmt.Sprintf("StripMode(%d)", int(m))
+	}
+}
+
+// StripPolicy controls the metadata written to output images.
+//
+// Stripping always normalizes the orientation first, rotating the pixels
+// upright, so dropping the EXIF orientation tag never turns the image
+// sideways. The allowlist fields only apply with StripExceptAllowlist.
+type StripPolicy struct {
+	Mode StripMode
+
+	KeepICC       bool // embedded ICC color profile
+	KeepCopyright bool // EXIF Copyright and Artist tags
+}
+
+// validate checks the policy for unknown modes
+func (p StripPolicy) validate() error {
+	if p.Mode < KeepMetadata || p.Mode > StripExceptAllowlist {
+		return fmt.Errorf("%w: unknown strip mode %d", ErrInvalidInput, int(p.Mode))
+	}
+	return nil
+}
+
+// pngMetadataChunks lists the PNG chunks that carry metadata. ImageMagick
+// writes them from image properties unless they are excluded. StripImage
+// also excludes rendering chunks such as gAMA, sRGB and cHRM, which change
+// how colors display; setting the exclusion list keeps them.
+var pngMetadataChunks = []string{"eXIf", "iCCP", "iTXt", "tEXt", "zTXt", "date"}
+
+// applyStripPolicy removes the metadata not allowed by policy from the
+// current image in mw
+func applyStripPolicy(mw *imagick.MagickWand, policy StripPolicy) error {
+	if policy.Mode == KeepMetadata {
+		return nil
+	}
+
+	if err := mw.AutoOrientImage(); err != nil {
+		return fmt.Errorf("%w: failed to normalize orientation: %v", ErrProcessing, err)
+	}
+
+	// Collect the allowed metadata before it is stripped
+	var icc, exif []byte
+	if policy.Mode == StripExceptAllowlist {
+		if policy.KeepICC {
+			icc = []byte(mw.GetImageProfile("icc"))
+		}
+		if policy.KeepCopyright {
+			exif = copyrightEXIF(mw.GetImageProperty("exif:Copyright"), mw.GetImageProperty("exif:Artist"))
+		}
+	}
+
+	if err := mw.StripImage(); err != nil {
+		return fmt.Errorf("%w: failed to strip image: %v", ErrProcessing, err)
+	}
+
+	// Exclude only the PNG metadata chunks, except the ones that carry
+	// allowed profiles
+	excluded := pngMetadataChunks
+	if len(icc) > 0 {
+		if err := mw.SetImageProfile("icc", icc); err != nil {
+			return fmt.Errorf("%w: failed to restore ICC profile: %v", ErrProcessing, err)
+		}
+		excluded = without(excluded, "iCCP")
+	}
+	if len(exif) > 0 {
+		if err := mw.SetImageProfile("exif", exif); err != nil {
+			return fmt.Errorf("%w: failed to restore copyright: %v", ErrProcessing, err)
+		}
+		excluded = without(excluded, "eXIf")
+	}
+	if err := mw.SetImageArtifact("png:exclude-chunk", strings.Join(excluded, ",")); err != nil {
+		return fmt.Errorf("%w: failed to exclude PNG chunks: %v", ErrProcessing, err)
+	}
+
+	return nil
+}
+
+// without returns list without the given element
+func without(list []string, elem string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != elem {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// copyrightEXIF builds an EXIF profile holding only the Copyright and
+// Artist tags, or returns nil if both are empty
+func copyrightEXIF(copyright, artist string) []byte {
+	type entry struct {
+		tag   uint16
+		value string
+	}
+
+	// IFD entries must be sorted by tag
+	var entries []entry
+	if artist = strings.TrimSpace(artist); artist != "" {
+		entries = append(entries, entry{0x013b, artist})
+	}
+	if copyright = strings.TrimSpace(copyright); copyright != "" {
+		entries = append(entries, entry{0x8298, copyright})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	le := binary.LittleEndian
+	b := []byte("Exif\x00\x00")
+	b = append(b, 'I', 'I', 42, 0)
+	b = le.AppendUint32(b, 8) // IFD0 follows the header
+
+	// Values longer than four bytes are stored after the IFD. Offsets are
+	// relative to the TIFF header.
+	dataOffset := 8 + 2 + 12*len(entries) + 4
+	var data []byte
+
+	b = le.AppendUint16(b, uint16(len(entries)))
+	for _, e := range entries {
+		value := append([]byte(e.value), 0)
+		b = le.AppendUint16(b, e.tag)
+		b = le.AppendUint16(b, 2) // ASCII
+		b = le.AppendUint32(b, uint32(len(value)))
+		if len(value) <= 4 {
+			b = append(b, value...)
+			b = append(b, make([]byte, 4-len(value))...)
+			continue
+		}
+		b = le.AppendUint32(b, uint32(dataOffset+len(data)))
+		data = append(data, value...)
+		if len(data)%2 == 1 {
+			data = append(data, 0)
+		}
+	}
+	b = le.AppendUint32(b, 0) // no IFD1
+
+	return append(b, data...)
+}
@@ -0,0 +1,244 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// exifASCII returns the ASCII tags of IFD0 in an EXIF profile
+func exifASCII(t *testing.T, exif []byte) map[uint16]string {
+	t.Helper()
+
+	tiff, ok := bytes.CutPrefix(exif, []byte("Exif\x00\x00"))
+	if !ok || !bytes.HasPrefix(tiff, []byte("II*\x00")) {
+		t.Fatalf("missing EXIF or TIFF header: % x", exif)
+	}
+
+	le := binary.LittleEndian
+	ifd := tiff[le.Uint32(tiff[4:8]):]
+	tags := make(map[uint16]string)
+	for i := 0; i < int(le.Uint16(ifd)); i++ {
+		e := ifd[2+12*i : 2+12*(i+1)]
+		if le.Uint16(e[2:4]) != 2 {
+			continue
+		}
+		n := le.Uint32(e[4:8])
+		value := e[8:12]
+		if n > 4 {
+			off := le.Uint32(e[8:12])
+			value = tiff[off : off+n]
+		}
+		tags[le.Uint16(e[0:2])] = strings.TrimRight(string(value[:n]), "\x00")
+	}
+	return tags
+}
+
+// TestCopyrightEXIF tests building the copyright-only EXIF profile
+func TestCopyrightEXIF(t *testing.T) {
+	if exif := copyrightEXIF(" ", ""); exif != nil {
+		t.Errorf("expected no profile without copyright, got % x", exif)
+	}
+
+	tags := exifASCII(t, copyrightEXIF("© 2024 Jane Doe", "Jane"))
+	if tags[0x8298] != "© 2024 Jane Doe" || tags[0x013b] != "Jane" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+
+	tags = exifASCII(t, copyrightEXIF("ACME", ""))
+	if len(tags) != 1 || tags[0x8298] != "ACME" {
+		t.Errorf("expected only an inline copyright tag, got %v", tags)
+	}
+}
+
+// TestWithout tests removing an element from a list
+func TestWithout(t *testing.T) {
+	got := without(pngMetadataChunks, "iCCP")
+	if len(got) != len(pngMetadataChunks)-1 || strings.Contains(strings.Join(got, ","), "iCCP") {
+		t.Errorf("expected iCCP to be removed, got %v", got)
+	}
+	if len(pngMetadataChunks) != 6 {
+		t.Error("without modified its input")
+	}
+}
+
+// gpsEXIF returns an EXIF profile with a copyright and a GPS location
+func gpsEXIF() []byte {
+	le := binary.LittleEndian
+	b := []byte("Exif\x00\x00II*\x00")
+	b = le.AppendUint32(b, 8)
+
+	// IFD0 at 8: Copyright at 38, GPS IFD at 48
+	b = le.AppendUint16(b, 2)
+	b = append(b, le.AppendUint32(le.AppendUint32(le.AppendUint16(le.AppendUint16(nil, 0x8298), 2), 9), 38)...)
+	b = append(b, le.AppendUint32(le.AppendUint32(le.AppendUint16(le.AppendUint16(nil, 0x8825), 4), 1), 48)...)
+	b = le.AppendUint32(b, 0)
+	b = append(b, "Jane Doe\x00\x00"...)
+
+	// GPS IFD at 48: latitude at 102, longitude at 126
+	b = le.AppendUint16(b, 4)
+	b = append(b, le.AppendUint32(le.AppendUint16(le.AppendUint16(nil, 1), 2), 2)...)
+	b = append(b, 'N', 0, 0, 0)
+	b = append(b, le.AppendUint32(le.AppendUint32(le.AppendUint16(le.AppendUint16(nil, 2), 5), 3), 102)...)
+	b = append(b, le.AppendUint32(le.AppendUint16(le.AppendUint16(nil, 3), 2), 2)...)
+	b = append(b, 'E', 0, 0, 0)
+	b = append(b, le.AppendUint32(le.AppendUint32(le.AppendUint16(le.AppendUint16(nil, 4), 5), 3), 126)...)
+	b = le.AppendUint32(b, 0)
+	for _, v := range []uint32{52, 1, 31, 1, 0, 1, 13, 1, 24, 1, 0, 1} {
+		b = le.AppendUint32(b, v)
+	}
+	return b
+}
+
+// TestStripPolicy tests that GPS tags are removed from every output format
+func TestStripPolicy(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	// Build a JPEG carrying a GPS location
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+	if err := mw.ReadImageBlob(testPNG(t, 64, 48)); err != nil {
+		t.Fatalf("Failed to read test image: %v", err)
+	}
+	if err := mw.SetImageProfile("exif", gpsEXIF()); err != nil {
+		t.Fatalf("Failed to set EXIF profile: %v", err)
+	}
+	if err := mw.SetImageFormat("jpeg"); err != nil {
+		t.Fatalf("Failed to set format: %v", err)
+	}
+	src, err := mw.GetImageBlob()
+	if err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	// properties returns the EXIF properties of an encoded image
+	properties := func(t *testing.T, data []byte) map[string]string {
+		t.Helper()
+		mw := imagick.NewMagickWand()
+		defer mw.Destroy()
+		if err := mw.ReadImageBlob(data); err != nil {
+			t.Fatalf("Failed to read result image: %v", err)
+		}
+		props := make(map[string]string)
+		for _, name := range mw.GetImageProperties("exif:*") {
+			props[name] = mw.GetImageProperty(name)
+		}
+		return props
+	}
+
+	if props := properties(t, src); props["exif:GPSLatitude"] == "" {
+		t.Fatal("test image has no GPS location")
+	}
+
+	client := New()
+	defer client.Close()
+	ctx := context.Background()
+
+	policies := []struct {
+		name          string
+		policy        StripPolicy
+		wantCopyright bool
+	}{
+		{"strip all", StripPolicy{Mode: StripAllMetadata}, false},
+		{"allowlist", StripPolicy{Mode: StripExceptAllowlist, KeepICC: true, KeepCopyright: true}, true},
+	}
+
+	for _, format := range []string{"jpeg", "png", "webp", "tiff"} {
+		for _, tt := range policies {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				var buf bytes.Buffer
+				err := client.Pipeline(WithStripPolicy(tt.policy)).Encode(format).Run(ctx, bytes.NewReader(src), &buf)
+				if err != nil {
+					t.Fatalf("Run failed: %v", err)
+				}
+
+				// The latitude rationals, past the 6 byte EXIF header
+				if bytes.Contains(buf.Bytes(), gpsEXIF()[6+102:6+126]) {
+					t.Error("output contains the GPS latitude")
+				}
+				props := properties(t, buf.Bytes())
+				for name := range props {
+					if strings.HasPrefix(name, "exif:GPS") {
+						t.Errorf("output still has %s", name)
+					}
+				}
+				// ImageMagick only writes EXIF to WebP when built with libwebpmux
+				if got := props["exif:Copyright"] == "Jane Doe"; got != tt.wantCopyright && format != "webp" {
+					t.Errorf("expected copyright kept %v, got properties %v", tt.wantCopyright, props)
+				}
+			})
+		}
+	}
+}
+
+// pngChunks returns the chunk types of a PNG file in order
+func pngChunks(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	rest, ok := bytes.CutPrefix(data, []byte("\x89PNG\r\n\x1a\n"))
+	if !ok {
+		t.Fatal("missing PNG signature")
+	}
+	var chunks []string
+	for len(rest) >= 12 {
+		n := binary.BigEndian.Uint32(rest)
+		chunks = append(chunks, string(rest[4:8]))
+		if uint64(n)+12 > uint64(len(rest)) {
+			t.Fatalf("truncated %s chunk", rest[4:8])
+		}
+		rest = rest[12+n:]
+	}
+	return chunks
+}
+
+// insertPNGChunk returns data with a chunk inserted after the IHDR chunk
+func insertPNGChunk(data []byte, kind string, body []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	at := 8 + 12 + 13 // signature and IHDR
+	return append(append(append([]byte(nil), data[:at]...), chunk...), data[at:]...)
+}
+
+// TestStripPolicyPNGRendering tests that stripping keeps the PNG chunks
+// that affect how colors display
+func TestStripPolicyPNGRendering(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	src := testPNG(t, 64, 48)
+	src = insertPNGChunk(src, "gAMA", binary.BigEndian.AppendUint32(nil, 45455))
+	src = insertPNGChunk(src, "sRGB", []byte{0}) // perceptual
+	src = insertPNGChunk(src, "tEXt", []byte("Comment\x00private"))
+
+	client := New()
+	defer client.Close()
+
+	var buf bytes.Buffer
+	err := client.Pipeline(WithStripPolicy(StripPolicy{Mode: StripAllMetadata})).Encode("png").Run(context.Background(), bytes.NewReader(src), &buf)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	chunks := strings.Join(pngChunks(t, buf.Bytes()), ",")
+	for _, kind := range []string{"gAMA", "sRGB"} {
+		if !strings.Contains(chunks, kind) {
+			t.Errorf("expected %s to survive stripping, got chunks %s", kind, chunks)
+		}
+	}
+	if strings.Contains(chunks, "tEXt") || bytes.Contains(buf.Bytes(), []byte("private")) {
+		t.Errorf("expected text chunks to be stripped, got chunks %s", chunks)
+	}
+}
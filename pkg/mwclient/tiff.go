@@ -0,0 +1,245 @@
+package mwclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// TIFFCompression selects the compression of assembled TIFF pages
+type TIFFCompression int
+
+const (
+	// TIFFLZW is lossless LZW compression, the default
+	TIFFLZW TIFFCompression = iota
+	// TIFFZIP is lossless Deflate compression, usually smaller than LZW
+	TIFFZIP
+	// TIFFGroup4 is CCITT Group 4 fax compression. It only encodes bilevel
+	// images, so pages are thresholded to black and white.
+	TIFFGroup4
+	// TIFFNone stores pages uncompressed
+	TIFFNone
+)
+
+// String returns the name of the compression
+func (c TIFFCompression) String() string {
+	switch c {
+	case TIFFLZW:
+		return "lzw"
+	case TIFFZIP:
+		return "zip"
+	case TIFFGroup4:
+		return "group4"
+	case TIFFNone:
+		return "none"
+	default:
+		return fmt.Sprintf("TIFFCompression(%d)", int(c))
+	}
+}
+
+// compressionType returns the ImageMagick compression for c
+func (c TIFFCompression) compressionType() (imagick.CompressionType, error) {
+	switch c {
+	case TIFFLZW:
+		return imagick.COMPRESSION_LZW, nil
+	case TIFFZIP:
+		return imagick.COMPRESSION_ZIP, nil
+	case TIFFGroup4:
+		return imagick.COMPRESSION_GROUP4, nil
+	case TIFFNone:
+		return imagick.COMPRESSION_NO, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown TIFF compression %v", ErrInvalidInput, c)
+	}
+}
+
+// compressionNames maps ImageMagick compressions to the names used in
+// PageInfo
+var compressionNames = map[imagick.CompressionType]string{
+	imagick.COMPRESSION_NO:     "None",
+	imagick.COMPRESSION_FAX:    "Group3",
+	imagick.COMPRESSION_GROUP4: "Group4",
+	imagick.COMPRESSION_JPEG:   "JPEG",
+	imagick.COMPRESSION_LZW:    "LZW",
+	imagick.COMPRESSION_RLE:    "RLE",
+	imagick.COMPRESSION_ZIP:    "ZIP",
+}
+
+// PageInfo describes one page of a multi-page image, or one frame of an
+// animation
+type PageInfo struct {
+	Width       int32
+	Height      int32
+	ColorSpace  string
+	BitDepth    uint
+	DPI         Resolution
+	Compression string // such as "LZW", "ZIP" or "Group4"; empty if unknown
+}
+
+// readPages describes every page of the image in mw
+func readPages(mw *imagick.MagickWand) []PageInfo {
+	pages := make([]PageInfo, 0, mw.GetNumberImages())
+	forEachFrame(mw, func() error {
+		page := PageInfo{
+			Width:       int32(mw.GetImageWidth()),
+			Height:      int32(mw.GetImageHeight()),
+			ColorSpace:  colorSpaceName(mw.GetImageColorspace()),
+			BitDepth:    mw.GetImageDepth(),
+			Compression: compressionNames[mw.GetImageCompression()],
+		}
+		if x, y, err := mw.GetImageResolution(); err == nil {
+			page.DPI = dotsPerInch(x, y, mw.GetImageUnits())
+		}
+		pages = append(pages, page)
+		return nil
+	})
+	return pages
+}
+
+// pagePath returns the output path of page index (0 for the first) of a
+// document with the given number of pages: outputPath itself for a single
+// page, otherwise "<base>_page<N><ext>"
+func pagePath(outputPath string, index, pages int) string {
+	if pages <= 1 {
+		return outputPath
+	}
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+	return fmt.Sprintf("%s_page%d%s", base, index+1, ext)
+}
+
+// ExtractPages writes each page of a multi-page image, such as a scanned
+// TIFF, to its own file, named like the pages of ConvertPdfToImages. The
+// output extension picks the format. maxPages limits the number of pages
+// written (0 means all pages). It returns the paths written, in page order.
+func (c *Client) ExtractPages(ctx context.Context, inputPath, outputPath string, maxPages int, opts ...Option) ([]string, error) {
+	if inputPath == "" || outputPath == "" {
+		return nil, fmt.Errorf("%w: input or output path is empty", ErrInvalidInput)
+	}
+
+	if maxPages < 0 {
+		return nil, fmt.Errorf("%w: max pages must not be negative", ErrInvalidInput)
+	}
+
+	s, err := c.settingsFor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := c.pool.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	doc := imagick.NewMagickWand()
+	defer doc.Destroy()
+
+	if err := checkFileSize(inputPath, &s); err != nil {
+		return nil, err
+	}
+	s.logger.Info("ReadImage", "In", inputPath)
+	if err := doc.ReadImage(inputPath); err != nil {
+		return nil, fmt.Errorf("%w: failed to read image: %v", ErrProcessing, err)
+	}
+
+	numPages := int(doc.GetNumberImages())
+	if maxPages > 0 && numPages > maxPages {
+		numPages = maxPages
+	}
+
+	paths := make([]string, 0, numPages)
+	for i := 0; i < numPages; i++ {
+		if err := checkContext(ctx); err != nil {
+			return paths, err
+		}
+
+		path := pagePath(outputPath, i, numPages)
+		s.logger.Info("Processing page", "Index", i, "Path", path)
+
+		doc.SetIteratorIndex(i)
+		if err := writePage(doc.GetImage(), path, &s); err != nil {
+			return paths, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// writePage orients, converts and writes a single page, then destroys it
+func writePage(page *imagick.MagickWand, path string, s *settings) error {
+	defer page.Destroy()
+
+	if err := page.AutoOrientImage(); err != nil {
+		s.logger.Error("Auto-orientation failed", "error", err)
+		// Continue despite error
+	}
+
+	if err := applyOutputPolicies(page, s); err != nil {
+		return err
+	}
+	if err := applyEncoderOptions(page, formatFromPath(path), s); err != nil {
+		return err
+	}
+
+	if err := page.WriteImage(path); err != nil {
+		return fmt.Errorf("%w: failed to write image: %v", ErrProcessing, err)
+	}
+	return nil
+}
+
+// AssembleTIFF combines the images read from inputs into a single
+// multi-page TIFF written to w, one page per image, in order. Multi-page
+// inputs contribute all their pages.
+func (c *Client) AssembleTIFF(ctx context.Context, inputs []io.Reader, w io.Writer, compression TIFFCompression, opts ...Option) error {
+	read, err := readerInputs(inputs, w)
+	if err != nil {
+		return err
+	}
+
+	return c.assembleTIFF(ctx, len(inputs), compression, read, func(mw *imagick.MagickWand, s *settings) error {
+		_, err := writeImage(mw, w, s)
+		return err
+	}, opts)
+}
+
+// AssembleTIFFFile is like AssembleTIFF but reads the images from
+// inputPaths and writes the TIFF to outputPath
+func (c *Client) AssembleTIFFFile(ctx context.Context, inputPaths []string, outputPath string, compression TIFFCompression, opts ...Option) error {
+	read, err := pathInputs(inputPaths, outputPath)
+	if err != nil {
+		return err
+	}
+
+	return c.assembleTIFF(ctx, len(inputPaths), compression, read, documentWriter("TIFF", outputPath), opts)
+}
+
+// assembleTIFF assembles a TIFF document with the given compression
+func (c *Client) assembleTIFF(ctx context.Context, n int, compression TIFFCompression, read readInput, write writeDocument, opts []Option) error {
+	ct, err := compression.compressionType()
+	if err != nil {
+		return err
+	}
+
+	return c.assemble(ctx, n, read, func(mw *imagick.MagickWand, s *settings) error {
+		if ct == imagick.COMPRESSION_GROUP4 {
+			// Group 4 only encodes black and white
+			if err := mw.SetImageType(imagick.IMAGE_TYPE_BILEVEL); err != nil {
+				return fmt.Errorf("%w: failed to convert page to bilevel: %v", ErrProcessing, err)
+			}
+		}
+
+		if err := mw.SetImageFormat("TIFF"); err != nil {
+			return fmt.Errorf("%w: failed to set image format: %v", ErrProcessing, err)
+		}
+		if err := mw.SetImageCompression(ct); err != nil {
+			return fmt.Errorf("%w: failed to set compression: %v", ErrProcessing, err)
+		}
+		return nil
+	}, write, opts)
+}
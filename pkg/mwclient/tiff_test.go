@@ -0,0 +1,161 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTIFFCompression tests the names and ImageMagick mapping of TIFF
+// compressions
+func TestTIFFCompression(t *testing.T) {
+	for _, c := range []TIFFCompression{TIFFLZW, TIFFZIP, TIFFGroup4, TIFFNone} {
+		if _, err := c.compressionType(); err != nil {
+			t.Errorf("%v: unexpected error %v", c, err)
+		}
+	}
+
+	if got := TIFFCompression(42).String(); got != "TIFFCompression(42)" {
+		t.Errorf("unexpected name %q", got)
+	}
+	if _, err := TIFFCompression(42).compressionType(); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for unknown compression, got %v", err)
+	}
+}
+
+// TestPagePath tests the naming of per-page output files
+func TestPagePath(t *testing.T) {
+	tests := []struct {
+		path         string
+		index, pages int
+		want         string
+	}{
+		{"out/scan.png", 0, 1, "out/scan.png"},
+		{"out/scan.png", 0, 3, "out/scan_page1.png"},
+		{"out/scan.png", 2, 3, "out/scan_page3.png"},
+		{"scan", 1, 2, "scan_page2"},
+	}
+
+	for _, tt := range tests {
+		if got := pagePath(tt.path, tt.index, tt.pages); got != tt.want {
+			t.Errorf("pagePath(%q, %d, %d) = %q, want %q", tt.path, tt.index, tt.pages, got, tt.want)
+		}
+	}
+}
+
+// TestMultiPageTIFF tests assembling, enumerating and extracting the pages
+// of a multi-page TIFF
+func TestMultiPageTIFF(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	sizes := [][2]int{{200, 100}, {120, 160}, {80, 80}}
+
+	for _, tt := range []struct {
+		compression TIFFCompression
+		name        string
+	}{
+		{TIFFLZW, "LZW"},
+		{TIFFZIP, "ZIP"},
+		{TIFFGroup4, "Group4"},
+	} {
+		t.Run(tt.compression.String(), func(t *testing.T) {
+			dir := t.TempDir()
+
+			inputs := make([]io.Reader, len(sizes))
+			for i, size := range sizes {
+				inputs[i] = bytes.NewReader(testPNG(t, size[0], size[1]))
+			}
+
+			var buf bytes.Buffer
+			if err := client.AssembleTIFF(ctx, inputs, &buf, tt.compression); err != nil {
+				t.Fatalf("AssembleTIFF failed: %v", err)
+			}
+
+			tiffPath := filepath.Join(dir, "scan.tiff")
+			if err := os.WriteFile(tiffPath, buf.Bytes(), 0o644); err != nil {
+				t.Fatalf("Failed to write TIFF: %v", err)
+			}
+
+			meta, err := client.OpenImage(tiffPath)
+			if err != nil {
+				t.Fatalf("OpenImage failed: %v", err)
+			}
+			if meta.FrameCount != len(sizes) || len(meta.Pages) != len(sizes) {
+				t.Fatalf("expected %d pages, got %d (%d described)", len(sizes), meta.FrameCount, len(meta.Pages))
+			}
+			for i, page := range meta.Pages {
+				if int(page.Width) != sizes[i][0] || int(page.Height) != sizes[i][1] {
+					t.Errorf("page %d: expected %dx%d, got %dx%d", i, sizes[i][0], sizes[i][1], page.Width, page.Height)
+				}
+				if page.Compression != tt.name {
+					t.Errorf("page %d: expected %s compression, got %q", i, tt.name, page.Compression)
+				}
+			}
+
+			paths, err := client.ExtractPages(ctx, tiffPath, filepath.Join(dir, "page.png"), 0)
+			if err != nil {
+				t.Fatalf("ExtractPages failed: %v", err)
+			}
+			if len(paths) != len(sizes) {
+				t.Fatalf("expected %d pages, got %d", len(sizes), len(paths))
+			}
+			for i, path := range paths {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Failed to read page %d: %v", i, err)
+				}
+				if format, width, height := decodeConfig(t, data); format != "png" || width != sizes[i][0] || height != sizes[i][1] {
+					t.Errorf("page %d: expected %dx%d png, got %dx%d %s", i, sizes[i][0], sizes[i][1], width, height, format)
+				}
+			}
+		})
+	}
+
+	t.Run("MaxPages", func(t *testing.T) {
+		dir := t.TempDir()
+		inputPaths := make([]string, len(sizes))
+		for i, size := range sizes {
+			inputPaths[i] = filepath.Join(dir, filepath.Base(pagePath("in.png", i, len(sizes))))
+			if err := os.WriteFile(inputPaths[i], testPNG(t, size[0], size[1]), 0o644); err != nil {
+				t.Fatalf("Failed to write input: %v", err)
+			}
+		}
+
+		tiffPath := filepath.Join(dir, "scan.tif")
+		if err := client.AssembleTIFFFile(ctx, inputPaths, tiffPath, TIFFZIP); err != nil {
+			t.Fatalf("AssembleTIFFFile failed: %v", err)
+		}
+
+		paths, err := client.ExtractPages(ctx, tiffPath, filepath.Join(dir, "page.jpg"), 2)
+		if err != nil {
+			t.Fatalf("ExtractPages failed: %v", err)
+		}
+		if len(paths) != 2 {
+			t.Errorf("expected 2 pages, got %d", len(paths))
+		}
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		if err := client.AssembleTIFF(ctx, nil, &bytes.Buffer{}, TIFFLZW); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput without inputs, got %v", err)
+		}
+		inputs := []io.Reader{bytes.NewReader(testPNG(t, 10, 10))}
+		if err := client.AssembleTIFF(ctx, inputs, &bytes.Buffer{}, TIFFCompression(42)); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput for unknown compression, got %v", err)
+		}
+		if _, err := client.ExtractPages(ctx, "scan.tiff", "", 0); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput with empty output path, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package mwclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+// PageSize is a PDF page size in points (1/72 inch). The zero value sizes
+// each page to fit its image.
+type PageSize struct {
+	Width  float64
+	Height float64
+}
+
+// Standard page sizes
+var (
+	PageFitImage = PageSize{}
+	PageA4       = PageSize{Width: 595.28, Height: 841.89}
+	PageLetter   = PageSize{Width: 612, Height: 792}
+)
+
+// PDFLayout controls how images are placed on the pages of an assembled PDF
+type PDFLayout struct {
+	// Page is the size of every page, turned to match the orientation of
+	// its image. With PageFitImage each page is the size of its image at
+	// DPI, plus the margins.
+	Page PageSize
+
+	// Margin is the blank border on every side of a page, in points
+	Margin float64
+
+	// DPI is the resolution of the images on the page. Images larger than
+	// the printable area at this resolution are scaled down to fit; smaller
+	// ones are centered at their size. 0 uses the client's PDF density.
+	DPI float64
+}
+
+// validate checks the layout for invalid sizes
+func (l PDFLayout) validate() error {
+	if l.Page.Width < 0 || l.Page.Height < 0 || (l.Page.Width == 0) != (l.Page.Height == 0) {
+		return fmt.Errorf("%w: invalid page size %gx%g", ErrInvalidInput, l.Page.Width, l.Page.Height)
+	}
+	if l.Margin < 0 {
+		return fmt.Errorf("%w: margin must not be negative", ErrInvalidInput)
+	}
+	if l.Page != PageFitImage && 2*l.Margin >= min(l.Page.Width, l.Page.Height) {
+		return fmt.Errorf("%w: margins leave no room on the page", ErrInvalidInput)
+	}
+	if l.DPI < 0 {
+		return fmt.Errorf("%w: DPI must not be negative", ErrInvalidInput)
+	}
+	return nil
+}
+
+// AssemblePDF combines the images read from inputs, such as receipts or ID
+// scans, into a single PDF written to w, one page per image, in order.
+// Images are auto-oriented and laid out according to layout; multi-page
+// inputs contribute all their pages.
+func (c *Client) AssemblePDF(ctx context.Context, inputs []io.Reader, w io.Writer, layout PDFLayout, opts ...Option) error {
+	read, err := readerInputs(inputs, w)
+	if err != nil {
+		return err
+	}
+
+	return c.assemblePDF(ctx, len(inputs), layout, read, func(mw *imagick.MagickWand, s *settings) error {
+		_, err := writeImage(mw, w, s)
+		return err
+	}, opts)
+}
+
+// AssemblePDFFile is like AssemblePDF but reads the images from inputPaths
+// and writes the PDF to outputPath
+func (c *Client) AssemblePDFFile(ctx context.Context, inputPaths []string, outputPath string, layout PDFLayout, opts ...Option) error {
+	read, err := pathInputs(inputPaths, outputPath)
+	if err != nil {
+		return err
+	}
+
+	return c.assemblePDF(ctx, len(inputPaths), layout, read, documentWriter("PDF", outputPath), opts)
+}
+
+// assemblePDF assembles a PDF document with the given layout
+func (c *Client) assemblePDF(ctx context.Context, n int, layout PDFLayout, read readInput, write writeDocument, opts []Option) error {
+	if err := layout.validate(); err != nil {
+		return err
+	}
+
+	return c.assemble(ctx, n, read, func(mw *imagick.MagickWand, s *settings) error {
+		return layoutPDFPage(mw, layout, s)
+	}, write, opts)
+}
+
+// layoutPDFPage turns the image in mw into a PDF page: flattened onto the
+// background, scaled down to the printable area, centered on the page and
+// tagged with the page resolution, which sets the page size in the PDF
+func layoutPDFPage(mw *imagick.MagickWand, layout PDFLayout, s *settings) error {
+	dpi := layout.DPI
+	if dpi == 0 {
+		dpi = s.pdfDensity
+	}
+	pixels := func(points float64) uint {
+		return uint(math.Round(points * dpi / 72))
+	}
+
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+	bg.SetColor(s.background)
+
+	if err := mw.SetImageBackgroundColor(bg); err != nil {
+		return fmt.Errorf("%w: failed to set background color: %v", ErrProcessing, err)
+	}
+	if err := mw.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_REMOVE); err != nil {
+		return fmt.Errorf("%w: failed to flatten transparency: %v", ErrProcessing, err)
+	}
+
+	width, height := mw.GetImageWidth(), mw.GetImageHeight()
+	margin := pixels(layout.Margin)
+	pageWidth, pageHeight := width+2*margin, height+2*margin
+
+	if layout.Page != PageFitImage {
+		pageWidth, pageHeight = pixels(layout.Page.Width), pixels(layout.Page.Height)
+		if width != height && (width > height) != (pageWidth > pageHeight) {
+			pageWidth, pageHeight = pageHeight, pageWidth
+		}
+
+		spec := ResizeSpec{Width: pageWidth - 2*margin, Height: pageHeight - 2*margin, Mode: ResizeFit, OnlyShrink: true}
+		if err := resizeImage(mw, spec, s); err != nil {
+			return err
+		}
+		width, height = mw.GetImageWidth(), mw.GetImageHeight()
+	}
+
+	// A negative offset centers the image on the larger page
+	padX, padY := (int(pageWidth)-int(width))/2, (int(pageHeight)-int(height))/2
+	if err := mw.ExtentImage(pageWidth, pageHeight, -padX, -padY); err != nil {
+		return fmt.Errorf("%w: failed to place image on page: %v", ErrProcessing, err)
+	}
+	if err := mw.ResetImagePage(""); err != nil {
+		return fmt.Errorf("%w: failed to reset image page: %v", ErrProcessing, err)
+	}
+
+	if err := mw.SetImageUnits(imagick.RESOLUTION_PIXELS_PER_INCH); err != nil {
+		return fmt.Errorf("%w: failed to set resolution units: %v", ErrProcessing, err)
+	}
+	if err := mw.SetImageResolution(dpi, dpi); err != nil {
+		return fmt.Errorf("%w: failed to set resolution: %v", ErrProcessing, err)
+	}
+
+	if err := mw.SetImageFormat("PDF"); err != nil {
+		return fmt.Errorf("%w: failed to set image format: %v", ErrProcessing, err)
+	}
+	// Embed pages as JPEG at the client quality to keep archives small
+	if err := mw.SetImageCompression(imagick.COMPRESSION_JPEG); err != nil {
+		return fmt.Errorf("%w: failed to set compression: %v", ErrProcessing, err)
+	}
+	if err := mw.SetImageCompressionQuality(s.quality); err != nil {
+		return fmt.Errorf("%w: failed to set compression quality: %v", ErrProcessing, err)
+	}
+	return nil
+}
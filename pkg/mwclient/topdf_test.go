@@ -0,0 +1,101 @@
+package mwclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+)
+
+// TestPDFLayoutValidation tests the validation of PDF page layouts
+func TestPDFLayoutValidation(t *testing.T) {
+	valid := []PDFLayout{
+		{},
+		{Page: PageA4, Margin: 36, DPI: 150},
+		{Page: PageLetter},
+		{Page: PageFitImage, Margin: 1000},
+	}
+	for _, layout := range valid {
+		if err := layout.validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", layout, err)
+		}
+	}
+
+	invalid := []PDFLayout{
+		{Page: PageSize{Width: 100}},
+		{Page: PageSize{Width: -100, Height: 100}},
+		{Page: PageA4, Margin: -1},
+		{Page: PageA4, Margin: 300},
+		{DPI: -72},
+	}
+	for _, layout := range invalid {
+		if err := layout.validate(); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%+v: expected ErrInvalidInput, got %v", layout, err)
+		}
+	}
+}
+
+// TestAssemblePDF tests assembling images into a multi-page PDF
+func TestAssemblePDF(t *testing.T) {
+	// Skip test if ImageMagick is not properly configured
+	if !isImageMagickAvailable() {
+		t.Skip("ImageMagick not available, skipping test")
+	}
+
+	client := New()
+	defer client.Close()
+
+	ctx := context.Background()
+	pagePattern := regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+	tests := []struct {
+		name       string
+		layout     PDFLayout
+		mediaBoxes []string
+	}{
+		{"A4", PDFLayout{Page: PageA4, Margin: 36, DPI: 72}, []string{"595 842", "842 595"}},
+		{"Letter", PDFLayout{Page: PageLetter, DPI: 72}, []string{"612 792", "792 612"}},
+		{"FitImage", PDFLayout{Margin: 10, DPI: 72}, []string{"220 420", "420 220"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs := []io.Reader{
+				bytes.NewReader(testPNG(t, 200, 400)),
+				bytes.NewReader(testPNG(t, 400, 200)),
+			}
+
+			var buf bytes.Buffer
+			if err := client.AssemblePDF(ctx, inputs, &buf, tt.layout); err != nil {
+				t.Fatalf("AssemblePDF failed: %v", err)
+			}
+
+			pdf := buf.Bytes()
+			if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+				t.Fatal("expected a PDF")
+			}
+			if pages := len(pagePattern.FindAll(pdf, -1)); pages != len(inputs) {
+				t.Errorf("expected %d pages, got %d", len(inputs), pages)
+			}
+			for _, box := range tt.mediaBoxes {
+				if !regexp.MustCompile(`/MediaBox\s*\[\s*0 0 ` + box + `\s*\]`).Match(pdf) {
+					t.Errorf("expected a %s page", box)
+				}
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := client.AssemblePDF(ctx, nil, &buf, PDFLayout{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput without inputs, got %v", err)
+	}
+	inputs := []io.Reader{bytes.NewReader(testPNG(t, 10, 10))}
+	if err := client.AssemblePDF(ctx, inputs, &buf, PDFLayout{Margin: -1}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for invalid layout, got %v", err)
+	}
+	if err := client.AssemblePDFFile(ctx, []string{"a.png"}, "", PDFLayout{}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput with empty output path, got %v", err)
+	}
+}